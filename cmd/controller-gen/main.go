@@ -17,11 +17,17 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -33,7 +39,10 @@ import (
 	"sigs.k8s.io/controller-tools/pkg/genall"
 	"sigs.k8s.io/controller-tools/pkg/genall/help"
 	prettyhelp "sigs.k8s.io/controller-tools/pkg/genall/help/pretty"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/lsp"
 	"sigs.k8s.io/controller-tools/pkg/markers"
+	"sigs.k8s.io/controller-tools/pkg/plugin"
 	"sigs.k8s.io/controller-tools/pkg/rbac"
 	"sigs.k8s.io/controller-tools/pkg/schemapatcher"
 	"sigs.k8s.io/controller-tools/pkg/version"
@@ -70,6 +79,7 @@ var (
 		"none":      genall.OutputToNothing,
 		"stdout":    genall.OutputToStdout,
 		"artifacts": genall.OutputArtifacts{},
+		"helm":      genall.OutputToHelm{},
 	}
 
 	// optionsRegistry contains all the marker definitions used to process command line options
@@ -89,18 +99,7 @@ func init() {
 			}
 		}
 
-		// make per-generation output rule markers
-		for ruleName, rule := range allOutputRules {
-			ruleMarker := markers.Must(markers.MakeDefinition(fmt.Sprintf("output:%s:%s", genName, ruleName), markers.DescribesPackage, rule))
-			if err := optionsRegistry.Register(ruleMarker); err != nil {
-				panic(err)
-			}
-			if helpGiver, hasHelp := rule.(genall.HasHelp); hasHelp {
-				if help := helpGiver.Help(); help != nil {
-					optionsRegistry.AddHelp(ruleMarker, help)
-				}
-			}
-		}
+		registerOutputRuleOptions(genName)
 	}
 
 	// make "default output" output rule markers
@@ -122,6 +121,61 @@ func init() {
 	}
 }
 
+// registerOutputRuleOptions registers the per-generator output rule options
+// (output:<generator>:<rule>) for the given generator.
+func registerOutputRuleOptions(genName string) {
+	for ruleName, rule := range allOutputRules {
+		ruleMarker := markers.Must(markers.MakeDefinition(fmt.Sprintf("output:%s:%s", genName, ruleName), markers.DescribesPackage, rule))
+		if err := optionsRegistry.Register(ruleMarker); err != nil {
+			panic(err)
+		}
+		if helpGiver, hasHelp := rule.(genall.HasHelp); hasHelp {
+			if help := helpGiver.Help(); help != nil {
+				optionsRegistry.AddHelp(ruleMarker, help)
+			}
+		}
+	}
+}
+
+// resolvePlugins registers generators for the plugins on the PATH named by
+// the given raw options, for generators that aren't otherwise known.  Only
+// plugins that are actually asked for are run (to describe them), so runs of
+// the built-in generators (or asking for help) never run anything from the
+// PATH.  Options for unknown generators without a plugin are left to be
+// reported when parsing the options.  The plugins are run in the given
+// directory ("" for the working directory).
+func resolvePlugins(rawOpts []string, dir string) error {
+	for _, rawOpt := range rawOpts {
+		name, _, _ := strings.Cut(strings.TrimPrefix(rawOpt, "+"), ":")
+		name, _, _ = strings.Cut(name, "=")
+		if name == "" || name == "output" || optionsRegistry.Lookup("+"+name, markers.DescribesPackage) != nil {
+			continue
+		}
+		p := plugin.Find(os.Getenv("PATH"), name)
+		if p == nil {
+			continue
+		}
+		p.Dir = dir
+		if err := registerPlugin(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerPlugin registers the given plugin's generator, and the output
+// rule options for it.
+func registerPlugin(p *plugin.Plugin) error {
+	if _, isBuiltin := allGenerators[p.Name]; isBuiltin {
+		return fmt.Errorf("plugin %q would shadow the built-in generator of the same name", p.Name)
+	}
+	if _, err := plugin.Register(optionsRegistry, p); err != nil {
+		return err
+	}
+	registerOutputRuleOptions(p.Name)
+	return nil
+}
+
 // noUsageError suppresses usage printing when it occurs
 // (since cobra doesn't provide a good way to avoid printing
 // out usage in only certain situations).
@@ -131,6 +185,17 @@ func main() {
 	helpLevel := 0
 	whichLevel := 0
 	showVersion := false
+	parallelism := 1
+	checkOnly := false
+	syncOutputs := false
+	kustomize := false
+	warningsAsErrors := false
+	unknownMarkers := false
+	watch := false
+	diagnosticsFormat := "text"
+	var profOpts profileOptions
+	configPath := ""
+	var overlayPaths []string
 	var buildTags []string
 
 	cmd := &cobra.Command{
@@ -150,9 +215,52 @@ func main() {
 	# Run all the generators for a given project
 	controller-gen paths=./apis/...
 
+	# Fail (printing a diff) if the generated CRDs and deepcopy code are out of date
+	controller-gen crd object paths=./apis/... --check
+
+	# Also write a kustomization.yaml listing the generated manifests into each output directory
+	controller-gen rbac:roleName=<role name> crd paths=./apis/... output:crd:dir=./config/crd --kustomize
+
+	# Write CRDs, RBAC, and webhook configurations into a Helm chart, with placeholders in templates/
+	controller-gen rbac:roleName=<role name> crd webhook paths=./apis/... output:helm:chart=./charts/operator
+
+	# Fail if any of the generators report warnings
+	controller-gen crd paths=./apis/... --warnings-as-errors
+
+	# Warn about misspelled or misplaced markers (e.g. +kubebuilder:validation:Minimun=1)
+	controller-gen crd object paths=./apis/... --unknown-markers
+
+	# Attach markers to types from other modules (e.g. embedded in CRDs) from an overlay file
+	controller-gen crd object paths=./apis/... --marker-overlay hack/markers.yaml
+
+	# Report problems as SARIF (e.g. for code scanning in CI) instead of as text
+	controller-gen crd paths=./apis/... --diagnostics-format=sarif 2> controller-gen.sarif
+
+	# Report where the time goes when generating, and write a CPU profile for "go tool pprof"
+	controller-gen crd object paths=./apis/... --profile --profile-cpu=cpu.pprof
+
+	# Regenerate CRDs and deepcopy code whenever the API types change
+	controller-gen crd object paths=./apis/... --watch
+
+	# Perform the runs described in a config file (paths in it are relative to the file)
+	controller-gen --config hack/controller-gen.yaml
+
+	# Run a generator plugin (an executable named controller-gen-mygen on the PATH)
+	controller-gen mygen paths=./apis/... output:mygen:dir=./config/mygen
+
 	# Explain the markers for generating CRDs, and their arguments
 	controller-gen crd -ww
 
+	# Show how deprecated markers would be rewritten to their replacements, then rewrite them
+	controller-gen migrate-markers ./apis/... --dry-run
+	controller-gen migrate-markers ./apis/...
+
+	# Rewrite markers into their canonical form (e.g. in a pre-commit hook)
+	controller-gen fmt-markers ./apis/...
+
+	# Run a language server for markers (completion, help on hover, and errors), for use by editors
+	controller-gen lsp
+
 	# Generate applyconfigurations for CRDs for use with Server Side Apply. They will be placed
 	# into a "applyconfiguration/" subdirectory
 
@@ -170,34 +278,127 @@ func main() {
 				return c.Usage()
 			}
 
+			if err := resolvePlugins(rawOpts, ""); err != nil {
+				return noUsageError{err}
+			}
+
 			// print the marker docs if we asked for them, then bail
 			if whichLevel > 0 {
-				return printMarkerDocs(c, rawOpts, whichLevel)
+				return printMarkerDocs(c, rawOpts, configPath, buildTags, whichLevel)
+			}
+
+			if checkOnly && syncOutputs {
+				return fmt.Errorf("--check and --sync cannot be used together")
+			}
+			if watch && (checkOnly || syncOutputs || kustomize || configPath != "") {
+				return fmt.Errorf("--watch cannot be used with --check, --sync, --kustomize, or --config")
+			}
+			opts := runOptions{
+				buildTags:        buildTags,
+				parallelism:      parallelism,
+				checkOnly:        checkOnly,
+				syncOutputs:      syncOutputs,
+				kustomize:        kustomize,
+				warningsAsErrors: warningsAsErrors,
+				unknownMarkers:   unknownMarkers,
+				overlayPaths:     overlayPaths,
+			}
+			switch diagnosticsFormat {
+			case "text":
+			case "json", "sarif":
+				if watch {
+					return fmt.Errorf("--diagnostics-format=%s cannot be used with --watch", diagnosticsFormat)
+				}
+				opts.diagnostics = &genall.Diagnostics{}
+			default:
+				return fmt.Errorf("unknown diagnostics format %q (must be text, json, or sarif)", diagnosticsFormat)
 			}
 
-			// otherwise, set up the runtime for actually running the generators
-			tagsFlag := fmt.Sprintf("-tags=%s", strings.Join(buildTags, ","))
-			rt, err := genall.FromOptionsWithConfig(&packages.Config{BuildFlags: []string{tagsFlag}}, optionsRegistry, rawOpts)
+			if configPath != "" && len(rawOpts) > 0 {
+				return fmt.Errorf("options cannot be specified on the command line when using --config")
+			}
+
+			stopProfiling, err := startProfiling(c.ErrOrStderr(), profOpts)
 			if err != nil {
-				return err
+				return noUsageError{err}
 			}
-			if len(rt.Generators) == 0 {
-				return fmt.Errorf("no generators specified")
+			switch {
+			case watch:
+				err = watchGenerators(c, rawOpts, opts)
+			case configPath != "":
+				err = runConfig(c, configPath, opts)
+			default:
+				// otherwise, set up the runtime for actually running the generators
+				err = runGenerators(c, rawOpts, opts)
+			}
+			if profErr := stopProfiling(); profErr != nil && err == nil {
+				err = noUsageError{profErr}
 			}
 
-			if hadErrs := rt.Run(); hadErrs {
-				// don't obscure the actual error with a bunch of usage
-				return noUsageError{fmt.Errorf("not all generators ran successfully")}
+			if opts.diagnostics != nil {
+				if writeErr := writeDiagnostics(c.ErrOrStderr(), opts.diagnostics, diagnosticsFormat); writeErr != nil {
+					return noUsageError{writeErr}
+				}
+				if errors.Is(err, errGenerationFailed) || errors.Is(err, errRunsFailed) {
+					// the details are in the diagnostics, so don't muddy them
+					// up with more text.
+					c.SilenceErrors = true
+					return silentError{err}
+				}
 			}
-			return nil
+			return err
 		},
 		SilenceUsage: true, // silence the usage, then print it out ourselves if it wasn't suppressed
+		// options aren't subcommands
+		Args: cobra.ArbitraryArgs,
 	}
+	cmd.CompletionOptions.DisableDefaultCmd = true
+	cmd.AddCommand(&cobra.Command{
+		Use:   "lsp",
+		Short: "Run a language server for markers over standard input and output.",
+		Long: `Run a language server (speaking the Language Server Protocol over standard input and output)
+for the markers known to the built-in generators.  It offers completion of marker names and
+arguments in Go comments, shows marker help (and the schema produced by CRD validation markers)
+on hover, and reports errors from parsing markers.`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, _ []string) error {
+			reg, err := allMarkers()
+			if err != nil {
+				return err
+			}
+			if err := genall.RegisterNoLintMarkers(reg); err != nil {
+				return err
+			}
+			if err := genall.RegisterMacroMarkers(reg); err != nil {
+				return err
+			}
+			server := &lsp.Server{Registry: reg, Version: version.Version()}
+			if err := server.Serve(c.InOrStdin(), c.OutOrStdout()); err != nil {
+				return noUsageError{err}
+			}
+			return nil
+		},
+	})
 	cmd.Flags().CountVarP(&whichLevel, "which-markers", "w", "print out all markers available with the requested generators\n(up to -www for the most detailed output, or -wwww for json output)")
 	cmd.Flags().CountVarP(&helpLevel, "detailed-help", "h", "print out more detailed help\n(up to -hhh for the most detailed output, or -hhhh for json output)")
 	cmd.Flags().BoolVar(&showVersion, "version", false, "show version")
+	cmd.Flags().IntVar(&parallelism, "parallelism", 1, "maximum number of generators to run at the same time")
+	cmd.Flags().BoolVar(&checkOnly, "check", false, "compare generated artifacts with the files on disk instead of writing them,\nprinting a diff and failing if any are out of date")
+	cmd.Flags().BoolVar(&warningsAsErrors, "warnings-as-errors", false, "report warnings from generators as errors, failing if there are any\n(suppress particular warnings with +kubebuilder:nolint:<code> markers)")
+	cmd.Flags().StringArrayVar(&overlayPaths, "marker-overlay", nil, "attach the markers in the given overlay file to types and fields, as if they were written\nin their source (may be repeated; see the detailed help for the file format)")
+	cmd.Flags().BoolVar(&unknownMarkers, "unknown-markers", false, "warn about markers that look like they're meant for controller-gen (e.g. +kubebuilder:...),\nbut that aren't known by any generator or are on the wrong kind of node")
+	cmd.Flags().BoolVar(&syncOutputs, "sync", false, "only write generated artifacts whose contents changed, and remove generated\nfiles that are no longer produced from the output directories")
+	cmd.Flags().BoolVar(&kustomize, "kustomize", false, "write a kustomization.yaml listing the generated YAML files as resources into each\noutput directory, merging with any existing one")
+	cmd.Flags().BoolVar(&watch, "watch", false, "keep running, and re-run the affected generators whenever the Go files\nof the roots (or files read by generators, like headers) change")
+	cmd.Flags().StringVar(&diagnosticsFormat, "diagnostics-format", "text", "format for reporting problems on standard error: text, json, or sarif\n(json and sarif include file, line, column, severity, generator, and marker)")
+	cmd.Flags().BoolVar(&profOpts.report, "profile", false, "print the time spent in (and memory allocated by) each phase of generation,\nper generator, once done")
+	cmd.Flags().StringVar(&profOpts.cpuPath, "profile-cpu", "", "write a Go pprof CPU profile to the given file")
+	cmd.Flags().StringVar(&profOpts.heapPath, "profile-heap", "", "write a Go pprof heap profile to the given file once done")
+	cmd.Flags().StringVar(&profOpts.tracePath, "profile-trace", "", "write the phases of generation to the given file as Chrome trace events\n(for chrome://tracing or https://ui.perfetto.dev)")
+	cmd.Flags().StringVar(&configPath, "config", "", "read the runs to perform from the given config file instead of the command line\n(see the detailed help for the file format)")
 	cmd.Flags().StringSliceVar(&buildTags, "load-build-tags", []string{"ignore_autogenerated"}, "build tags to use when loading Go packages")
 	cmd.Flags().Bool("help", false, "print out usage and a summary of options")
+	cmd.AddCommand(migrateMarkersCommand(), fmtMarkersCommand())
 	oldUsage := cmd.UsageFunc()
 	cmd.SetUsageFunc(func(c *cobra.Command) error {
 		if err := oldUsage(c); err != nil {
@@ -207,10 +408,23 @@ func main() {
 			helpLevel = summaryHelp
 		}
 		fmt.Fprintf(c.OutOrStderr(), "\n\nOptions\n\n")
-		return helpForLevels(c.OutOrStdout(), c.OutOrStderr(), helpLevel, optionsRegistry, help.SortByOption)
+		if err := helpForLevels(c.OutOrStdout(), c.OutOrStderr(), helpLevel, optionsRegistry, help.SortByOption); err != nil {
+			return err
+		}
+		if helpLevel == detailedHelp || helpLevel == fullHelp {
+			fmt.Fprintf(c.OutOrStderr(), "\n\nConfig File\n\n")
+			writeConfigFileHelp(c.OutOrStdout())
+			fmt.Fprintf(c.OutOrStderr(), "\n\nMarker Overlay Files\n\n")
+			fmt.Fprint(c.OutOrStdout(), overlayFileHelp)
+		}
+		return nil
 	})
 
-	if err := cmd.Execute(); err != nil {
+	if ranCmd, err := cmd.ExecuteC(); err != nil {
+		var errSilent silentError
+		if errors.As(err, &errSilent) {
+			os.Exit(1)
+		}
 		var errNoUsage noUsageError
 		if !errors.As(err, &errNoUsage) {
 			// print the usage unless we suppressed it
@@ -218,21 +432,474 @@ func main() {
 				panic(err)
 			}
 		}
-		fmt.Fprintf(cmd.OutOrStderr(), "run `%[1]s %[2]s -w` to see all available markers, or `%[1]s %[2]s -h` for usage\n", cmd.CalledAs(), strings.Join(os.Args[1:], " "))
+		if ranCmd == cmd {
+			// subcommands don't take generator markers, so don't point at them
+			fmt.Fprintf(cmd.OutOrStderr(), "run `%[1]s %[2]s -w` to see all available markers, or `%[1]s %[2]s -h` for usage\n", cmd.CalledAs(), strings.Join(os.Args[1:], " "))
+		}
 		os.Exit(1)
 	}
 }
 
+// rewriteMarkersCommand returns a subcommand that rewrites markers in the
+// given packages with the given function (like genall.MigrateMarkers),
+// reporting what it did with the given verb.
+func rewriteMarkersCommand(cmd *cobra.Command, verb string, rewrite func(*markers.Collector, []*loader.Package) ([]genall.RewrittenFile, error)) *cobra.Command {
+	dryRun := false
+	var buildTags []string
+	cmd.RunE = func(c *cobra.Command, patterns []string) error {
+		if len(patterns) == 0 {
+			patterns = []string{"."}
+		}
+		reg, err := allMarkers()
+		if err != nil {
+			return err
+		}
+		tagsFlag := fmt.Sprintf("-tags=%s", strings.Join(buildTags, ","))
+		roots, err := loader.LoadRootsWithConfig(&packages.Config{BuildFlags: []string{tagsFlag}}, patterns...)
+		if err != nil {
+			return noUsageError{err}
+		}
+
+		files, rewriteErr := rewrite(&markers.Collector{Registry: reg}, roots)
+		for _, file := range files {
+			if dryRun {
+				fmt.Fprint(c.OutOrStdout(), file.Diff())
+				continue
+			}
+			if err := file.Write(); err != nil {
+				return noUsageError{err}
+			}
+			fmt.Fprintf(c.ErrOrStderr(), "%s %d marker(s) in %s\n", verb, len(file.Rewrites), file.Path)
+		}
+		if rewriteErr != nil {
+			var errs loader.ErrList
+			if !errors.As(rewriteErr, &errs) {
+				errs = loader.ErrList{rewriteErr}
+			}
+			for _, err := range errs {
+				fmt.Fprintln(c.ErrOrStderr(), err)
+			}
+			return noUsageError{errors.New("not all markers could be " + verb)}
+		}
+		return nil
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print a diff of the changes instead of making them")
+	cmd.Flags().StringSliceVar(&buildTags, "load-build-tags", []string{"ignore_autogenerated"}, "build tags to use when loading Go packages")
+	return cmd
+}
+
+// migrateMarkersCommand returns the migrate-markers subcommand.
+func migrateMarkersCommand() *cobra.Command {
+	return rewriteMarkersCommand(&cobra.Command{
+		Use:   "migrate-markers [packages]",
+		Short: "Rewrite deprecated markers in place to their replacements.",
+		Long: `Rewrite deprecated markers (like +k8s:deepcopy-gen) in the Go files of the given packages
+(or the current one) to the markers that replace them, leaving the rest of the comments as they are.`,
+	}, "migrated", genall.MigrateMarkers)
+}
+
+// fmtMarkersCommand returns the fmt-markers subcommand.
+func fmtMarkersCommand() *cobra.Command {
+	return rewriteMarkersCommand(&cobra.Command{
+		Use:   "fmt-markers [packages]",
+		Short: "Rewrite markers in place into their canonical form.",
+		Long: `Rewrite the markers in the Go files of the given packages (or the current one) into their
+canonical form: arguments in the order they're declared, strings only quoted when needed,
+and slices and maps in curly braces (e.g. +kubebuilder:validation:Enum={a, b} instead of
++kubebuilder:validation:Enum=a;b).  Formatting already formatted markers changes nothing.`,
+	}, "formatted", genall.FormatMarkers)
+}
+
+// writeConfigFileHelp describes the format of the file passed to --config,
+// using the help for genall.Config and its fields.
+func writeConfigFileHelp(out io.Writer) {
+	configHelp, fields := genall.ConfigHelp()
+	fmt.Fprintf(out, "A config file (passed with --config) %s\n%s\n\n", configHelp.Summary, configHelp.Details)
+	fmt.Fprint(out, `Paths in the file are relative to the file itself.  Argument values are
+written as YAML, and have the same types as the corresponding options.
+`)
+	for _, field := range fields {
+		typ := field.Type
+		if field.Optional {
+			typ = "optional " + typ
+		}
+		fmt.Fprintf(out, "\n%s (%s)\n", field.Path, typ)
+		fmt.Fprintf(out, "\t%s\n", field.Summary)
+		if field.Details != "" {
+			fmt.Fprintf(out, "\t%s\n", strings.ReplaceAll(field.Details, "\n", "\n\t"))
+		}
+	}
+}
+
+// overlayFileHelp describes the format of the files passed to --marker-overlay.
+const overlayFileHelp = `A marker overlay file (passed with --marker-overlay) attaches markers to types
+and fields whose source can't be edited (e.g. types from other modules that are
+embedded in CRDs), as if they were written in the source.
+
+  version: v1alpha1          # required
+  markers:                   # <package path>.<Type> or <package path>.<Type>.<Field>
+    k8s.io/api/core/v1.Container:
+    - +kubebuilder:validation:XValidation:rule="self.image != ''",message="image is required"
+    k8s.io/api/core/v1.Container.Name:
+    - +kubebuilder:validation:MaxLength=63
+
+Fields are named by their Go name (or the name of their type, if embedded).
+Generation fails if a target doesn't exist, e.g. after it was renamed upstream.
+`
+
+// silentError exits with a failure status without printing anything more
+// (for when the problems have already been reported).
+type silentError struct{ error }
+
+// runOptions are the command line flags that affect how runs are performed.
+type runOptions struct {
+	buildTags   []string
+	parallelism int
+	checkOnly   bool
+	syncOutputs bool
+	// kustomize writes kustomization files listing the generated YAML.
+	kustomize bool
+	// warningsAsErrors reports warnings as errors.
+	warningsAsErrors bool
+	// unknownMarkers warns about unknown and misplaced markers.
+	unknownMarkers bool
+	// diagnostics collects problems, if they're to be reported as
+	// structured diagnostics rather than text.
+	diagnostics *genall.Diagnostics
+	// macros are the marker macros from the config file, if any.
+	macros []markers.Macro
+	// overlayPaths are the marker overlay files to load.
+	overlayPaths []string
+	// dir is the directory that relative paths (in the options, and in the
+	// paths passed to generators) are relative to, or "" for the working
+	// directory.
+	dir string
+}
+
+var (
+	// errStale is returned by runGenerators when --check found out-of-date artifacts.
+	errStale = noUsageError{errors.New("generated artifacts are out of date")}
+	// errGenerationFailed is returned by runGenerators when generators reported problems.
+	errGenerationFailed = noUsageError{errors.New("not all generators ran successfully")}
+	// errRunsFailed is returned by runConfig when any of the runs failed.
+	errRunsFailed = noUsageError{errors.New("not all runs completed successfully")}
+)
+
+// profileOptions are the command line flags for profiling.
+type profileOptions struct {
+	// report prints a report of the phases of generation.
+	report bool
+	// cpuPath, heapPath, and tracePath are the files to write CPU and heap
+	// profiles, and a trace of the phases of generation to, if any.
+	cpuPath   string
+	heapPath  string
+	tracePath string
+}
+
+// startProfiling starts profiling as per the given options, returning a
+// function that stops it and writes out the results.
+func startProfiling(errOut io.Writer, opts profileOptions) (func() error, error) {
+	var prof *genall.Profile
+	if opts.report || opts.tracePath != "" {
+		prof = genall.StartProfile()
+	}
+	var cpuFile *os.File
+	if opts.cpuPath != "" {
+		var err error
+		if cpuFile, err = os.Create(opts.cpuPath); err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, err
+		}
+	}
+
+	return func() error {
+		var errs []error
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			errs = append(errs, cpuFile.Close())
+		}
+		if opts.heapPath != "" {
+			errs = append(errs, writeProfileFile(opts.heapPath, func(out io.Writer) error {
+				runtime.GC() // get up-to-date statistics
+				return pprof.WriteHeapProfile(out)
+			}))
+		}
+		if prof != nil {
+			prof.Stop()
+			if opts.report {
+				errs = append(errs, prof.WriteReport(errOut))
+			}
+			if opts.tracePath != "" {
+				errs = append(errs, writeProfileFile(opts.tracePath, prof.WriteTrace))
+			}
+		}
+		return errors.Join(errs...)
+	}, nil
+}
+
+// writeProfileFile writes a profile to the given file using the given function.
+func writeProfileFile(path string, write func(io.Writer) error) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// writeDiagnostics writes the collected diagnostics in the given format.
+func writeDiagnostics(out io.Writer, diags *genall.Diagnostics, format string) error {
+	if format == "sarif" {
+		return diags.WriteSARIF(out, version.Version())
+	}
+	return diags.WriteJSON(out)
+}
+
+// loadRuntime sets up a runtime from the given raw options.
+func loadRuntime(rawOpts []string, opts runOptions) (*genall.Runtime, error) {
+	tagsFlag := fmt.Sprintf("-tags=%s", strings.Join(opts.buildTags, ","))
+	cfg := &packages.Config{BuildFlags: []string{tagsFlag}, Dir: opts.dir}
+	rt, err := genall.FromOptionsWithConfig(cfg, optionsRegistry, rawOpts)
+	if err != nil {
+		return nil, err
+	}
+	if len(rt.Generators) == 0 {
+		return nil, fmt.Errorf("no generators specified")
+	}
+	if rt.Collector.Overlays, err = genall.LoadOverlays(cfg, opts.overlayPaths...); err != nil {
+		return nil, noUsageError{err}
+	}
+	rt.Parallelism = opts.parallelism
+	rt.Collector.Macros = opts.macros
+	rt.Diagnostics = opts.diagnostics
+	rt.WarningsAsErrors = opts.warningsAsErrors
+	if opts.unknownMarkers {
+		// check against the markers of every generator, not just the ones
+		// being run, so that e.g. CRD markers aren't reported when only
+		// generating deepcopy code.
+		if rt.KnownMarkers, err = allMarkers(); err != nil {
+			return nil, err
+		}
+	}
+	return rt, nil
+}
+
+// allMarkers returns a registry containing the markers of all the built-in
+// generators.
+func allMarkers() (*markers.Registry, error) {
+	reg := &markers.Registry{}
+	for _, gen := range allGenerators {
+		if err := gen.RegisterMarkers(reg); err != nil {
+			return nil, err
+		}
+	}
+	return reg, nil
+}
+
+// watchGenerators runs the generators from the given raw options, then
+// keeps re-running them as their inputs change, until interrupted.
+func watchGenerators(c *cobra.Command, rawOpts []string, opts runOptions) error {
+	// check the options up front, so that we can print usage on errors
+	if _, err := genall.RegistryFromOptions(optionsRegistry, rawOpts); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	watcher := &genall.Watcher{
+		Load: func() (*genall.Runtime, error) { return loadRuntime(rawOpts, opts) },
+		Log:  c.OutOrStderr(),
+	}
+	fmt.Fprintln(c.OutOrStderr(), "watching for changes (press Ctrl-C to stop)")
+	if err := watcher.Watch(ctx); err != nil {
+		return noUsageError{err}
+	}
+	return nil
+}
+
+// runGenerators sets up a runtime from the given raw options and runs it.
+func runGenerators(c *cobra.Command, rawOpts []string, opts runOptions) error {
+	rt, err := loadRuntime(rawOpts, opts)
+	if err != nil {
+		return err
+	}
+
+	var checker *genall.OutputChecker
+	if opts.checkOnly {
+		checker = &genall.OutputChecker{}
+		if err := checker.WrapOutputRules(rt); err != nil {
+			return err
+		}
+	}
+	var syncer *genall.OutputSyncer
+	if opts.syncOutputs {
+		syncer = &genall.OutputSyncer{}
+		if err := syncer.WrapOutputRules(rt); err != nil {
+			return err
+		}
+	}
+
+	var kustomizer *genall.Kustomizer
+	if opts.kustomize {
+		// wrap last, so that the kustomization files are checked or synced too
+		kustomizer = &genall.Kustomizer{}
+		if err := kustomizer.WrapOutputRules(rt); err != nil {
+			return err
+		}
+	}
+
+	if hadErrs := rt.Run(); hadErrs {
+		// don't obscure the actual error with a bunch of usage
+		return errGenerationFailed
+	}
+
+	if kustomizer != nil {
+		if err := kustomizer.Write(); err != nil {
+			return noUsageError{err}
+		}
+	}
+
+	if syncer != nil {
+		removed, err := syncer.Prune()
+		for _, path := range removed {
+			fmt.Fprintf(c.OutOrStderr(), "removed stale generated file %s\n", path)
+		}
+		if err != nil {
+			return noUsageError{err}
+		}
+	}
+
+	if checker != nil {
+		stale, err := checker.Report(c.OutOrStdout())
+		if err != nil {
+			return noUsageError{err}
+		}
+		if stale {
+			return errStale
+		}
+	}
+	return nil
+}
+
+// runConfig performs each of the runs in the given config file, relative to
+// the directory containing the file.  All runs are attempted (so that --check
+// reports everything that's out of date), and the first error is returned.
+func runConfig(c *cobra.Command, configPath string, opts runOptions) error {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	config, err := genall.ParseConfig(data)
+	if err != nil {
+		return fmt.Errorf("unable to parse config file %s: %w", configPath, err)
+	}
+
+	// paths in the config are relative to the config file itself
+	opts.dir = filepath.Dir(configPath)
+	relToConfig := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(opts.dir, path)
+	}
+
+	opts.macros = config.Macros
+	opts.overlayPaths = slices.Clone(opts.overlayPaths)
+	for _, path := range config.Overlays {
+		opts.overlayPaths = append(opts.overlayPaths, relToConfig(path))
+	}
+
+	for _, pluginConfig := range config.Plugins {
+		p := &plugin.Plugin{Name: pluginConfig.Name, Path: relToConfig(pluginConfig.Path), Dir: opts.dir}
+		if err := registerPlugin(p); err != nil {
+			return noUsageError{fmt.Errorf("unable to register plugin %q from config file: %w", pluginConfig.Name, err)}
+		}
+	}
+
+	var firstErr error
+	for i, run := range config.Runs {
+		name := run.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		rawOpts, err := run.Options()
+		if err == nil {
+			err = resolvePlugins(rawOpts, opts.dir)
+		}
+		if err == nil {
+			err = runGenerators(c, rawOpts, opts)
+		}
+		if err == nil {
+			continue
+		}
+		quiet := errors.Is(err, errStale) || (opts.diagnostics != nil && errors.Is(err, errGenerationFailed))
+		if !quiet {
+			fmt.Fprintf(c.OutOrStderr(), "run %s: %v\n", name, err)
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if errors.Is(firstErr, errStale) {
+		return errStale
+	}
+	if firstErr != nil {
+		return errRunsFailed
+	}
+	return nil
+}
+
 // printMarkerDocs prints out marker help for the given generators specified in
-// the rawOptions, at the given level.
-func printMarkerDocs(c *cobra.Command, rawOptions []string, whichLevel int) error {
+// the rawOptions, at the given level, along with the macros from the given
+// config file (if any) and the packages in the rawOptions (if any).
+func printMarkerDocs(c *cobra.Command, rawOptions []string, configPath string, buildTags []string, whichLevel int) error {
 	// just grab a registry so we don't lag while trying to load roots
-	// (like we'd do if we just constructed the full runtime).
+	// (like we'd do if we just constructed the full runtime) -- unless we
+	// need to look for macros in them.
 	reg, err := genall.RegistryFromOptions(optionsRegistry, rawOptions)
 	if err != nil {
 		return err
 	}
 
+	var configMacros []markers.Macro
+	if configPath != "" {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return err
+		}
+		config, err := genall.ParseConfig(data)
+		if err != nil {
+			return fmt.Errorf("unable to parse config file %s: %w", configPath, err)
+		}
+		configMacros = config.Macros
+		if len(rawOptions) == 0 {
+			// the generators are given per run, so list the markers of all of them
+			if reg, err = allMarkers(); err != nil {
+				return err
+			}
+			if err := genall.RegisterNoLintMarkers(reg); err != nil {
+				return err
+			}
+			if err := genall.RegisterMacroMarkers(reg); err != nil {
+				return err
+			}
+		}
+	}
+	tagsFlag := fmt.Sprintf("-tags=%s", strings.Join(buildTags, ","))
+	macros, err := genall.MacrosFromOptions(&packages.Config{BuildFlags: []string{tagsFlag}}, optionsRegistry, reg, rawOptions, configMacros)
+	if err != nil {
+		return noUsageError{err}
+	}
+	if err := genall.RegisterMacroHelp(reg, macros); err != nil {
+		return noUsageError{err}
+	}
+
 	return helpForLevels(c.OutOrStdout(), c.OutOrStderr(), whichLevel, reg, help.SortByCategory)
 }
 
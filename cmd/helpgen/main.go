@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"go/format"
+	"go/token"
 	"io"
 	"os"
 	"slices"
@@ -129,15 +130,10 @@ func (Generator) RegisterMarkers(reg *markers.Registry) error {
 	return nil
 }
 func (g Generator) Generate(ctx *genall.GenerationContext) error {
-	var headerText string
-	if g.HeaderFile != "" {
-		headerBytes, err := ctx.ReadFile(g.HeaderFile)
-		if err != nil {
-			return err
-		}
-		headerText = string(headerBytes)
+	boilerplate, err := ctx.LoadHeader(g.HeaderFile, g.Year)
+	if err != nil {
+		return err
 	}
-	headerText = strings.ReplaceAll(headerText, " YEAR", g.Year)
 
 	for _, root := range ctx.Roots {
 		byType := make(map[string]string)
@@ -149,6 +145,10 @@ func (g Generator) Generate(ctx *genall.GenerationContext) error {
 			outContent := new(bytes.Buffer)
 
 			for _, field := range info.Fields {
+				if !token.IsExported(field.Name) {
+					// unexported fields can't be set with markers
+					continue
+				}
 				summary, details := godocToDetails(field.Name, field.Doc)
 				fmt.Fprintf(outContent, "%[1]q: {\nSummary: %[2]q,\n Details: %[3]q,\n},\n", field.Name, summary, details)
 			}
@@ -170,6 +170,11 @@ func (g Generator) Generate(ctx *genall.GenerationContext) error {
 		}
 		slices.Sort(typeNames)
 
+		headerText, err := boilerplate.Render(genall.GoArtifact, root)
+		if err != nil {
+			root.AddError(err)
+			continue
+		}
 		outContent := new(bytes.Buffer)
 		if headerText == "" {
 			panic("at the disco!")
@@ -214,7 +219,7 @@ func (Generator) Help() *markers.DefinitionHelp {
 		},
 		FieldHelp: map[string]markers.DetailedHelp{
 			"HeaderFile": {Summary: "the file containing the header to use for generated files"},
-			"Year":       {Summary: "replace \" YEAR\" in the header with this value."},
+			"Year":       {Summary: "the year to use in the header file (as {{ .Year }}, or in place of \" YEAR\")"},
 		},
 	}
 }
@@ -233,6 +233,31 @@ var _ = Describe("ApplyConfiguration generation from API types", func() {
 		Entry("with the an alternative output package", "other"),
 		Entry("with a package outside of the current directory", "../../clients"),
 	)
+
+	It("should render templated header files for each package", func() {
+		By("writing a templated header file")
+		Expect(os.WriteFile("header.txt", []byte("Generated for {{ .Package }} in {{ .Year }}.\n"), 0o644)).To(Succeed())
+
+		By("Initializing the runtime")
+		optionsRegistry := &markers.Registry{}
+		Expect(genall.RegisterOptionsMarkers(optionsRegistry)).To(Succeed())
+		Expect(optionsRegistry.Register(markers.Must(markers.MakeDefinition("applyconfiguration", markers.DescribesPackage, Generator{})))).To(Succeed())
+
+		rt, err := genall.FromOptions(optionsRegistry, []string{
+			"applyconfiguration:externalApplyConfigurations=sigs.k8s.io/controller-tools/pkg/applyconfiguration/testdata/cronjob/external.ExternalData@sigs.k8s.io/controller-tools/pkg/applyconfiguration/testdata/cronjob/externalac,headerFile=header.txt,year=2042",
+			"paths=./api/v1",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		rt.OutputRules = genall.OutputRules{Default: make(outputToMap)}
+
+		By("Running the generator")
+		Expect(rt.Run()).To(BeFalse(), "Generator should run without errors")
+
+		By("Checking the header of a generated file")
+		generated, err := os.ReadFile(filepath.Join("api/v1", applyConfigurationDir, "api/v1/cronjob.go"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(generated)).To(HavePrefix("// Generated for sigs.k8s.io/controller-tools/pkg/applyconfiguration/testdata/cronjob/api/v1 in 2042.\n"))
+	})
 })
 
 func replaceOutputPkgMarker(dir string, newOutputPackage string) error {
@@ -23,7 +23,9 @@ import (
 	"maps"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/gengo/v2/types"
@@ -40,6 +42,7 @@ import (
 	crdmarkers "sigs.k8s.io/controller-tools/pkg/crd/markers"
 	"sigs.k8s.io/controller-tools/pkg/genall"
 	"sigs.k8s.io/controller-tools/pkg/internal/crd"
+	"sigs.k8s.io/controller-tools/pkg/internal/profile"
 	"sigs.k8s.io/controller-tools/pkg/loader"
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
@@ -60,8 +63,15 @@ const defaultOutputPackage = "applyconfiguration"
 // Generator generates code containing apply configuration type implementations.
 type Generator struct {
 	// HeaderFile specifies the header text (e.g. license) to prepend to generated files.
+	//
+	// See genall.Header for its format (including the template variables it may use).
 	HeaderFile string `marker:",optional"`
 
+	// Year specifies the year to use in the header file (as {{ .Year }}, or in place of " YEAR").
+	//
+	// Left unspecified, the current year is used.
+	Year string `marker:",optional"`
+
 	// ExternalApplyConfigurations provides mappings between external types and their applyconfiguration packages.
 	//
 	// Use this to reference apply configuration types for external types referenced
@@ -142,20 +152,14 @@ func isCRD(info *markers.TypeInfo) bool {
 }
 
 func (d Generator) Generate(ctx *genall.GenerationContext) error {
-	headerFilePath := d.HeaderFile
-
-	if headerFilePath == "" {
-		tmpFile, err := os.CreateTemp("", "applyconfig-header-*.txt")
-		if err != nil {
-			return fmt.Errorf("failed to create temporary file: %w", err)
-		}
-		if err := tmpFile.Close(); err != nil {
-			return fmt.Errorf("failed to close temporary file: %w", err)
-		}
-
-		defer os.Remove(tmpFile.Name())
-
-		headerFilePath = tmpFile.Name()
+	year := d.Year
+	if year == "" {
+		// gengo fills in " YEAR" with the current year, so keep doing that.
+		year = strconv.Itoa(time.Now().Year())
+	}
+	header, err := ctx.LoadHeader(d.HeaderFile, year)
+	if err != nil {
+		return err
 	}
 
 	// Parse external apply configurations
@@ -172,7 +176,7 @@ func (d Generator) Generate(ctx *genall.GenerationContext) error {
 	objGenCtx := ObjectGenCtx{
 		Collector:                   ctx.Collector,
 		Checker:                     ctx.Checker,
-		HeaderFilePath:              headerFilePath,
+		Header:                      header,
 		ExternalApplyConfigurations: externalACs,
 	}
 
@@ -198,6 +202,10 @@ type ObjectGenCtx struct {
 	Checker                     *loader.TypeChecker
 	HeaderFilePath              string
 	ExternalApplyConfigurations map[types.Name]string
+
+	// Header, if set, is rendered for each package as the header of the
+	// generated files, instead of using the file at HeaderFilePath.
+	Header *genall.Header
 }
 
 // generateForPackage generates apply configuration implementations for
@@ -213,6 +221,14 @@ func (ctx *ObjectGenCtx) generateForPackage(root *loader.Package) error {
 
 	arguments := args.New()
 	arguments.GoHeaderFile = ctx.HeaderFilePath
+	if ctx.Header != nil {
+		headerFile, err := writeHeaderFile(ctx.Header, root)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(headerFile)
+		arguments.GoHeaderFile = headerFile
+	}
 
 	// Set external apply configurations
 	maps.Copy(arguments.ExternalApplyConfigurations, ctx.ExternalApplyConfigurations)
@@ -227,12 +243,15 @@ func (ctx *ObjectGenCtx) generateForPackage(root *loader.Package) error {
 	// that Kubebuilder generation markers are converted into the genclient marker
 	// prior to executing the targets.
 	buildTags := []string{gengo.StdBuildTag}
+	endGengo := profile.Start(profile.Gengo)
 	p := parser.NewWithOptions(parser.Options{BuildTags: buildTags})
 	if err := p.LoadPackages(root.PkgPath); err != nil {
+		endGengo()
 		return fmt.Errorf("failed making a parser: %w", err)
 	}
 
 	c, err := generator.NewContext(p, generators.NameSystems(), generators.DefaultNameSystem())
+	endGengo()
 	if err != nil {
 		return fmt.Errorf("failed making a context: %w", err)
 	}
@@ -290,6 +309,7 @@ func (ctx *ObjectGenCtx) generateForPackage(root *loader.Package) error {
 		arguments.OpenAPISchemaFilePath = schemaFile
 	}
 
+	defer profile.Start(profile.Gengo)()
 	targets := generators.GetTargets(c, arguments)
 	if err := c.ExecuteTargets(targets); err != nil {
 		return fmt.Errorf("failed executing generator: %w", err)
@@ -298,6 +318,29 @@ func (ctx *ObjectGenCtx) generateForPackage(root *loader.Package) error {
 	return nil
 }
 
+// writeHeaderFile renders the given header for the given package into a
+// temporary file, since gengo reads headers from files, returning its path.
+func writeHeaderFile(header *genall.Header, root *loader.Package) (string, error) {
+	headerText, err := header.Render(genall.GoArtifact, root)
+	if err != nil {
+		return "", err
+	}
+	tmpFile, err := os.CreateTemp("", "applyconfig-header-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	if _, err := tmpFile.WriteString(headerText); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	return tmpFile.Name(), nil
+}
+
 func isCRDClusterScoped(info *markers.TypeInfo) bool {
 	resourceMarker := info.Markers.Get(isCRDMarker.Name)
 	if resourceMarker == nil {
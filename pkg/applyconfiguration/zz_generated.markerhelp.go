@@ -34,7 +34,11 @@ func (Generator) Help() *markers.DefinitionHelp {
 		FieldHelp: map[string]markers.DetailedHelp{
 			"HeaderFile": {
 				Summary: "specifies the header text (e.g. license) to prepend to generated files.",
-				Details: "",
+				Details: "See genall.Header for its format (including the template variables it may use).",
+			},
+			"Year": {
+				Summary: "specifies the year to use in the header file (as {{ .Year }}, or in place of \" YEAR\").",
+				Details: "Left unspecified, the current year is used.",
 			},
 			"ExternalApplyConfigurations": {
 				Summary: "provides mappings between external types and their applyconfiguration packages.",
@@ -38,7 +38,8 @@ limitations under the License.
 // NeedPackage.  Then, CRDs can be generated with NeedCRDFor.
 //
 // Errors are generally attached directly to the relevant Package with
-// AddError.
+// AddError.  Problems that don't stop generation, like list fields without a
+// list type, are reported to Parser.Warn as warnings, if it's set.
 //
 // # Known Packages
 //
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"go/ast"
 	"go/types"
+	"path"
 	"slices"
 	"strings"
 
@@ -80,11 +81,53 @@ type Generator struct {
 	GenerateEmbeddedObjectMeta *bool `marker:",optional"`
 
 	// HeaderFile specifies the header text (e.g. license) to prepend to generated files.
+	//
+	// See genall.Header for its format (including the template variables it may use).
 	HeaderFile string `marker:",optional"`
 
-	// Year specifies the year to substitute for " YEAR" in the header file.
+	// Year specifies the year to use in the header file (as {{ .Year }}, or in place of " YEAR").
 	Year string `marker:",optional"`
 
+	// FileName specifies the name of the file to write each CRD to, as a Go template.
+	//
+	// It can refer to {{ .Group }}, {{ .Version }} (the storage version), {{ .Kind }},
+	// {{ .Plural }}, and {{ .CRDVersion }} (the version of the CRD API), and can use
+	// {{ lower ... }} to lowercase them (e.g. `fileName="{{ lower .Kind }}.yaml"`, quoted
+	// since it contains braces).  Defaults to "{{ .Group }}_{{ .Plural }}.yaml", or
+	// "{{ .Group }}.yaml" with FilePerGroup.
+	FileName string `marker:",optional"`
+
+	// GroupDirectories nests the files for each API group in a directory named after the group.
+	GroupDirectories *bool `marker:",optional"`
+
+	// FilePerGroup writes all CRDs for each API group to a single file, as multiple documents.
+	//
+	// FileName can then only refer to {{ .Group }} and {{ .CRDVersion }}, and must
+	// refer to {{ .CRDVersion }} if there are several CRDVersions.
+	FilePerGroup *bool `marker:",optional"`
+
+	// Groups specifies the API groups to generate CRDs for, as globs (e.g. "*.example.com").
+	//
+	// Left unspecified, CRDs are generated for all groups.
+	Groups []string `marker:",optional"`
+
+	// Kinds specifies the kinds to generate CRDs for, as globs matching either
+	// the kind (e.g. "Foo*") or the kind and group (e.g. "Foo.bar.example.com").
+	//
+	// Left unspecified, CRDs are generated for all kinds.
+	Kinds []string `marker:",optional"`
+
+	// ExcludeGroups specifies API groups not to generate CRDs for, as globs.
+	//
+	// This takes precedence over Groups and Kinds.
+	ExcludeGroups []string `marker:",optional"`
+
+	// ExcludeKinds specifies kinds not to generate CRDs for, as globs matching
+	// either the kind or the kind and group.
+	//
+	// This takes precedence over Groups and Kinds.
+	ExcludeKinds []string `marker:",optional"`
+
 	// DeprecatedV1beta1CompatibilityPreserveUnknownFields indicates whether
 	// or not we should turn off field pruning for this resource.
 	//
@@ -134,6 +177,18 @@ func (g Generator) Generate(ctx *genall.GenerationContext) error {
 		GenerateEmbeddedObjectMeta: g.GenerateEmbeddedObjectMeta != nil && *g.GenerateEmbeddedObjectMeta,
 	}
 
+	roots := make(map[*loader.Package]struct{}, len(ctx.Roots))
+	for _, root := range ctx.Roots {
+		roots[root] = struct{}{}
+	}
+	parser.Warn = func(pkg *loader.Package, node ast.Node, code, message string) {
+		// types from outside the roots (e.g. dependencies) can't be fixed, so
+		// there's no point in warning about them.
+		if _, isRoot := roots[pkg]; isRoot {
+			ctx.Warn(pkg, node, code, message)
+		}
+	}
+
 	AddKnownTypes(parser)
 	for _, root := range ctx.Roots {
 		parser.NeedPackage(root)
@@ -145,8 +200,10 @@ func (g Generator) Generate(ctx *genall.GenerationContext) error {
 		return nil
 	}
 
-	// TODO: allow selecting a specific object
-	kubeKinds := FindKubeKinds(parser, metav1Pkg)
+	kubeKinds, err := g.selectKinds(parser, FindKubeKinds(parser, metav1Pkg))
+	if err != nil {
+		return err
+	}
 	if len(kubeKinds) == 0 {
 		// no objects in the roots
 		return nil
@@ -158,16 +215,14 @@ func (g Generator) Generate(ctx *genall.GenerationContext) error {
 		crdVersions = []string{defaultVersion}
 	}
 
-	var headerText string
-
-	if g.HeaderFile != "" {
-		headerBytes, err := ctx.ReadFile(g.HeaderFile)
-		if err != nil {
-			return err
-		}
-		headerText = string(headerBytes)
+	header, err := ctx.LoadHeader(g.HeaderFile, g.Year)
+	if err != nil {
+		return err
+	}
+	headerText, err := header.Render(genall.YAMLArtifact, nil)
+	if err != nil {
+		return err
 	}
-	headerText = strings.ReplaceAll(headerText, " YEAR", " "+g.Year)
 
 	yamlOpts := []*genall.WriteYAMLOptions{
 		genall.WithTransform(transformRemoveCRDStatus),
@@ -177,6 +232,15 @@ func (g Generator) Generate(ctx *genall.GenerationContext) error {
 		yamlOpts = append(yamlOpts, genall.WithTransform(transformPreserveUnknownFields(*g.DeprecatedV1beta1CompatibilityPreserveUnknownFields)))
 	}
 
+	layout, err := g.layout()
+	if err != nil {
+		return err
+	}
+	// files are written once all CRDs have been generated, in order, so that
+	// CRDs sharing a file (e.g. with FilePerGroup) are written together.
+	var files []*crdFile
+	filesByName := make(map[string]*crdFile)
+
 	for _, groupKind := range kubeKinds {
 		parser.NeedCRDFor(groupKind, g.MaxDescLen)
 		crdRaw := parser.CustomResourceDefinitions[groupKind]
@@ -196,21 +260,95 @@ func (g Generator) Generate(ctx *genall.GenerationContext) error {
 
 		for i, crd := range versionedCRDs {
 			removeDescriptionFromMetadata(crd.(*apiextensionsv1.CustomResourceDefinition))
-			var fileName string
-			if i == 0 {
-				fileName = fmt.Sprintf("%s_%s.yaml", crdRaw.Spec.Group, crdRaw.Spec.Names.Plural)
-			} else {
-				fileName = fmt.Sprintf("%s_%s.%s.yaml", crdRaw.Spec.Group, crdRaw.Spec.Names.Plural, crdVersions[i])
-			}
-			if err := ctx.WriteYAML(fileName, headerText, []any{crd}, yamlOpts...); err != nil {
+			fileName, err := layout.pathFor(crd.(*apiextensionsv1.CustomResourceDefinition), crdVersions[i], i == 0)
+			if err != nil {
 				return err
 			}
+
+			// different kinds can share a file when writing one file per
+			// group, but different versions of the same CRD never can.
+			file, exists := filesByName[fileName]
+			switch {
+			case !exists:
+				file = &crdFile{name: fileName}
+				filesByName[fileName] = file
+				files = append(files, file)
+			case slices.Contains(file.kinds, groupKind.String()):
+				return fmt.Errorf("CRDs for %s as different CRD versions would both be written to %s (consider referring to {{ .CRDVersion }} in the file name)", groupKind, fileName)
+			case !layout.perGroup:
+				return fmt.Errorf("CRDs for %s and %s would both be written to %s", file.kinds[0], groupKind, fileName)
+			}
+			file.crds = append(file.crds, crd)
+			file.kinds = append(file.kinds, groupKind.String())
+		}
+	}
+
+	for _, file := range files {
+		if err := ctx.WriteYAML(file.name, headerText, file.crds, yamlOpts...); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// selectKinds filters the given group-kinds down to the ones selected by the
+// Groups, Kinds, ExcludeGroups, and ExcludeKinds options, skipping any groups
+// that have opted out of CRD generation with the SkipCRDs package marker.
+func (g Generator) selectKinds(parser *Parser, kinds []schema.GroupKind) ([]schema.GroupKind, error) {
+	for _, patterns := range [][]string{g.Groups, g.Kinds, g.ExcludeGroups, g.ExcludeKinds} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid kind or group pattern %q: %w", pattern, err)
+			}
+		}
+	}
+
+	skippedGroups := make(map[string]struct{})
+	for pkg, gv := range parser.GroupVersions {
+		pkgMarkers, err := markers.PackageMarkers(parser.Collector, pkg)
+		if err != nil {
+			pkg.AddError(err)
+			continue
+		}
+		if pkgMarkers.Get(crdmarkers.SkipCRDsMarkerName) != nil {
+			skippedGroups[gv.Group] = struct{}{}
+		}
+	}
+
+	var selected []schema.GroupKind
+	for _, groupKind := range kinds {
+		if _, skipped := skippedGroups[groupKind.Group]; skipped {
+			continue
+		}
+		qualifiedKind := groupKind.Kind + "." + groupKind.Group
+		if len(g.Groups) > 0 && !matchesAny(g.Groups, groupKind.Group) {
+			continue
+		}
+		if len(g.Kinds) > 0 && !matchesAny(g.Kinds, groupKind.Kind, qualifiedKind) {
+			continue
+		}
+		if matchesAny(g.ExcludeGroups, groupKind.Group) || matchesAny(g.ExcludeKinds, groupKind.Kind, qualifiedKind) {
+			continue
+		}
+		selected = append(selected, groupKind)
+	}
+	return selected, nil
+}
+
+// matchesAny checks if any of the given values match any of the given glob
+// patterns, which must have already been validated.
+func matchesAny(patterns []string, values ...string) bool {
+	for _, pattern := range patterns {
+		for _, value := range values {
+			if matched, _ := path.Match(pattern, value); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func removeDescriptionFromMetadata(crd *apiextensionsv1.CustomResourceDefinition) {
 	for _, versionSpec := range crd.Spec.Versions {
 		if versionSpec.Schema != nil {
@@ -25,6 +25,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"golang.org/x/tools/go/packages"
 	"sigs.k8s.io/controller-tools/pkg/crd"
 	crdmarkers "sigs.k8s.io/controller-tools/pkg/crd/markers"
 	"sigs.k8s.io/controller-tools/pkg/genall"
@@ -169,6 +170,244 @@ var _ = Describe("CRD Generation proper defaulting", func() {
 		By("comparing the two")
 		Expect(out.buf.String()).To(Equal(string(expectedFile)), cmp.Diff(out.buf.String(), string(expectedFile)))
 	})
+
+	It("should only generate CRDs for the selected kinds", func() {
+		By("calling Generate with a kind selected")
+		gen := &crd.Generator{
+			CRDVersions: []string{"v1"},
+			Kinds:       []string{"Z*"},
+		}
+		Expect(gen.Generate(ctx2)).NotTo(HaveOccurred())
+
+		By("comparing against the selected kind's YAML")
+		expectedFile, err := os.ReadFile(filepath.Join(genDir, "zoo", "bar.example.com_zoos.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.buf.String()).To(Equal(string(expectedFile)), cmp.Diff(out.buf.String(), string(expectedFile)))
+	})
+
+	It("should select kinds qualified with their group", func() {
+		By("calling Generate with a qualified kind selected")
+		gen := &crd.Generator{
+			CRDVersions: []string{"v1"},
+			Kinds:       []string{"Foo.bar.example.com"},
+		}
+		Expect(gen.Generate(ctx2)).NotTo(HaveOccurred())
+
+		By("comparing against the selected kind's YAML")
+		expectedFile, err := os.ReadFile(filepath.Join(genDir, "bar.example.com_foos.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.buf.String()).To(Equal(string(expectedFile)), cmp.Diff(out.buf.String(), string(expectedFile)))
+	})
+
+	It("should only generate CRDs for the selected groups", func() {
+		By("calling Generate with a different group selected")
+		gen := &crd.Generator{
+			CRDVersions: []string{"v1"},
+			Groups:      []string{"*.k8s.io"},
+		}
+		Expect(gen.Generate(ctx2)).NotTo(HaveOccurred())
+		Expect(out.buf.String()).To(BeEmpty())
+
+		By("calling Generate with the group selected")
+		gen.Groups = []string{"*.example.com"}
+		Expect(gen.Generate(ctx2)).NotTo(HaveOccurred())
+		Expect(out.buf.String()).To(ContainSubstring("kind: Foo\n"))
+		Expect(out.buf.String()).To(ContainSubstring("kind: Zoo\n"))
+	})
+
+	It("should not generate CRDs for excluded kinds and groups, even when selected", func() {
+		By("calling Generate with a kind excluded")
+		gen := &crd.Generator{
+			CRDVersions:  []string{"v1"},
+			Groups:       []string{"bar.example.com"},
+			ExcludeKinds: []string{"Foo"},
+		}
+		Expect(gen.Generate(ctx2)).NotTo(HaveOccurred())
+		Expect(out.buf.String()).NotTo(ContainSubstring("kind: Foo\n"))
+		Expect(out.buf.String()).To(ContainSubstring("kind: Zoo\n"))
+
+		By("calling Generate with the group excluded")
+		out.buf.Reset()
+		gen.ExcludeGroups = []string{"bar.*"}
+		Expect(gen.Generate(ctx2)).NotTo(HaveOccurred())
+		Expect(out.buf.String()).To(BeEmpty())
+	})
+
+	It("should reject invalid patterns", func() {
+		gen := &crd.Generator{
+			CRDVersions: []string{"v1"},
+			Kinds:       []string{"Foo["},
+		}
+		Expect(gen.Generate(ctx2)).To(MatchError(ContainSubstring(`invalid kind or group pattern "Foo["`)))
+	})
+
+	It("should name files using the file name template, in per-group directories", func() {
+		By("calling Generate")
+		files := &filesOutputRule{}
+		ctx2.OutputRule = files
+		yes := true
+		gen := &crd.Generator{
+			CRDVersions:      []string{"v1"},
+			FileName:         "{{ lower .Kind }}-{{ .Version }}.yaml",
+			GroupDirectories: &yes,
+		}
+		Expect(gen.Generate(ctx2)).NotTo(HaveOccurred())
+
+		By("comparing the files against the desired YAMLs")
+		expectedFileFoos, err := os.ReadFile(filepath.Join(genDir, "bar.example.com_foos.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		expectedFileZoos, err := os.ReadFile(filepath.Join(genDir, "zoo", "bar.example.com_zoos.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files.Contents()).To(Equal(map[string]string{
+			"bar.example.com/foo-foo.yaml": string(expectedFileFoos),
+			"bar.example.com/zoo-zoo.yaml": string(expectedFileZoos),
+		}))
+	})
+
+	It("should write one file per group", func() {
+		By("calling Generate")
+		files := &filesOutputRule{}
+		ctx2.OutputRule = files
+		yes := true
+		gen := &crd.Generator{
+			CRDVersions:  []string{"v1"},
+			FilePerGroup: &yes,
+		}
+		Expect(gen.Generate(ctx2)).NotTo(HaveOccurred())
+
+		By("comparing the file against the desired YAMLs, as multiple documents")
+		expectedFileFoos, err := os.ReadFile(filepath.Join(genDir, "bar.example.com_foos.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		expectedFileZoos, err := os.ReadFile(filepath.Join(genDir, "zoo", "bar.example.com_zoos.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files.Contents()).To(Equal(map[string]string{
+			"bar.example.com.yaml": string(expectedFileFoos) + string(expectedFileZoos),
+		}))
+	})
+
+	It("should refuse to write multiple CRDs to the same file", func() {
+		gen := &crd.Generator{
+			CRDVersions: []string{"v1"},
+			FileName:    "{{ .Group }}.yaml",
+		}
+		Expect(gen.Generate(ctx2)).To(MatchError("CRDs for Foo.bar.example.com and Zoo.bar.example.com would both be written to bar.example.com.yaml"))
+	})
+
+	It("should refuse to write different CRD versions of the same CRD to the same file when writing one file per group", func() {
+		yes := true
+		gen := &crd.Generator{
+			CRDVersions:  []string{"v1", "v1"},
+			FileName:     "{{ .Group }}.yaml",
+			FilePerGroup: &yes,
+		}
+		Expect(gen.Generate(ctx2)).To(MatchError(HavePrefix("CRDs for Foo.bar.example.com as different CRD versions would both be written to bar.example.com.yaml")))
+	})
+
+	It("should refuse to write different CRD versions of the same CRD to the same file", func() {
+		gen := &crd.Generator{
+			CRDVersions: []string{"v1", "v1"},
+			FileName:    "{{ .Group }}_{{ .Plural }}.yaml",
+		}
+		Expect(gen.Generate(ctx)).To(MatchError(HavePrefix("CRDs for Foo.bar.example.com as different CRD versions would both be written to bar.example.com_foos.yaml")))
+	})
+
+	It("should refuse to refer to kinds in the file name template when writing one file per group", func() {
+		yes := true
+		gen := &crd.Generator{
+			CRDVersions:  []string{"v1"},
+			FileName:     "{{ .Kind }}.yaml",
+			FilePerGroup: &yes,
+		}
+		Expect(gen.Generate(ctx2)).To(MatchError(ContainSubstring(`map has no entry for key "Kind"`)))
+	})
+})
+
+var _ = Describe("CRD Generation with groups opted out", func() {
+	It("should not generate CRDs for groups marked with kubebuilder:skipcrds, but still use their types", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(filepath.Join("testdata", "skipcrds"))).To(Succeed())
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		By("loading the roots")
+		pkgs, err := loader.LoadRoots("./...")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkgs).To(HaveLen(2))
+
+		By("calling Generate")
+		reg := &markers.Registry{}
+		Expect(crdmarkers.Register(reg)).To(Succeed())
+		out := &outputRule{buf: &bytes.Buffer{}}
+		ctx := &genall.GenerationContext{
+			Collector:  &markers.Collector{Registry: reg},
+			Roots:      pkgs,
+			Checker:    &loader.TypeChecker{},
+			OutputRule: out,
+		}
+		gen := &crd.Generator{CRDVersions: []string{"v1"}}
+		Expect(gen.Generate(ctx)).NotTo(HaveOccurred())
+		for _, pkg := range pkgs {
+			Expect(pkg.Errors).To(BeEmpty())
+		}
+
+		By("checking that only the kept group's CRD was generated")
+		Expect(out.buf.String()).To(ContainSubstring("name: kepts.kept.example.com\n"))
+		Expect(out.buf.String()).To(ContainSubstring("value:\n"))
+		Expect(out.buf.String()).NotTo(ContainSubstring("skipped.example.com"))
+	})
+})
+
+var _ = Describe("CRD Generation warnings", func() {
+	var diags []genall.Diagnostic
+
+	BeforeEach(func() {
+		By("loading the roots")
+		gens := genall.Generators{new(genall.Generator)}
+		*gens[0] = crd.Generator{}
+		rt, err := gens.ForRootsWithConfig(&packages.Config{Dir: "testdata"}, "./warnings")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("running the generator")
+		rt.Diagnostics = &genall.Diagnostics{}
+		Expect(rt.Run()).To(BeFalse())
+		diags = rt.Diagnostics.Items()
+	})
+
+	warningsWithCode := func(code string) []genall.Diagnostic {
+		var res []genall.Diagnostic
+		for _, diag := range diags {
+			Expect(diag.Severity).To(Equal(genall.SeverityWarning), diag.Message)
+			if diag.Code == code {
+				res = append(res, diag)
+			}
+		}
+		return res
+	}
+
+	It("should warn about list fields without a list type, unless suppressed", func() {
+		Expect(warningsWithCode(crd.MissingListTypeCode)).To(ConsistOf(And(
+			HaveField("Filename", HaveSuffix(filepath.Join("warnings", "types.go"))),
+			HaveField("Line", 42),
+			HaveField("Column", 2),
+			HaveField("Message", ContainSubstring("WarningSpec.Untyped")),
+		)))
+	})
+
+	It("should warn about unbounded strings used in CEL rules, unless suppressed", func() {
+		Expect(warningsWithCode(crd.UnboundedCELStringCode)).To(ConsistOf(
+			And(
+				HaveField("Line", 41),
+				HaveField("Column", 6),
+				HaveField("Message", ContainSubstring(`"parent"`)),
+			),
+			And(
+				HaveField("Line", 51),
+				HaveField("Column", 2),
+				HaveField("Message", ContainSubstring(`self.matches`)),
+			),
+		))
+	})
 })
 
 type outputRule struct {
@@ -179,6 +418,27 @@ func (o *outputRule) Open(_ *loader.Package, _ string) (io.WriteCloser, error) {
 	return nopCloser{o.buf}, nil
 }
 
+// filesOutputRule records the contents of each file written.
+type filesOutputRule struct {
+	files map[string]*bytes.Buffer
+}
+
+func (o *filesOutputRule) Open(_ *loader.Package, itemPath string) (io.WriteCloser, error) {
+	if o.files == nil {
+		o.files = make(map[string]*bytes.Buffer)
+	}
+	o.files[itemPath] = &bytes.Buffer{}
+	return nopCloser{o.files[itemPath]}, nil
+}
+
+func (o *filesOutputRule) Contents() map[string]string {
+	out := make(map[string]string, len(o.files))
+	for name, buf := range o.files {
+		out[name] = buf.String()
+	}
+	return out
+}
+
 type nopCloser struct {
 	io.Writer
 }
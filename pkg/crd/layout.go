@@ -0,0 +1,120 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crd
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+const (
+	// defaultFileName is the file name template used for each CRD when no
+	// FileName is given.
+	defaultFileName = "{{ .Group }}_{{ .Plural }}.yaml"
+	// defaultGroupFileName is the file name template used for each group when
+	// no FileName is given and FilePerGroup is set.
+	defaultGroupFileName = "{{ .Group }}.yaml"
+)
+
+// crdLayout decides which file each generated CRD is written to.
+type crdLayout struct {
+	fileName     *template.Template
+	perGroup     bool
+	groupDirs    bool
+	defaultNames bool
+}
+
+// crdFile is a file of one or more CRDs.
+type crdFile struct {
+	name  string
+	crds  []any
+	kinds []string
+}
+
+// layout returns the crdLayout described by the Generator's options.
+func (g Generator) layout() (*crdLayout, error) {
+	layout := &crdLayout{
+		perGroup:  g.FilePerGroup != nil && *g.FilePerGroup,
+		groupDirs: g.GroupDirectories != nil && *g.GroupDirectories,
+	}
+
+	fileName := g.FileName
+	if fileName == "" {
+		layout.defaultNames = true
+		fileName = defaultFileName
+		if layout.perGroup {
+			fileName = defaultGroupFileName
+		}
+	}
+
+	var err error
+	layout.fileName, err = template.New("fileName").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"lower": strings.ToLower}).
+		Parse(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CRD file name template %q: %w", fileName, err)
+	}
+	return layout, nil
+}
+
+// pathFor returns the path of the file that the given CRD, as the given
+// version of the CRD API, should be written to.  Non-default CRD API versions
+// get a version suffix when using the default file names.
+func (l *crdLayout) pathFor(crd *apiextensionsv1.CustomResourceDefinition, crdVersion string, isDefaultVersion bool) (string, error) {
+	data := map[string]string{
+		"Group":      crd.Spec.Group,
+		"CRDVersion": crdVersion,
+	}
+	if !l.perGroup {
+		data["Kind"] = crd.Spec.Names.Kind
+		data["Plural"] = crd.Spec.Names.Plural
+		data["Version"] = storageVersion(crd)
+	}
+
+	var out bytes.Buffer
+	if err := l.fileName.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("unable to render CRD file name for %s: %w", crd.Name, err)
+	}
+	fileName := out.String()
+	if l.defaultNames && !isDefaultVersion {
+		fileName = strings.TrimSuffix(fileName, ".yaml") + "." + crdVersion + ".yaml"
+	}
+	if l.groupDirs {
+		fileName = path.Join(crd.Spec.Group, fileName)
+	}
+	return fileName, nil
+}
+
+// storageVersion returns the storage version of the given CRD, or its first
+// version if none is marked as the storage version.
+func storageVersion(crd *apiextensionsv1.CustomResourceDefinition) string {
+	for _, ver := range crd.Spec.Versions {
+		if ver.Storage {
+			return ver.Name
+		}
+	}
+	if len(crd.Spec.Versions) > 0 {
+		return crd.Spec.Versions[0].Name
+	}
+	return ""
+}
@@ -20,6 +20,10 @@ import (
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
 
+// SkipCRDsMarkerName is the name of the package marker that opts the
+// package's API group out of CRD generation.
+const SkipCRDsMarkerName = "kubebuilder:skipcrds"
+
 func init() {
 	AllDefinitions = append(AllDefinitions,
 		mustOptional(markers.MakeDefinition("groupName", markers.DescribesPackage, "")).
@@ -36,5 +40,8 @@ func init() {
 
 		must(markers.MakeDefinition("kubebuilder:skip", markers.DescribesPackage, struct{}{})).
 			WithHelp(markers.SimpleHelp("CRD", "don't consider this package as an API version. Use this to exclude internal or helper packages from CRD generation.")),
+
+		must(markers.MakeDefinition(SkipCRDsMarkerName, markers.DescribesPackage, struct{}{})).
+			WithHelp(markers.SimpleHelp("CRD", "don't generate CRDs for any kinds in this package's API group. Unlike kubebuilder:skip, its types can still be used by other packages.")),
 	)
 }
@@ -26,6 +26,9 @@ import (
 type definitionWithHelp struct {
 	*markers.Definition
 	Help *markers.DefinitionHelp
+	// Migration, if set, marks the definition as deprecated, and migrates
+	// it to its replacement.
+	Migration markers.Migration
 }
 
 func (d *definitionWithHelp) WithHelp(help *markers.DefinitionHelp) *definitionWithHelp {
@@ -40,6 +43,9 @@ func (d *definitionWithHelp) Register(reg *markers.Registry) error {
 	if d.Help != nil {
 		reg.AddHelp(d.Definition, d.Help)
 	}
+	if d.Migration != nil {
+		reg.AddMigration(d.Definition, d.Migration)
+	}
 	return nil
 }
 
@@ -48,6 +54,7 @@ func (d *definitionWithHelp) clone() *definitionWithHelp {
 	return &definitionWithHelp{
 		Definition: &newDef,
 		Help:       &newHelp,
+		Migration:  d.Migration,
 	}
 }
 
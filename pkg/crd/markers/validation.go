@@ -179,6 +179,20 @@ func init() {
 	AllDefinitions = append(AllDefinitions, FieldOnlyMarkers...)
 	AllDefinitions = append(AllDefinitions, TypeOnlyMarkers...)
 	AllDefinitions = append(AllDefinitions, ValidationIshMarkers...)
+
+	for _, def := range AllDefinitions {
+		replacement, deprecated := renamedMarkers[def.Name]
+		if !deprecated {
+			continue
+		}
+		def.Migration = markers.RenameTo(replacement)
+		def.Help.DeprecatedInFavorOf = &replacement
+	}
+}
+
+// renamedMarkers maps deprecated markers to the markers that replace them.
+var renamedMarkers = map[string]string{
+	validationPrefix + "XPreserveUnknownFields": "kubebuilder:pruning:PreserveUnknownFields",
 }
 
 // Maximum specifies the maximum numeric value that this field can have.
@@ -18,10 +18,12 @@ package crd
 
 import (
 	"fmt"
+	"go/ast"
 
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-tools/pkg/internal/crd"
+	"sigs.k8s.io/controller-tools/pkg/internal/profile"
 	"sigs.k8s.io/controller-tools/pkg/loader"
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
@@ -92,6 +94,11 @@ type Parser struct {
 
 	// GenerateEmbeddedObjectMeta specifies if any embedded ObjectMeta should be generated
 	GenerateEmbeddedObjectMeta bool
+
+	// Warn, if set, is called to report problems with types that don't stop
+	// their schemata from being generated, such as list fields without a list
+	// type (see genall.GenerationContext.Warn for the arguments).
+	Warn func(pkg *loader.Package, node ast.Node, code, message string)
 }
 
 func (p *Parser) init() {
@@ -173,6 +180,7 @@ func (p *Parser) NeedSchemaFor(typ TypeIdent) {
 	p.Schemata[typ] = apiextensionsv1.JSONSchemaProps{}
 
 	schemaCtx := newSchemaContext(typ.Package, p, p.AllowDangerousTypes, p.IgnoreUnexportedFields)
+	schemaCtx.warn = p.Warn
 	ctxForInfo := schemaCtx.ForInfo(info)
 
 	pkgMarkers, err := markers.PackageMarkers(p.Collector, typ.Package)
@@ -194,8 +202,10 @@ func (p *Parser) NeedFlattenedSchemaFor(typ TypeIdent) {
 	}
 
 	p.NeedSchemaFor(typ)
+	endFlatten := profile.Start(profile.Flatten)
 	partialFlattened := p.flattener.FlattenType(typ)
 	fullyFlattened := FlattenEmbedded(partialFlattened, typ.Package)
+	endFlatten()
 
 	p.FlattenedSchemata[typ] = *fullyFlattened
 }
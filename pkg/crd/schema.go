@@ -22,6 +22,7 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
+	"regexp"
 	"slices"
 	"strings"
 
@@ -40,6 +41,16 @@ const (
 	defPrefix = "#/definitions/"
 )
 
+const (
+	// MissingListTypeCode is the code of warnings about list fields without a
+	// +listType marker, which server-side apply then treats as atomic.
+	MissingListTypeCode = "missing-list-type"
+	// UnboundedCELStringCode is the code of warnings about strings without a
+	// maximum length that are used in CEL validation rules, whose cost the API
+	// server then can't estimate well (often rejecting the rule as too costly).
+	UnboundedCELStringCode = "unbounded-cel-string"
+)
+
 // byteType is the types.Type for byte (see the types documention
 // for why we need to look this up in the Universe), saved
 // for quick comparison.
@@ -73,6 +84,9 @@ type schemaContext struct {
 
 	allowDangerousTypes    bool
 	ignoreUnexportedFields bool
+
+	// warn reports warnings, if set (see Parser.Warn).
+	warn func(pkg *loader.Package, node ast.Node, code, message string)
 }
 
 // newSchemaContext constructs a new schemaContext for the given package and schema requester.
@@ -96,7 +110,17 @@ func (c *schemaContext) ForInfo(info *markers.TypeInfo) *schemaContext {
 		schemaRequester:        c.schemaRequester,
 		allowDangerousTypes:    c.allowDangerousTypes,
 		ignoreUnexportedFields: c.ignoreUnexportedFields,
+		warn:                   c.warn,
+	}
+}
+
+// warnf reports a warning with the given code about the given node, if
+// warnings were asked for.
+func (c *schemaContext) warnf(node ast.Node, code, format string, args ...any) {
+	if c.warn == nil {
+		return
 	}
+	c.warn(c.pkg, node, code, fmt.Sprintf(format, args...))
 }
 
 // requestSchema asks for the schema for a type in the package with the
@@ -275,11 +299,62 @@ func typeToSchema(ctx *schemaContext, rawType ast.Expr) *apiextensionsv1.JSONSch
 
 	props.Description = ctx.info.Doc
 
+	rulesBefore := len(props.XValidations)
 	applyMarkers(ctx, ctx.info.Markers, props, rawType)
+	if ctx.info.RawSpec != nil {
+		warnUnboundedCELStrings(ctx, props.XValidations[rulesBefore:], props, ctx.info.RawSpec)
+	}
 
 	return props
 }
 
+// warnUnboundedCELStrings warns about the given schema, or its properties,
+// being a string without a maximum length that's used in the given rules.
+func warnUnboundedCELStrings(ctx *schemaContext, rules []apiextensionsv1.ValidationRule, props *apiextensionsv1.JSONSchemaProps, node ast.Node) {
+	if ctx.warn == nil || len(rules) == 0 {
+		return
+	}
+	if isUnboundedString(props) {
+		for _, rule := range rules {
+			ctx.warnf(node, UnboundedCELStringCode, "string without a maximum length is used in the CEL rule %q (consider adding +kubebuilder:validation:MaxLength)", rule.Rule)
+		}
+		return
+	}
+
+	propNames := make([]string, 0, len(props.Properties))
+	for propName := range props.Properties {
+		propNames = append(propNames, propName)
+	}
+	slices.Sort(propNames)
+	for _, propName := range propNames {
+		prop := props.Properties[propName]
+		if !isUnboundedString(&prop) {
+			continue
+		}
+		propRef := regexp.MustCompile(`\bself\.\??` + regexp.QuoteMeta(propName) + `\b`)
+		for _, rule := range rules {
+			if propRef.MatchString(rule.Rule) {
+				ctx.warnf(node, UnboundedCELStringCode, "string field %q without a maximum length is used in the CEL rule %q (consider adding +kubebuilder:validation:MaxLength to it)", propName, rule.Rule)
+			}
+		}
+	}
+}
+
+// isUnboundedString checks if the given schema is for a string of unbounded
+// length.  References are assumed to be bounded, since their bounds aren't
+// known until they're flattened.
+func isUnboundedString(props *apiextensionsv1.JSONSchemaProps) bool {
+	if props.Type != "string" || props.Ref != nil || props.MaxLength != nil || len(props.Enum) > 0 {
+		return false
+	}
+	switch props.Format {
+	case "date", "date-time", "duration":
+		// the API server knows the maximum length of these.
+		return false
+	}
+	return true
+}
+
 // qualifiedName constructs a JSONSchema-safe qualified name for a type
 // (`<typeName>` or `<safePkgPath>~0<typeName>`, where `<safePkgPath>`
 // is the package path with `/` replaced by `~1`, according to JSONPointer
@@ -600,13 +675,19 @@ func structToSchema(ctx *schemaContext, structType *ast.StructType) *apiextensio
 		}
 		propSchema.Description = field.Doc
 
+		rulesBefore := len(propSchema.XValidations)
 		applyMarkers(ctx, field.Markers, propSchema, field.RawField)
+		warnUnboundedCELStrings(ctx, propSchema.XValidations[rulesBefore:], propSchema, field.RawField)
 
 		if inline {
 			props.AllOf = append(props.AllOf, *propSchema)
 			continue
 		}
 
+		if propSchema.Type == "array" && propSchema.XListType == nil {
+			ctx.warnf(field.RawField, MissingListTypeCode, "list field %s.%s has no +listType marker, so server-side apply treats it as atomic", ctx.info.Name, field.Name)
+		}
+
 		if field.Markers.Get("k8s:immutable") != nil {
 			immutableFields = append(immutableFields, fieldName)
 		}
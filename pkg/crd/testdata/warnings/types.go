@@ -0,0 +1,65 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +groupName=testdata.kubebuilder.io
+// +versionName=v1
+package warnings
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+type Warning struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec WarningSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+type WarningList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Warning `json:"items"`
+}
+
+// +kubebuilder:validation:XValidation:rule="self.parent.startsWith(self.child)"
+type WarningSpec struct {
+	Untyped []string `json:"untyped"`
+
+	// +listType=set
+	Typed []string `json:"typed"`
+
+	// +kubebuilder:nolint:missing-list-type
+	UntypedSuppressed []string `json:"untypedSuppressed"`
+
+	// +kubebuilder:validation:XValidation:rule="self.matches('^[a-z]+$')"
+	Unbounded string `json:"unbounded"`
+
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:XValidation:rule="self.matches('^[a-z]+$')"
+	Bounded string `json:"bounded"`
+
+	// +kubebuilder:nolint:unbounded-cel-string
+	// +kubebuilder:validation:XValidation:rule="self.matches('^[a-z]+$')"
+	UnboundedSuppressed string `json:"unboundedSuppressed"`
+
+	Parent string `json:"parent"`
+
+	// +kubebuilder:validation:MaxLength=63
+	Child string `json:"child"`
+}
@@ -54,12 +54,40 @@ func (Generator) Help() *markers.DefinitionHelp {
 			},
 			"HeaderFile": {
 				Summary: "specifies the header text (e.g. license) to prepend to generated files.",
-				Details: "",
+				Details: "See genall.Header for its format (including the template variables it may use).",
 			},
 			"Year": {
-				Summary: "specifies the year to substitute for \" YEAR\" in the header file.",
+				Summary: "specifies the year to use in the header file (as {{ .Year }}, or in place of \" YEAR\").",
 				Details: "",
 			},
+			"FileName": {
+				Summary: "specifies the name of the file to write each CRD to, as a Go template.",
+				Details: "It can refer to {{ .Group }}, {{ .Version }} (the storage version), {{ .Kind }},\n{{ .Plural }}, and {{ .CRDVersion }} (the version of the CRD API), and can use\n{{ lower ... }} to lowercase them (e.g. `fileName=\"{{ lower .Kind }}.yaml\"`, quoted\nsince it contains braces).  Defaults to \"{{ .Group }}_{{ .Plural }}.yaml\", or\n\"{{ .Group }}.yaml\" with FilePerGroup.",
+			},
+			"GroupDirectories": {
+				Summary: "nests the files for each API group in a directory named after the group.",
+				Details: "",
+			},
+			"FilePerGroup": {
+				Summary: "writes all CRDs for each API group to a single file, as multiple documents.",
+				Details: "FileName can then only refer to {{ .Group }} and {{ .CRDVersion }}, and must\nrefer to {{ .CRDVersion }} if there are several CRDVersions.",
+			},
+			"Groups": {
+				Summary: "specifies the API groups to generate CRDs for, as globs (e.g. \"*.example.com\").",
+				Details: "Left unspecified, CRDs are generated for all groups.",
+			},
+			"Kinds": {
+				Summary: "specifies the kinds to generate CRDs for, as globs matching either",
+				Details: "the kind (e.g. \"Foo*\") or the kind and group (e.g. \"Foo.bar.example.com\").\n\nLeft unspecified, CRDs are generated for all kinds.",
+			},
+			"ExcludeGroups": {
+				Summary: "specifies API groups not to generate CRDs for, as globs.",
+				Details: "This takes precedence over Groups and Kinds.",
+			},
+			"ExcludeKinds": {
+				Summary: "specifies kinds not to generate CRDs for, as globs matching",
+				Details: "either the kind or the kind and group.\n\nThis takes precedence over Groups and Kinds.",
+			},
 			"DeprecatedV1beta1CompatibilityPreserveUnknownFields": {
 				Summary: "indicates whether",
 				Details: "or not we should turn off field pruning for this resource.\n\nSpecifies spec.preserveUnknownFields value that is false and omitted by default.\nThis value can only be specified for CustomResourceDefinitions that were created with\n`apiextensions.k8s.io/v1beta1`.\n\nThe field can be set for compatibility reasons, although strongly discouraged, resource\nauthors should move to a structural OpenAPI schema instead.\n\nSee https://kubernetes.io/docs/tasks/extend-kubernetes/custom-resources/custom-resource-definitions/#field-pruning\nfor more information about field pruning and v1beta1 resources compatibility.",
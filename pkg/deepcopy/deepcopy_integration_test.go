@@ -18,10 +18,8 @@ package deepcopy_test
 
 import (
 	"fmt"
-	"io"
 	"os"
 	"path"
-	"slices"
 
 	"github.com/google/go-cmp/cmp"
 	. "github.com/onsi/ginkgo/v2"
@@ -29,42 +27,9 @@ import (
 	"sigs.k8s.io/controller-tools/pkg/crd"
 	"sigs.k8s.io/controller-tools/pkg/deepcopy"
 	"sigs.k8s.io/controller-tools/pkg/genall"
-	"sigs.k8s.io/controller-tools/pkg/loader"
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
 
-type outputToMap map[string]*outputFile
-
-// Open implements genall.OutputRule.
-func (m outputToMap) Open(_ *loader.Package, path string) (io.WriteCloser, error) {
-	if _, ok := m[path]; !ok {
-		m[path] = &outputFile{}
-	}
-	return m[path], nil
-}
-
-func (m outputToMap) fileList() []string {
-	ret := make([]string, 0, len(m))
-	for path := range m {
-		ret = append(ret, path)
-	}
-	slices.Sort(ret)
-	return ret
-}
-
-type outputFile struct {
-	contents []byte
-}
-
-func (o *outputFile) Write(p []byte) (int, error) {
-	o.contents = append(o.contents, p...)
-	return len(p), nil
-}
-
-func (o *outputFile) Close() error {
-	return nil
-}
-
 var _ = Describe("CRD Generation From Parsing to CustomResourceDefinition", func() {
 	It("should properly generate and flatten the rewritten CronJob schema", func() {
 		By("switching into testdata to appease go modules")
@@ -73,8 +38,6 @@ var _ = Describe("CRD Generation From Parsing to CustomResourceDefinition", func
 		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
 		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
 
-		output := make(outputToMap)
-
 		By("initializing the runtime")
 		optionsRegistry := &markers.Registry{}
 		Expect(optionsRegistry.Register(markers.Must(markers.MakeDefinition("crd", markers.DescribesPackage, crd.Generator{})))).To(Succeed())
@@ -84,16 +47,15 @@ var _ = Describe("CRD Generation From Parsing to CustomResourceDefinition", func
 			fmt.Sprintf("object:headerFile=%s", path.Join(cwd, "../../hack/boilerplate/boilerplate.generatego.txt")),
 		})
 		Expect(err).NotTo(HaveOccurred())
-		rt.OutputRules = genall.OutputRules{Default: output}
 
 		By("running the generator and checking for errors")
-		hadErrs := rt.Run()
+		artifacts, genErr := rt.Generate()
 
 		By("checking that we got output contents")
-		Expect(output.fileList()).To(ContainElement("zz_generated.deepcopy.go")) // Don't use HaveKey--the output is too verbose to be usable
-		outFile := output["zz_generated.deepcopy.go"]
+		Expect(artifacts.ForGenerator("object").Paths()).To(ContainElement("zz_generated.deepcopy.go"))
+		outFile := artifacts.Get("object", rt.Roots[0], "zz_generated.deepcopy.go")
 		Expect(outFile).NotTo(BeNil())
-		outContents := outFile.contents
+		outContents := outFile.Contents
 		Expect(outContents).NotTo(BeNil())
 
 		By("loading the desired code")
@@ -104,6 +66,42 @@ var _ = Describe("CRD Generation From Parsing to CustomResourceDefinition", func
 		Expect(string(outContents)).To(Equal(string(expectedFile)), "generated code not as expected, check pkg/deepcopy/testdata/README.md for more details.\n\nDiff:\n\n%s", cmp.Diff(outContents, expectedFile))
 
 		By("checking for errors")
-		Expect(hadErrs).To(BeFalse())
+		Expect(genErr).NotTo(HaveOccurred())
+	})
+
+	It("should generate the same code when running generators in parallel", func() {
+		By("switching into testdata to appease go modules")
+		cwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir("./testdata")).To(Succeed()) // go modules are directory-sensitive
+		defer func() { Expect(os.Chdir(cwd)).To(Succeed()) }()
+
+		output := &genall.OutputToMemory{}
+
+		By("initializing the runtime")
+		optionsRegistry := &markers.Registry{}
+		Expect(optionsRegistry.Register(markers.Must(markers.MakeDefinition("crd", markers.DescribesPackage, crd.Generator{})))).To(Succeed())
+		Expect(optionsRegistry.Register(markers.Must(markers.MakeDefinition("object", markers.DescribesPackage, deepcopy.Generator{})))).To(Succeed())
+		rt, err := genall.FromOptions(optionsRegistry, []string{
+			"crd",
+			fmt.Sprintf("object:headerFile=%s", path.Join(cwd, "../../hack/boilerplate/boilerplate.generatego.txt")),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		crdOutput := &genall.OutputToMemory{}
+		rt.OutputRules = genall.OutputRules{
+			Default:     output,
+			ByGenerator: map[*genall.Generator]genall.OutputRule{rt.Generators[0]: crdOutput},
+		}
+		rt.Parallelism = 2
+
+		By("running the generators and checking for errors")
+		Expect(rt.Run()).To(BeFalse())
+
+		By("comparing the generated code with the desired code")
+		Expect(output.Artifacts().Paths()).To(ConsistOf("zz_generated.deepcopy.go"))
+		expectedFile, err := os.ReadFile("zz_generated.deepcopy.go")
+		Expect(err).NotTo(HaveOccurred())
+		outContents := output.Artifacts()[0].Contents
+		Expect(string(outContents)).To(Equal(string(expectedFile)), "generated code not as expected, check pkg/deepcopy/testdata/README.md for more details.\n\nDiff:\n\n%s", cmp.Diff(outContents, expectedFile))
 	})
 })
@@ -53,8 +53,10 @@ var (
 // DeepCopyObject method implementations.
 type Generator struct {
 	// HeaderFile specifies the header text (e.g. license) to prepend to generated files.
+	//
+	// See genall.Header for its format (including the template variables it may use).
 	HeaderFile string `marker:",optional"`
-	// Year specifies the year to substitute for " YEAR" in the header file.
+	// Year specifies the year to use in the header file (as {{ .Year }}, or in place of " YEAR").
 	Year string `marker:",optional"`
 }
 
@@ -85,9 +87,44 @@ func (Generator) RegisterMarkers(into *markers.Registry) error {
 		markers.DeprecatedHelp(enableTypeMarker.Name, "object", "overrides enabling or disabling deepcopy generation for this type"))
 	into.AddHelp(legacyIsObjectMarker,
 		markers.DeprecatedHelp(isObjectMarker.Name, "object", "enables object interface implementation generation for this type"))
+
+	into.AddMigration(legacyEnablePkgMarker, migrateLegacyEnable(func(args string) bool {
+		return strings.Split(args, ",")[0] == "package"
+	}))
+	into.AddMigration(legacyEnableTypeMarker, migrateLegacyEnable(func(args string) bool {
+		return args == "true"
+	}))
+	into.AddMigration(legacyIsObjectMarker, migrateLegacyIsObject)
 	return nil
 }
 
+// migrateLegacyEnable migrates the legacy deepcopy-gen enable markers to
+// +kubebuilder:object:generate, using the given function to work out
+// whether their arguments enable generation, as per enabledOnPackage and
+// enabledOnType.
+func migrateLegacyEnable(enabled func(args string) bool) markers.Migration {
+	return func(def *markers.Definition, marker string) ([]string, error) {
+		args, err := def.Parse(marker)
+		if err != nil {
+			return nil, err
+		}
+		return []string{fmt.Sprintf("+%s=%t", enablePkgMarker.Name, enabled(string(args.(markers.RawArguments))))}, nil
+	}
+}
+
+// migrateLegacyIsObject migrates the legacy deepcopy-gen interfaces marker
+// to +kubebuilder:object:root, as per genObjectInterface.
+func migrateLegacyIsObject(def *markers.Definition, marker string) ([]string, error) {
+	iface, err := def.Parse(marker)
+	if err != nil {
+		return nil, err
+	}
+	if iface != runtimeObjPath {
+		return nil, fmt.Errorf("only %s is supported, and has an equivalent marker", runtimeObjPath)
+	}
+	return []string{"+" + isObjectMarker.Name + "=true"}, nil
+}
+
 func enabledOnPackage(col *markers.Collector, pkg *loader.Package) (bool, error) {
 	pkgMarkers, err := markers.PackageMarkers(col, pkg)
 	if err != nil {
@@ -134,21 +171,15 @@ func genObjectInterface(info *markers.TypeInfo) bool {
 }
 
 func (d Generator) Generate(ctx *genall.GenerationContext) error {
-	var headerText string
-
-	if d.HeaderFile != "" {
-		headerBytes, err := ctx.ReadFile(d.HeaderFile)
-		if err != nil {
-			return err
-		}
-		headerText = string(headerBytes)
+	header, err := ctx.LoadHeader(d.HeaderFile, d.Year)
+	if err != nil {
+		return err
 	}
-	headerText = strings.ReplaceAll(headerText, " YEAR", " "+d.Year)
 
 	objGenCtx := ObjectGenCtx{
-		Collector:  ctx.Collector,
-		Checker:    ctx.Checker,
-		HeaderText: headerText,
+		Collector: ctx.Collector,
+		Checker:   ctx.Checker,
+		Header:    header,
 	}
 
 	for _, root := range ctx.Roots {
@@ -170,6 +201,9 @@ type ObjectGenCtx struct {
 	Collector  *markers.Collector
 	Checker    *loader.TypeChecker
 	HeaderText string
+
+	// Header, if set, is rendered for each package in place of HeaderText.
+	Header *genall.Header
 }
 
 // writeHeader writes out the build tag, package declaration, and imports
@@ -251,8 +285,17 @@ func (ctx *ObjectGenCtx) generateForPackage(root *loader.Package) []byte {
 		return nil
 	}
 
+	headerText := ctx.HeaderText
+	if ctx.Header != nil {
+		headerText, err = ctx.Header.Render(genall.GoArtifact, root)
+		if err != nil {
+			root.AddError(err)
+			return nil
+		}
+	}
+
 	outContent := new(bytes.Buffer)
-	writeHeader(root, outContent, root.Name, imports, ctx.HeaderText)
+	writeHeader(root, outContent, root.Name, imports, headerText)
 	writeMethods(root, outContent, byType)
 
 	outBytes := outContent.Bytes()
@@ -0,0 +1,114 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"sigs.k8s.io/controller-tools/pkg/internal/diff"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// OutputChecker compares artifacts against the files already on disk,
+// instead of writing them, for verifying that generated files are up to date
+// (e.g. in CI).
+//
+// Call WrapOutputRules on a Runtime before running it, then call Report.
+// Only FileOutputRules are checked -- other rules (like stdout) are left as-is.
+type OutputChecker struct {
+	artifactTracker
+}
+
+// WrapOutputRules replaces the given runtime's output rules, such that each
+// FileOutputRule records artifacts for comparison instead of writing them.
+func (c *OutputChecker) WrapOutputRules(rt *Runtime) error {
+	return wrapFileRules(rt, &c.artifactTracker, func(rule FileOutputRule) FileOutputRule {
+		return checkOutput{rule: rule, checker: c}
+	})
+}
+
+// Report writes a unified diff to out for each artifact whose contents
+// differ from the file on disk, including generated files that would no
+// longer be produced.  It returns true if anything differed.
+func (c *OutputChecker) Report(out io.Writer) (bool, error) {
+	stale := false
+	for _, path := range c.producedPaths() {
+		c.mu.Lock()
+		generated := c.produced[path]
+		c.mu.Unlock()
+
+		name := displayPath(path)
+		fromName := name
+		existing, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			fromName = os.DevNull
+		} else if err != nil {
+			return stale, err
+		}
+
+		if fileDiff := diff.Unified(fromName, name, existing, generated); fileDiff != "" {
+			stale = true
+			if _, err := io.WriteString(out, fileDiff); err != nil {
+				return stale, err
+			}
+		}
+	}
+
+	orphans, err := c.orphans()
+	if err != nil {
+		return stale, err
+	}
+	for _, path := range orphans {
+		existing, err := os.ReadFile(path)
+		if err != nil {
+			return stale, err
+		}
+		stale = true
+		if _, err := io.WriteString(out, diff.Unified(displayPath(path), os.DevNull, existing, nil)); err != nil {
+			return stale, err
+		}
+	}
+
+	return stale, nil
+}
+
+// checkOutput buffers artifacts for an OutputChecker instead of writing them.
+type checkOutput struct {
+	rule    FileOutputRule
+	checker *OutputChecker
+}
+
+func (o checkOutput) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	path, err := o.rule.PathFor(pkg, itemPath)
+	if err != nil {
+		return nil, err
+	}
+	return &bufferedArtifact{onClose: func(contents []byte) error {
+		if err := o.checker.produce(path, contents); err != nil {
+			return fmt.Errorf("unable to check %s: %w", path, err)
+		}
+		return nil
+	}}, nil
+}
+
+func (o checkOutput) PathFor(pkg *loader.Package, itemPath string) (string, error) {
+	return o.rule.PathFor(pkg, itemPath)
+}
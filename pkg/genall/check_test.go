@@ -0,0 +1,94 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+)
+
+const generatedYAML = `---
+metadata:
+  annotations:
+    controller-gen.kubebuilder.io/version: (devel)
+`
+
+var _ = Describe("OutputChecker", func() {
+	var outDir string
+	var gen fakeGenerator
+
+	BeforeEach(func() {
+		outDir = GinkgoT().TempDir()
+		gen = fakeGenerator{artifacts: map[string]string{"foo.yaml": generatedYAML}}
+	})
+
+	check := func() (bool, string) {
+		checker := &genall.OutputChecker{}
+		rt := runtimeFor(gen, genall.OutputArtifacts{Config: genall.OutputToDirectory(outDir)})
+		Expect(checker.WrapOutputRules(rt)).To(Succeed())
+		Expect(rt.Run()).To(BeFalse())
+
+		out := &strings.Builder{}
+		stale, err := checker.Report(out)
+		Expect(err).NotTo(HaveOccurred())
+		return stale, out.String()
+	}
+
+	It("should not report anything when the files on disk are up to date", func() {
+		Expect(os.WriteFile(filepath.Join(outDir, "foo.yaml"), []byte(generatedYAML), 0o644)).To(Succeed())
+
+		stale, report := check()
+		Expect(stale).To(BeFalse())
+		Expect(report).To(BeEmpty())
+	})
+
+	It("should report a diff when a file differs, without writing it", func() {
+		outdated := strings.Replace(generatedYAML, "(devel)", "v0.1.0", 1)
+		Expect(os.WriteFile(filepath.Join(outDir, "foo.yaml"), []byte(outdated), 0o644)).To(Succeed())
+
+		stale, report := check()
+		Expect(stale).To(BeTrue())
+		Expect(report).To(ContainSubstring("-    controller-gen.kubebuilder.io/version: v0.1.0\n+    controller-gen.kubebuilder.io/version: (devel)\n"))
+
+		By("checking that the file on disk wasn't touched")
+		Expect(os.ReadFile(filepath.Join(outDir, "foo.yaml"))).To(BeEquivalentTo(outdated))
+	})
+
+	It("should report files that don't exist yet", func() {
+		stale, report := check()
+		Expect(stale).To(BeTrue())
+		Expect(report).To(HavePrefix("--- " + os.DevNull + "\n"))
+		Expect(filepath.Join(outDir, "foo.yaml")).NotTo(BeAnExistingFile())
+	})
+
+	It("should report generated files that are no longer produced", func() {
+		Expect(os.WriteFile(filepath.Join(outDir, "foo.yaml"), []byte(generatedYAML), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(outDir, "old.yaml"), []byte(generatedYAML), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(outDir, "handwritten.yaml"), []byte("kind: Foo\n"), 0o644)).To(Succeed())
+
+		stale, report := check()
+		Expect(stale).To(BeTrue())
+		Expect(report).To(ContainSubstring("old.yaml\n+++ " + os.DevNull + "\n"))
+		Expect(report).NotTo(ContainSubstring("handwritten.yaml"))
+	})
+})
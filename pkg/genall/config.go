@@ -0,0 +1,344 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// ConfigVersion is the version of the config file format understood by
+// ParseConfig.
+const ConfigVersion = "v1alpha1"
+
+// DefaultOutputKey is the key in RunConfig.Output that sets the default
+// output rule (equivalent to `output:<rule>` on the command line).
+const DefaultOutputKey = "default"
+
+// +controllertools:marker:generateHelp:category="config file"
+
+// Config is a declarative description of one or more runs of a set of
+// generators, each equivalent to a set of command line options.  It's usually
+// loaded from a YAML file like:
+//
+//	version: v1alpha1
+//	runs:
+//	- name: api
+//	  paths: ["./api/..."]
+//	  generators:
+//	    crd:
+//	      maxDescLen: 0
+//	    object:
+//	      headerFile: hack/boilerplate.go.txt
+//	  output:
+//	    crd:
+//	      artifacts:
+//	        config: config/crd/bases
+//
+// which is equivalent to
+//
+//	paths=./api/... crd:maxDescLen=0 object:headerFile=hack/boilerplate.go.txt output:crd:artifacts:config=config/crd/bases
+type Config struct {
+	// Version is the version of the config file format, which must be v1alpha1.
+	Version string `json:"version"`
+	// Plugins are additional generators implemented by external executables,
+	// usable in any of the runs (see the plugin package).
+	Plugins []PluginConfig `json:"plugins,omitempty"`
+	// Macros are marker macros usable in every package in any of the runs,
+	// each standing for a list of other markers (see markers.Macro).
+	Macros []markers.Macro `json:"macros,omitempty"`
+	// Overlays are paths to marker overlay files used in every run, as for
+	// --marker-overlay (see OverlayFile).
+	Overlays []string `json:"overlays,omitempty"`
+	// Runs are the runs to perform, in order.
+	Runs []RunConfig `json:"runs"`
+}
+
+// +controllertools:marker:generateHelp:category="config file"
+
+// PluginConfig names a generator plugin executable.
+type PluginConfig struct {
+	// Name is the name of the generator, as used in the generators of each run.
+	Name string `json:"name"`
+	// Path is the path to the plugin executable.
+	Path string `json:"path"`
+}
+
+// +controllertools:marker:generateHelp:category="config file"
+
+// RunConfig describes a single run of a set of generators.
+type RunConfig struct {
+	// Name identifies this run in error messages.
+	Name string `json:"name,omitempty"`
+	// Paths are the package roots, as for the `paths` option.
+	Paths []string `json:"paths,omitempty"`
+	// Generators maps generator names to their arguments, as for the
+	// `<generator>:<arg>=<value>` options.  A null value runs the generator
+	// with no arguments.
+	Generators map[string]map[string]any `json:"generators"`
+	// Output maps generator names (or "default") to a single output rule name
+	// and its arguments, as for the `output:<generator>:<rule>` (or
+	// `output:<rule>`) options.
+	Output map[string]map[string]any `json:"output,omitempty"`
+}
+
+// ConfigFieldHelp is the help for a single field of a config file (see
+// ConfigHelp).
+type ConfigFieldHelp struct {
+	// Path is the path to the field in the file, like "runs[].paths".
+	Path string
+	// Type describes the type of the field's value, like "string" or
+	// "[]object".
+	Type string
+	// Optional indicates that the field may be omitted.
+	Optional bool
+	// DetailedHelp is the help for the field, from its godoc.
+	markers.DetailedHelp
+}
+
+// ConfigHelp returns the help for the config file format (from the godoc of
+// Config), and for each of the fields in it (from the godoc of the fields of
+// Config and the types nested in it), in the order that they're declared.
+func ConfigHelp() (*markers.DefinitionHelp, []ConfigFieldHelp) {
+	return Config{}.Help(), configFieldHelp(reflect.TypeOf(Config{}), "")
+}
+
+// configFieldHelp returns the help for the fields of the given struct type
+// (and the structs nested in it), prefixing their paths with the given prefix.
+func configFieldHelp(typ reflect.Type, prefix string) []ConfigFieldHelp {
+	var fieldHelp map[string]markers.DetailedHelp
+	if helpGiver, hasHelp := reflect.Zero(typ).Interface().(HasHelp); hasHelp {
+		fieldHelp = helpGiver.Help().FieldHelp
+	}
+
+	var res []ConfigFieldHelp
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		path := prefix + name
+		res = append(res, ConfigFieldHelp{
+			Path:         path,
+			Type:         configTypeName(field.Type),
+			Optional:     strings.Contains(opts, "omitempty"),
+			DetailedHelp: fieldHelp[field.Name],
+		})
+
+		// describe the fields of nested objects (and lists of them) too
+		elemType := field.Type
+		if elemType.Kind() == reflect.Slice {
+			elemType = elemType.Elem()
+			path += "[]"
+		}
+		if elemType.Kind() == reflect.Struct {
+			res = append(res, configFieldHelp(elemType, path+".")...)
+		}
+	}
+	return res
+}
+
+// configTypeName describes the given type as written in a config file.
+func configTypeName(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Slice:
+		return "[]" + configTypeName(typ.Elem())
+	case reflect.Map:
+		return "map[" + configTypeName(typ.Key()) + "]" + configTypeName(typ.Elem())
+	case reflect.Struct:
+		return "object"
+	case reflect.Interface:
+		return "any"
+	default:
+		return typ.Kind().String()
+	}
+}
+
+// ParseConfig parses the given YAML (or JSON) config file contents, failing
+// on unknown fields and unsupported versions.
+func ParseConfig(data []byte) (*Config, error) {
+	var config Config
+	if err := yaml.UnmarshalStrict(data, &config, func(dec *json.Decoder) *json.Decoder {
+		// keep numbers as written, so that they turn back into the same option values
+		dec.UseNumber()
+		return dec
+	}); err != nil {
+		return nil, err
+	}
+	if config.Version != ConfigVersion {
+		return nil, fmt.Errorf("unsupported config version %q (expected %q)", config.Version, ConfigVersion)
+	}
+	for i, plugin := range config.Plugins {
+		if plugin.Name == "" || plugin.Path == "" {
+			return nil, fmt.Errorf("plugin %d must specify both a name and a path", i)
+		}
+	}
+	macroNames := make(map[string]bool, len(config.Macros))
+	for _, macro := range config.Macros {
+		if err := macro.Validate(); err != nil {
+			return nil, err
+		}
+		if macroNames[macro.Name] {
+			return nil, fmt.Errorf("macro %q is defined more than once", macro.Name)
+		}
+		macroNames[macro.Name] = true
+	}
+	if len(config.Runs) == 0 {
+		return nil, fmt.Errorf("config must specify at least one run")
+	}
+	return &config, nil
+}
+
+// Options converts this run into the equivalent command line options, for
+// use with FromOptions and friends.
+func (r RunConfig) Options() ([]string, error) {
+	var opts []string
+	if len(r.Paths) > 0 {
+		paths, err := optionValue(toAnySlice(r.Paths))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, "paths="+paths)
+	}
+
+	for _, genName := range sortedKeys(r.Generators) {
+		opt, err := optionWithArgs(genName, r.Generators[genName])
+		if err != nil {
+			return nil, fmt.Errorf("generator %q: %w", genName, err)
+		}
+		opts = append(opts, opt)
+	}
+
+	for _, genName := range sortedKeys(r.Output) {
+		rules := r.Output[genName]
+		if len(rules) != 1 {
+			return nil, fmt.Errorf("output for %q must specify exactly one output rule, not %d", genName, len(rules))
+		}
+		prefix := "output:"
+		if genName != DefaultOutputKey {
+			if _, known := r.Generators[genName]; !known {
+				return nil, fmt.Errorf("output specified for non-invoked generator %q", genName)
+			}
+			prefix += genName + ":"
+		}
+		for ruleName, args := range rules {
+			opt, err := ruleOption(prefix+ruleName, args)
+			if err != nil {
+				return nil, fmt.Errorf("output for %q: %w", genName, err)
+			}
+			opts = append(opts, opt)
+		}
+	}
+
+	return opts, nil
+}
+
+// ruleOption converts an output rule and its arguments into an option.
+// Rules may either take a map of named arguments, or a single value (e.g.
+// the directory for `dir`).
+func ruleOption(name string, args any) (string, error) {
+	switch args := args.(type) {
+	case nil:
+		return name, nil
+	case map[string]any:
+		return optionWithArgs(name, args)
+	default:
+		val, err := optionValue(args)
+		if err != nil {
+			return "", err
+		}
+		return name + "=" + val, nil
+	}
+}
+
+// optionWithArgs converts the given option name and named arguments into
+// an option of the form `name:arg1=val1,arg2=val2`.
+func optionWithArgs(name string, args map[string]any) (string, error) {
+	if len(args) == 0 {
+		return name, nil
+	}
+	parts := make([]string, 0, len(args))
+	for _, argName := range sortedKeys(args) {
+		val, err := optionValue(args[argName])
+		if err != nil {
+			return "", fmt.Errorf("argument %q: %w", argName, err)
+		}
+		parts = append(parts, argName+"="+val)
+	}
+	return name + ":" + strings.Join(parts, ","), nil
+}
+
+// optionValue formats a decoded YAML value in marker argument syntax.
+func optionValue(val any) (string, error) {
+	switch val := val.(type) {
+	case string:
+		return strconv.Quote(val), nil
+	case json.Number:
+		return val.String(), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case []any:
+		items := make([]string, len(val))
+		for i, item := range val {
+			var err error
+			if items[i], err = optionValue(item); err != nil {
+				return "", err
+			}
+		}
+		return "{" + strings.Join(items, ",") + "}", nil
+	case map[string]any:
+		items := make([]string, 0, len(val))
+		for _, key := range sortedKeys(val) {
+			item, err := optionValue(val[key])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, strconv.Quote(key)+":"+item)
+		}
+		return "{" + strings.Join(items, ",") + "}", nil
+	default:
+		return "", fmt.Errorf("unsupported value %v (of type %T)", val, val)
+	}
+}
+
+// toAnySlice converts a slice of strings to a slice of any.
+func toAnySlice(vals []string) []any {
+	res := make([]any, len(vals))
+	for i, val := range vals {
+		res[i] = val
+	}
+	return res
+}
+
+// sortedKeys returns the keys of the given map, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,262 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/tools/go/packages"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+var _ = Describe("Config files", func() {
+	It("should convert runs into the equivalent command line options", func() {
+		config, err := genall.ParseConfig([]byte(`
+version: v1alpha1
+runs:
+- name: api
+  paths: ["./api/...", "./other"]
+  generators:
+    crd:
+      maxDescLen: 0
+      crdVersions: [v1]
+    object:
+    rbac:
+      roleName: manager-role
+  output:
+    crd:
+      artifacts:
+        config: config/crd/bases
+    rbac:
+      dir: config/rbac
+    default:
+      none: {}
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.Runs).To(HaveLen(1))
+
+		opts, err := config.Runs[0].Options()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts).To(Equal([]string{
+			`paths={"./api/...","./other"}`,
+			`crd:crdVersions={"v1"},maxDescLen=0`,
+			`object`,
+			`rbac:roleName="manager-role"`,
+			`output:crd:artifacts:config="config/crd/bases"`,
+			`output:none`,
+			`output:rbac:dir="config/rbac"`,
+		}))
+	})
+
+	It("should format map arguments as marker maps", func() {
+		config, err := genall.ParseConfig([]byte(`
+version: v1alpha1
+runs:
+- generators:
+    fake:
+      labels: {b: true, a: "x"}
+`))
+		Expect(err).NotTo(HaveOccurred())
+
+		opts, err := config.Runs[0].Options()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts).To(Equal([]string{`fake:labels={"a":"x","b":true}`}))
+	})
+
+	It("should reject unknown fields", func() {
+		_, err := genall.ParseConfig([]byte(`
+version: v1alpha1
+runs:
+- generator: {crd: {}}
+`))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should parse marker macros and overlays", func() {
+		config, err := genall.ParseConfig([]byte(`
+version: v1alpha1
+macros:
+- name: k8sName
+  description: a Kubernetes object name
+  expands:
+  - +kubebuilder:validation:MaxLength=253
+  - kubebuilder:validation:MinLength=1
+overlays: [hack/markers.yaml]
+runs:
+- generators: {crd: {}}
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.Macros).To(HaveLen(1))
+		Expect(config.Macros[0].Name).To(Equal("k8sName"))
+		Expect(config.Macros[0].Markers()).To(Equal([]string{"+kubebuilder:validation:MaxLength=253", "+kubebuilder:validation:MinLength=1"}))
+		Expect(config.Overlays).To(Equal([]string{"hack/markers.yaml"}))
+	})
+
+	It("should reject invalid and duplicate macros", func() {
+		_, err := genall.ParseConfig([]byte(`
+version: v1alpha1
+macros:
+- name: k8sName
+runs:
+- generators: {crd: {}}
+`))
+		Expect(err).To(MatchError(ContainSubstring("must expand to at least one marker")))
+
+		_, err = genall.ParseConfig([]byte(`
+version: v1alpha1
+macros:
+- {name: k8sName, expands: [a]}
+- {name: k8sName, expands: [b]}
+runs:
+- generators: {crd: {}}
+`))
+		Expect(err).To(MatchError(ContainSubstring("defined more than once")))
+	})
+
+	It("should reject unsupported versions", func() {
+		_, err := genall.ParseConfig([]byte(`
+version: v2
+runs:
+- generators: {crd: {}}
+`))
+		Expect(err).To(MatchError(ContainSubstring("unsupported config version")))
+	})
+
+	It("should reject output for generators that aren't run", func() {
+		config, err := genall.ParseConfig([]byte(`
+version: v1alpha1
+runs:
+- generators: {crd: {}}
+  output:
+    rbac: {dir: config/rbac}
+`))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = config.Runs[0].Options()
+		Expect(err).To(MatchError(ContainSubstring("non-invoked generator")))
+	})
+
+	It("should reject output with more than one rule", func() {
+		config, err := genall.ParseConfig([]byte(`
+version: v1alpha1
+runs:
+- generators: {crd: {}}
+  output:
+    crd: {dir: a, stdout: {}}
+`))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = config.Runs[0].Options()
+		Expect(err).To(MatchError(ContainSubstring("exactly one output rule")))
+	})
+
+	It("should produce options that can be parsed", func() {
+		config, err := genall.ParseConfig([]byte(`
+version: v1alpha1
+runs:
+- generators:
+    fake: {}
+  output:
+    fake:
+      dir: some/dir
+`))
+		Expect(err).NotTo(HaveOccurred())
+		opts, err := config.Runs[0].Options()
+		Expect(err).NotTo(HaveOccurred())
+
+		reg := &markers.Registry{}
+		Expect(reg.Register(markers.Must(markers.MakeDefinition("fake", markers.DescribesPackage, fakeGenerator{})))).To(Succeed())
+		Expect(reg.Register(markers.Must(markers.MakeDefinition("output:fake:dir", markers.DescribesPackage, genall.OutputToDirectory(""))))).To(Succeed())
+		Expect(genall.RegisterOptionsMarkers(reg)).To(Succeed())
+
+		rt, err := genall.FromOptions(reg, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rt.Generators).To(HaveLen(1))
+		Expect(rt.OutputRules.ByGenerator).To(HaveLen(1))
+		for _, rule := range rt.OutputRules.ByGenerator {
+			Expect(rule).To(Equal(genall.OutputToDirectory("some/dir")))
+		}
+	})
+
+	It("should resolve paths relative to the config directory", func() {
+		configDir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(configDir, "go.mod"), []byte("module example.com/cfg\n\ngo 1.22\n"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(configDir, "cfg.go"), []byte("package cfg\n"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(configDir, "header.txt"), []byte("// header"), 0o644)).To(Succeed())
+		config, err := genall.ParseConfig([]byte(`
+version: v1alpha1
+runs:
+- generators:
+    fake: {}
+  output:
+    fake:
+      dir: some/dir
+`))
+		Expect(err).NotTo(HaveOccurred())
+		opts, err := config.Runs[0].Options()
+		Expect(err).NotTo(HaveOccurred())
+
+		reg := &markers.Registry{}
+		Expect(reg.Register(markers.Must(markers.MakeDefinition("fake", markers.DescribesPackage, fakeGenerator{})))).To(Succeed())
+		Expect(reg.Register(markers.Must(markers.MakeDefinition("output:fake:dir", markers.DescribesPackage, genall.OutputToDirectory(""))))).To(Succeed())
+		Expect(genall.RegisterOptionsMarkers(reg)).To(Succeed())
+
+		rt, err := genall.FromOptionsWithConfig(&packages.Config{Dir: configDir}, reg, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rt.Roots).To(HaveLen(1))
+		Expect(rt.Roots[0].PkgPath).To(Equal("example.com/cfg"))
+		for _, rule := range rt.OutputRules.ByGenerator {
+			Expect(rule).To(Equal(genall.OutputToDirectory(filepath.Join(configDir, "some", "dir"))))
+		}
+
+		By("checking that inputs are read relative to the config directory")
+		in, err := rt.InputRule.OpenForRead("header.txt")
+		Expect(err).NotTo(HaveOccurred())
+		defer in.Close()
+		contents, err := io.ReadAll(in)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("// header"))
+	})
+
+	It("should describe every field in its help", func() {
+		configHelp, fields := genall.ConfigHelp()
+		Expect(configHelp.Summary).NotTo(BeEmpty())
+
+		paths := make([]string, 0, len(fields))
+		for _, field := range fields {
+			Expect(field.Summary).NotTo(BeEmpty(), "field %s has no help", field.Path)
+			paths = append(paths, field.Path)
+		}
+		Expect(paths).To(Equal([]string{
+			"version",
+			"plugins", "plugins[].name", "plugins[].path",
+			"macros", "macros[].name", "macros[].expands", "macros[].description",
+			"overlays",
+			"runs", "runs[].name", "runs[].paths", "runs[].generators", "runs[].output",
+		}))
+		Expect(fields[0]).To(HaveField("Type", "string"))
+		Expect(fields[0]).To(HaveField("Optional", false))
+		Expect(fields[1]).To(HaveField("Type", "[]object"))
+		Expect(fields[1]).To(HaveField("Optional", true))
+	})
+})
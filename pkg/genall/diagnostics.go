@@ -0,0 +1,436 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"encoding/json"
+	"errors"
+	"go/token"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// Severity is the severity of a Diagnostic.
+type Severity string
+
+const (
+	// SeverityError indicates that generation failed.
+	SeverityError Severity = "error"
+	// SeverityWarning indicates a problem that didn't stop generation
+	// (see GenerationContext.Warn).
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a single, structured problem reported while running
+// Generators.
+type Diagnostic struct {
+	// Severity is the severity of the problem.
+	Severity Severity `json:"severity"`
+	// Message describes the problem.
+	Message string `json:"message"`
+	// Filename is the file the problem is in, if known.
+	Filename string `json:"filename,omitempty"`
+	// Line is the 1-based line the problem is on, if known.
+	Line int `json:"line,omitempty"`
+	// Column is the 1-based column the problem starts at, if known.
+	Column int `json:"column,omitempty"`
+	// EndLine and EndColumn are the 1-based line and column just past the
+	// end of the problem, if known (e.g. for marker parsing errors).
+	EndLine   int `json:"endLine,omitempty"`
+	EndColumn int `json:"endColumn,omitempty"`
+	// Generator is the name of the Generator that reported the problem,
+	// if known.
+	Generator string `json:"generator,omitempty"`
+	// Marker is the name of the marker involved in the problem, if any.
+	Marker string `json:"marker,omitempty"`
+	// Code identifies the kind of problem, for warnings.
+	Code string `json:"code,omitempty"`
+}
+
+// Diagnostics collects Diagnostics from one or more Runtimes, for
+// reporting in a machine-readable format.  Set Runtime.Diagnostics to
+// report problems here instead of as text.
+//
+// Package errors are attributed to the Generator that added them.  When
+// Generators run in parallel, an error is attributed to the Generator that
+// was running when it was added, if only one was -- errors added while
+// several Generators were running at once aren't attributed to any of them.
+type Diagnostics struct {
+	mu    sync.Mutex
+	items []Diagnostic
+	// runs records the errors added to packages by each Generator run.
+	runs []generatorRun
+}
+
+// generatorRun records the errors that were added to packages while a
+// Generator ran.
+type generatorRun struct {
+	generator string
+	// before and after are the number of errors on each package before
+	// and after the run.
+	before, after errorCounts
+}
+
+// Items returns the collected Diagnostics, in the order they were reported.
+func (d *Diagnostics) Items() []Diagnostic {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Diagnostic(nil), d.items...)
+}
+
+// Add records the given Diagnostic.
+func (d *Diagnostics) Add(diag Diagnostic) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.items = append(d.items, diag)
+}
+
+// WriteJSON writes the collected Diagnostics as a JSON array.
+func (d *Diagnostics) WriteJSON(out io.Writer) error {
+	items := d.Items()
+	if items == nil {
+		items = []Diagnostic{}
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(items)
+}
+
+// WriteSARIF writes the collected Diagnostics as a SARIF 2.1.0 log, with
+// a single run attributed to controller-gen at the given version.
+func (d *Diagnostics) WriteSARIF(out io.Writer, toolVersion string) error {
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "controller-gen",
+				Version:        toolVersion,
+				InformationURI: "https://github.com/kubernetes-sigs/controller-tools",
+			}},
+			Results: []sarifResult{},
+		}},
+	}
+	run := &log.Runs[0]
+
+	seenRules := make(map[string]struct{})
+	for _, diag := range d.Items() {
+		ruleID := diag.ruleID()
+		if _, seen := seenRules[ruleID]; !seen {
+			seenRules[ruleID] = struct{}{}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: ruleID})
+		}
+
+		result := sarifResult{
+			RuleID:  ruleID,
+			Level:   sarifLevel(diag.Severity),
+			Message: sarifMessage{Text: diag.Message},
+		}
+		if diag.Generator != "" || diag.Marker != "" || diag.Code != "" {
+			result.Properties = map[string]string{}
+			if diag.Generator != "" {
+				result.Properties["generator"] = diag.Generator
+			}
+			if diag.Marker != "" {
+				result.Properties["marker"] = diag.Marker
+			}
+			if diag.Code != "" {
+				result.Properties["code"] = diag.Code
+			}
+		}
+		if diag.Filename != "" {
+			loc := sarifLocation{}
+			loc.PhysicalLocation.ArtifactLocation.URI = sarifURI(diag.Filename)
+			if diag.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{
+					StartLine:   diag.Line,
+					StartColumn: diag.Column,
+					EndLine:     diag.EndLine,
+					EndColumn:   diag.EndColumn,
+				}
+			}
+			result.Locations = []sarifLocation{loc}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// ruleID identifies the kind of problem for SARIF: the warning code, or
+// the marker involved, otherwise the generator that reported it.
+func (d Diagnostic) ruleID() string {
+	switch {
+	case d.Code != "":
+		return d.Code
+	case d.Marker != "":
+		return d.Marker
+	case d.Generator != "":
+		return d.Generator
+	default:
+		return "controller-gen"
+	}
+}
+
+// errorCounts records the number of errors on each package in a graph.
+type errorCounts map[*packages.Package]int
+
+// countErrors counts the errors on each package in the graph starting at
+// the given roots.  It's safe to call while Generators are running.
+func countErrors(roots []*loader.Package) errorCounts {
+	return loader.CountErrors(roots)
+}
+
+// claimErrors records that the errors added to the package graph since the
+// given counts were taken were added while the given Generator ran.
+func (d *Diagnostics) claimErrors(roots []*loader.Package, before errorCounts, genName string) {
+	after := countErrors(roots)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.runs = append(d.runs, generatorRun{generator: genName, before: before, after: after})
+}
+
+// ownerOf returns the name of the Generator that added the error at the
+// given index on the given package, or "" if it's not known (e.g. because
+// several Generators were running when it was added).  It must be called
+// with mu held.
+func (d *Diagnostics) ownerOf(pkg *packages.Package, index int) string {
+	owner := ""
+	for _, run := range d.runs {
+		if index < run.before[pkg] || index >= run.after[pkg] {
+			continue
+		}
+		if owner != "" && owner != run.generator {
+			return ""
+		}
+		owner = run.generator
+	}
+	return owner
+}
+
+// addGeneratorError records an error returned by a Generator.  fset is used
+// to resolve positions of loader.PositionedErrors, if available.
+func (d *Diagnostics) addGeneratorError(fset *token.FileSet, genName string, err error) {
+	var errList loader.ErrList
+	if errors.As(err, &errList) {
+		for _, subErr := range errList {
+			d.addGeneratorError(fset, genName, subErr)
+		}
+		return
+	}
+
+	diag := Diagnostic{
+		Severity:  SeverityError,
+		Message:   err.Error(),
+		Generator: genName,
+		Marker:    markerFor(err),
+	}
+	var posErr loader.PositionedError
+	if fset != nil && errors.As(err, &posErr) && posErr.Pos.IsValid() {
+		pos := fset.Position(posErr.Pos)
+		diag.Filename, diag.Line, diag.Column = pos.Filename, pos.Line, pos.Column
+		diag.setEnd(fset, err)
+	}
+	d.Add(diag)
+}
+
+// addPackageErrors records the errors in the package graph starting at the
+// given roots (skipping those of the given kinds), returning true if any
+// were recorded.
+func (d *Diagnostics) addPackageErrors(roots []*loader.Package, filterKinds ...packages.ErrorKind) bool {
+	toSkip := make(map[packages.ErrorKind]struct{})
+	for _, errKind := range filterKinds {
+		toSkip[errKind] = struct{}{}
+	}
+
+	var fset *token.FileSet
+	if len(roots) > 0 {
+		fset = roots[0].FileSet()
+	}
+
+	var diags []Diagnostic
+	indices := make(map[*packages.Package]int)
+	loader.VisitErrors(roots, func(pkg *packages.Package, pkgErr packages.Error, cause error) {
+		index := indices[pkg]
+		indices[pkg]++
+		if _, skip := toSkip[pkgErr.Kind]; skip {
+			return
+		}
+		diag := Diagnostic{
+			Severity: SeverityError,
+			Message:  pkgErr.Msg,
+			Marker:   markerFor(cause),
+		}
+		diag.Filename, diag.Line, diag.Column = splitPos(pkgErr.Pos)
+		diag.setEnd(fset, cause)
+		d.mu.Lock()
+		diag.Generator = d.ownerOf(pkg, index)
+		d.mu.Unlock()
+		diags = append(diags, diag)
+	})
+	for _, diag := range diags {
+		d.Add(diag)
+	}
+	return len(diags) > 0
+}
+
+// setEnd sets the end of the diagnostic's range from the given error, for
+// marker parsing errors that know the extent of the part of the marker
+// they're about.  fset is used to resolve the end's position.
+func (d *Diagnostic) setEnd(fset *token.FileSet, err error) {
+	var markerErr markers.MarkerError
+	if d.Column == 0 || fset == nil || err == nil || !errors.As(err, &markerErr) || !markerErr.End.IsValid() {
+		return
+	}
+	end := fset.Position(markerErr.End)
+	d.EndLine, d.EndColumn = end.Line, end.Column
+}
+
+// markerFor returns the name of the marker involved in the given error, if any.
+func markerFor(err error) string {
+	var markerErr markers.MarkerError
+	if err != nil && errors.As(err, &markerErr) {
+		return markerErr.Marker
+	}
+	return ""
+}
+
+// splitPos splits a position as formatted in packages.Error (file:line:col,
+// file:line, file, or "-") into its parts.
+func splitPos(pos string) (filename string, line, column int) {
+	if pos == "" || pos == "-" {
+		return "", 0, 0
+	}
+	var nums []int
+	for range 2 {
+		idx := strings.LastIndex(pos, ":")
+		if idx < 0 {
+			break
+		}
+		num, err := strconv.Atoi(pos[idx+1:])
+		if err != nil {
+			if pos[idx+1:] == "-" {
+				// the package-level form, pkgID:-
+				return "", 0, 0
+			}
+			break
+		}
+		nums = append([]int{num}, nums...)
+		pos = pos[:idx]
+	}
+	switch len(nums) {
+	case 2:
+		return pos, nums[0], nums[1]
+	case 1:
+		return pos, nums[0], 0
+	default:
+		return pos, 0, 0
+	}
+}
+
+// SARIF (https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html)
+// types, only including what we need.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// sarifLevel converts a Severity to a SARIF result level.
+func sarifLevel(severity Severity) string {
+	switch severity {
+	case SeverityError:
+		return "error"
+	default:
+		return "warning"
+	}
+}
+
+// sarifURI converts a filename into a SARIF artifact URI: a relative
+// reference for files under the working directory (so that results line up
+// with the repository being checked), and a file URI otherwise.
+func sarifURI(filename string) string {
+	path := displayPath(filename)
+	if !filepath.IsAbs(path) {
+		return (&url.URL{Path: filepath.ToSlash(path)}).String()
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// windows paths, like C:/foo
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -0,0 +1,227 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	"golang.org/x/tools/go/packages"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// numberGenerator collects a package marker that takes a number.
+type numberGenerator struct{}
+
+func (numberGenerator) RegisterMarkers(into *markers.Registry) error {
+	return into.Register(markers.Must(markers.MakeDefinition("test:num", markers.DescribesPackage, 0)))
+}
+
+func (numberGenerator) Generate(ctx *genall.GenerationContext) error {
+	for _, root := range ctx.Roots {
+		if _, err := markers.PackageMarkers(ctx.Collector, root); err != nil {
+			root.AddError(err)
+		}
+	}
+	return nil
+}
+
+// failingGenerator always fails.
+type failingGenerator struct{}
+
+func (failingGenerator) RegisterMarkers(*markers.Registry) error { return nil }
+
+func (failingGenerator) Generate(*genall.GenerationContext) error {
+	return errors.New("boom")
+}
+
+// funcGenerator runs the given function.
+type funcGenerator func(*genall.GenerationContext) error
+
+func (funcGenerator) RegisterMarkers(*markers.Registry) error { return nil }
+
+func (f funcGenerator) Generate(ctx *genall.GenerationContext) error {
+	return f(ctx)
+}
+
+var _ = Describe("Diagnostics", func() {
+	var rt *genall.Runtime
+	var diags *genall.Diagnostics
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/diags\n\ngo 1.22\n"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "a.go"), []byte("// +test:num=notanumber\npackage a\n"), 0o644)).To(Succeed())
+
+		gens := genall.Generators{new(genall.Generator), new(genall.Generator)}
+		*gens[0], *gens[1] = numberGenerator{}, failingGenerator{}
+		var err error
+		rt, err = gens.ForRootsWithConfig(&packages.Config{Dir: dir}, "./...")
+		Expect(err).NotTo(HaveOccurred())
+		rt.GeneratorNames = map[*genall.Generator]string{gens[0]: "num", gens[1]: "fail"}
+		rt.OutputRules = genall.OutputRules{Default: genall.OutputToNothing}
+		rt.ErrorWriter = GinkgoWriter
+
+		diags = &genall.Diagnostics{}
+		rt.Diagnostics = diags
+	})
+
+	It("should collect problems with their ranges, generators, and markers", func() {
+		Expect(rt.Run()).To(BeTrue())
+
+		items := diags.Items()
+		Expect(items).To(HaveLen(2))
+		Expect(items[0]).To(MatchFields(IgnoreExtras, Fields{
+			"Severity":  Equal(genall.SeverityError),
+			"Message":   Equal("boom"),
+			"Generator": Equal("fail"),
+			"Filename":  BeEmpty(),
+		}))
+		Expect(items[1]).To(MatchFields(IgnoreExtras, Fields{
+			"Severity":  Equal(genall.SeverityError),
+			"Filename":  HaveSuffix("a.go"),
+			"Line":      Equal(1),
+			"Column":    Equal(14),
+			"EndLine":   Equal(1),
+			"EndColumn": Equal(24),
+			"Generator": Equal("num"),
+			"Marker":    Equal("test:num"),
+		}))
+	})
+
+	It("should find the end of problems in markers continued over several lines", func() {
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/diags\n\ngo 1.22\n"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "a.go"), []byte("// +test:num=\"not a \\\n//   number\"\npackage a\n"), 0o644)).To(Succeed())
+		gen := genall.Generator(numberGenerator{})
+		rt, err := genall.Generators{&gen}.ForRootsWithConfig(&packages.Config{Dir: dir}, "./...")
+		Expect(err).NotTo(HaveOccurred())
+		rt.OutputRules = genall.OutputRules{Default: genall.OutputToNothing}
+		rt.Diagnostics = diags
+		Expect(rt.Run()).To(BeTrue())
+
+		items := diags.Items()
+		Expect(items).To(HaveLen(1))
+		Expect(items[0]).To(MatchFields(IgnoreExtras, Fields{
+			"Line":      Equal(1),
+			"Column":    Equal(14),
+			"EndLine":   Equal(2),
+			"EndColumn": Equal(13),
+		}))
+	})
+
+	It("should attribute package errors to generators run in parallel", func() {
+		rt.Generators = rt.Generators[:1]
+		rt.Parallelism = 2
+		Expect(rt.Run()).To(BeTrue())
+
+		items := diags.Items()
+		Expect(items).To(HaveLen(1))
+		Expect(items[0].Generator).To(Equal("num"))
+	})
+
+	It("should not attribute package errors added while several generators were running", func() {
+		started, added := make(chan struct{}), make(chan struct{})
+		gens := genall.Generators{new(genall.Generator), new(genall.Generator)}
+		*gens[0] = funcGenerator(func(ctx *genall.GenerationContext) error {
+			<-started
+			ctx.Roots[0].AddError(errors.New("added while both ran"))
+			close(added)
+			return nil
+		})
+		*gens[1] = funcGenerator(func(*genall.GenerationContext) error {
+			close(started)
+			<-added
+			return nil
+		})
+		rt.Generators = gens
+		rt.GeneratorNames = map[*genall.Generator]string{gens[0]: "first", gens[1]: "second"}
+		rt.Parallelism = 2
+		Expect(rt.Run()).To(BeTrue())
+
+		items := diags.Items()
+		Expect(items).To(HaveLen(1))
+		Expect(items[0].Message).To(Equal("added while both ran"))
+		Expect(items[0].Generator).To(BeEmpty())
+	})
+
+	It("should write SARIF logs", func() {
+		Expect(rt.Run()).To(BeTrue())
+
+		var out bytes.Buffer
+		Expect(diags.WriteSARIF(&out, "v1.2.3")).To(Succeed())
+
+		var log struct {
+			Version string `json:"version"`
+			Runs    []struct {
+				Tool struct {
+					Driver struct {
+						Name    string `json:"name"`
+						Version string `json:"version"`
+						Rules   []struct {
+							ID string `json:"id"`
+						} `json:"rules"`
+					} `json:"driver"`
+				} `json:"tool"`
+				Results []struct {
+					RuleID    string `json:"ruleId"`
+					Level     string `json:"level"`
+					Locations []struct {
+						PhysicalLocation struct {
+							ArtifactLocation struct {
+								URI string `json:"uri"`
+							} `json:"artifactLocation"`
+							Region struct {
+								StartLine int `json:"startLine"`
+							} `json:"region"`
+						} `json:"physicalLocation"`
+					} `json:"locations"`
+				} `json:"results"`
+			} `json:"runs"`
+		}
+		Expect(json.Unmarshal(out.Bytes(), &log)).To(Succeed())
+		Expect(log.Version).To(Equal("2.1.0"))
+		Expect(log.Runs).To(HaveLen(1))
+		run := log.Runs[0]
+		Expect(run.Tool.Driver.Name).To(Equal("controller-gen"))
+		Expect(run.Tool.Driver.Version).To(Equal("v1.2.3"))
+		Expect(run.Tool.Driver.Rules).To(HaveLen(2))
+
+		Expect(run.Results).To(HaveLen(2))
+		Expect(run.Results[0].RuleID).To(Equal("fail"))
+		Expect(run.Results[0].Locations).To(BeEmpty())
+		Expect(run.Results[1].RuleID).To(Equal("test:num"))
+		Expect(run.Results[1].Level).To(Equal("error"))
+		Expect(run.Results[1].Locations).To(HaveLen(1))
+		Expect(run.Results[1].Locations[0].PhysicalLocation.ArtifactLocation.URI).To(HaveSuffix("/a.go"))
+		Expect(run.Results[1].Locations[0].PhysicalLocation.Region.StartLine).To(Equal(1))
+	})
+
+	It("should write an empty JSON array when there are no problems", func() {
+		var out bytes.Buffer
+		Expect(diags.WriteJSON(&out)).To(Succeed())
+		Expect(out.String()).To(Equal("[]\n"))
+	})
+})
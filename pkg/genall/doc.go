@@ -37,8 +37,29 @@ limitations under the License.
 // OutputRules are defined for stdout, file writing, and sending to /dev/null
 // (useful for doing "type-checking" without actually saving the results).
 //
+// OutputToHelm writes config into a Helm chart instead, turning the names,
+// namespaces, and webhook services in it into Helm placeholders.
+//
+// FileOutputRules additionally know which file on disk each artifact goes
+// to.  OutputChecker uses this to compare artifacts against the files on disk
+// instead of writing them (e.g. to verify that generated files are up to
+// date in CI), and OutputSyncer uses it to only write artifacts that changed
+// and to prune generated files that are no longer produced.  Generators that
+// write files themselves, rather than via an OutputRule, aren't covered by
+// this.
+//
+// Kustomizer similarly records the YAML artifacts written via
+// FileOutputRules, and writes a kustomization.yaml listing them into each
+// output directory, merging with any existing one.
+//
+// OutputToMemory collects artifacts in memory instead, keyed by the
+// Generator that produced them, which is useful when using generators as a
+// library.  Runtime.Generate runs the Generators with it, returning the
+// Artifacts that they produced.
+//
 // InputRule defines custom input loading, but its shared across all
-// Generators.  There's currently only a filesystem implementation.
+// Generators.  InputFromFileSystem reads from the filesystem, while
+// InputFromFS reads from an fs.FS (like an embed.FS).
 //
 // # Runtime and Context
 //
@@ -46,13 +67,55 @@ limitations under the License.
 // the common collector and roots, plus the output rule for that generator, and
 // a handle for reading files (like boilerplate headers).
 //
+// GenerationContext.LoadHeader loads such headers (see Header for their
+// format, which generators document their header options by referring to).
+// Headers that refer to the generator, package, year or version are
+// rendered as templates for each artifact, and all headers are wrapped in
+// the comment syntax for that kind of artifact.
+//
 // It will run all associated generators, printing errors and automatically
 // skipping type-checking errors (since those are commonly caused by the
-// partial type-checking of loader.TypeChecker).
+// partial type-checking of loader.TypeChecker).  Generators may be run in
+// parallel by setting Runtime.Parallelism, since the shared collector,
+// type-checker, and packages are all safe for concurrent use.
+//
+// Generators can also report problems that don't stop them from producing
+// output as Warnings, via GenerationContext.Warn.  Each Warning has a code,
+// which can be used to suppress it with a NoLint marker (e.g.
+// `+kubebuilder:nolint:some-code`).  Setting Runtime.WarningsAsErrors fails
+// the run if any are reported.  Setting Runtime.KnownMarkers also warns about
+// unknown (e.g. misspelled) and misplaced markers in the roots.
+//
+// Setting Runtime.Diagnostics collects problems as structured Diagnostics
+// (with their positions, and the Generator and marker involved, where known)
+// instead, which can then be written as JSON or as a SARIF log.
+//
+// Watcher keeps a Runtime alive, re-running Generators as their inputs
+// change.  It reuses the unchanged packages (and their collected markers)
+// between runs, only re-parsing and re-checking what changed.
+//
+// StartProfile records how long each phase of a run (loading, type-checking,
+// marker collection, generation, etc) takes, and how much it allocates, per
+// Generator.  The results can be written as a table, or as a Chrome trace.
+//
+// MigrateMarkers rewrites deprecated markers in the roots' source files into
+// their replacements, using the Migrations registered for them.  FormatMarkers
+// similarly rewrites markers into their canonical form.
 //
 // # Options
 //
 // The FromOptions (and associated helpers) function makes it easy to use generators
 // and output rules as markers that can be parsed from the command line, producing
 // a registry from command line args.
+//
+// Config describes the same options declaratively (usually in a YAML file),
+// as a series of runs.  Each RunConfig can be converted back into the
+// equivalent command line options with RunConfig.Options.  It may also
+// define marker macros (see markers.Macro) for the Collector.  Runtimes can
+// define macros in packages too, since ForRoots registers the marker for
+// them, and RegisterMacroHelp lists them alongside the other markers in help.
+//
+// OverlayFile attaches markers to types and fields whose source can't be
+// edited (e.g. types from other modules).  LoadOverlays loads them for
+// Collector.Overlays, failing if any of their targets no longer exist.
 package genall
@@ -17,13 +17,18 @@ limitations under the License.
 package genall
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"go/token"
 	"io"
+	"io/fs"
 	"os"
+	"sync"
 
 	"golang.org/x/tools/go/packages"
 	rawyaml "gopkg.in/yaml.v2"
+	"sigs.k8s.io/controller-tools/pkg/internal/profile"
 	"sigs.k8s.io/controller-tools/pkg/loader"
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
@@ -101,6 +106,29 @@ type Runtime struct {
 	OutputRules OutputRules
 	// ErrorWriter defines where to write error messages.
 	ErrorWriter io.Writer
+	// Parallelism is the maximum number of Generators to run at once.
+	// Values less than 2 run the Generators one after another.
+	Parallelism int
+	// GeneratorNames are the names of the Generators, as used in options
+	// (see FromOptions).  They're used to attribute Diagnostics.
+	GeneratorNames map[*Generator]string
+	// Diagnostics, if set, collects problems as structured Diagnostics
+	// instead of printing them to ErrorWriter and standard error.
+	Diagnostics *Diagnostics
+	// WarningsAsErrors reports the warnings from Generators as errors,
+	// failing the run if there are any.
+	WarningsAsErrors bool
+	// KnownMarkers, if set, enables warnings about markers that look like
+	// they're meant for controller-gen (see markers.UnknownMarkerPrefixes),
+	// but that match no definition in it (or in the Collector's registry)
+	// for the node they're on, such as misspelled markers.  It should
+	// usually contain the markers of every known Generator, not just the
+	// ones being run.
+	KnownMarkers *markers.Registry
+
+	// inputFor, if set, wraps the InputRule given to each Generator
+	// (used to track which files each Generator reads).
+	inputFor func(gen *Generator, rule InputRule) InputRule
 }
 
 // GenerationContext defines the common information needed for each Generator
@@ -117,6 +145,11 @@ type GenerationContext struct {
 	// InputRule describes how to load associated boilerplate artifacts.
 	// It should *not* be used to load source files.
 	InputRule
+
+	// warnings collects the warnings reported by the Generator being run.
+	warnings *warningSink
+	// generatorName is the name of the Generator being run, if known.
+	generatorName string
 }
 
 // WriteYAMLOptions implements the Options Pattern for WriteYAML.
@@ -215,6 +248,15 @@ func (g GenerationContext) ReadFile(path string) ([]byte, error) {
 	return io.ReadAll(file)
 }
 
+// ReadDir lists the entries of the given directory using the context's
+// InputRule, if it's a DirectoryInputRule, or from the filesystem otherwise.
+func (g GenerationContext) ReadDir(path string) ([]fs.DirEntry, error) {
+	if dirRule, canList := g.InputRule.(DirectoryInputRule); canList {
+		return dirRule.ReadDir(path)
+	}
+	return os.ReadDir(path)
+}
+
 // ForRoots produces a Runtime to run the given generators against the
 // given packages.  It outputs to /dev/null by default.
 func (g Generators) ForRoots(rootPaths ...string) (*Runtime, error) {
@@ -243,16 +285,23 @@ func (g Generators) ForRootsWithConfig(cfg *packages.Config, rootPaths ...string
 	if err := rt.Generators.RegisterMarkers(rt.Collector.Registry); err != nil {
 		return nil, err
 	}
+	if err := RegisterNoLintMarkers(rt.Collector.Registry); err != nil {
+		return nil, err
+	}
+	if err := RegisterMacroMarkers(rt.Collector.Registry); err != nil {
+		return nil, err
+	}
 	return rt, nil
 }
 
 // Run runs the Generators in this Runtime against its packages, printing
 // errors (except type errors, which common result from using TypeChecker with
 // filters), returning true if errors were found.
+//
+// If Parallelism is greater than one, up to that many Generators are run at
+// the same time.  Errors and standard-out output are still reported in
+// Generator order, so the results are the same as a sequential run.
 func (r *Runtime) Run() bool {
-	// TODO(directxman12): we could make this parallel,
-	// but we'd need to ensure all underlying machinery is threadsafe
-
 	if r.ErrorWriter == nil {
 		r.ErrorWriter = os.Stderr
 	}
@@ -261,23 +310,155 @@ func (r *Runtime) Run() bool {
 		return true
 	}
 
-	hadErrs := false
-	for _, gen := range r.Generators {
-		ctx := r.GenerationContext // make a shallow copy
-		ctx.OutputRule = r.OutputRules.ForGenerator(gen)
-
-		// don't pass a typechecker to generators that don't provide a filter
-		// to avoid accidents
-		if _, needsChecking := (*gen).(NeedsTypeChecking); !needsChecking {
-			ctx.Checker = nil
-		}
+	return r.run(r.Generators)
+}
 
-		if err := (*gen).Generate(&ctx); err != nil {
-			fmt.Fprintln(r.ErrorWriter, err)
-			hadErrs = true
+// run runs the given subset of this Runtime's Generators, as per Run.
+func (r *Runtime) run(gens Generators) bool {
+	var hadErrs bool
+	if r.Parallelism > 1 {
+		hadErrs = r.runParallel(gens)
+	} else {
+		for _, gen := range gens {
+			var before errorCounts
+			if r.Diagnostics != nil {
+				before = countErrors(r.Roots)
+			}
+			warnings := &warningSink{}
+			err := r.runGenerator(gen, r.OutputRules.ForGenerator(gen), warnings)
+			if r.Diagnostics != nil {
+				r.Diagnostics.claimErrors(r.Roots, before, r.GeneratorNames[gen])
+			}
+			if r.reportWarnings(gen, warnings) {
+				hadErrs = true
+			}
+			if err != nil {
+				r.reportError(gen, err)
+				hadErrs = true
+			}
 		}
 	}
 
+	if r.KnownMarkers != nil && r.reportWarnings(nil, r.checkMarkers()) {
+		hadErrs = true
+	}
+
 	// skip TypeErrors -- they're probably just from partial typechecking in crd-gen
+	if r.Diagnostics != nil {
+		return r.Diagnostics.addPackageErrors(r.Roots, packages.TypeError) || hadErrs
+	}
 	return loader.PrintErrors(r.Roots, packages.TypeError) || hadErrs
 }
+
+// reportError reports an error returned by the given Generator, either as
+// text or as Diagnostics.
+func (r *Runtime) reportError(gen *Generator, err error) {
+	if r.Diagnostics == nil {
+		fmt.Fprintln(r.ErrorWriter, err)
+		return
+	}
+	var fset *token.FileSet
+	if len(r.Roots) > 0 {
+		fset = r.Roots[0].FileSet()
+	}
+	r.Diagnostics.addGeneratorError(fset, r.GeneratorNames[gen], err)
+}
+
+// runGenerator runs a single Generator with a copy of the base generation
+// context that writes using the given output rule, and collects warnings
+// into the given sink.
+func (r *Runtime) runGenerator(gen *Generator, outputRule OutputRule, warnings *warningSink) error {
+	ctx := r.GenerationContext // make a shallow copy
+	ctx.OutputRule = outputRule
+	ctx.warnings = warnings
+	ctx.generatorName = r.GeneratorNames[gen]
+
+	generatorName := ctx.generatorName
+	if generatorName == "" {
+		generatorName = fmt.Sprintf("%T", *gen)
+	}
+	defer profile.StartGenerator(profile.Generate, generatorName)()
+
+	// don't pass a typechecker to generators that don't provide a filter
+	// to avoid accidents
+	if _, needsChecking := (*gen).(NeedsTypeChecking); !needsChecking {
+		ctx.Checker = nil
+	}
+	if r.inputFor != nil {
+		ctx.InputRule = r.inputFor(gen, ctx.InputRule)
+	}
+
+	return (*gen).Generate(&ctx)
+}
+
+// runParallel runs up to Parallelism Generators at once, reporting errors
+// in Generator order once they've all finished.  It returns true if any
+// Generator returned an error.
+func (r *Runtime) runParallel(gens Generators) bool {
+	// type-check the roots up front, so that every generator sees the same
+	// type information regardless of which one happens to get there first.
+	if r.Checker != nil && len(gens.CheckFilters()) > 0 {
+		for _, root := range r.Roots {
+			r.Checker.Check(root)
+		}
+	}
+
+	errs := make([]error, len(gens))
+	warnings := make([]*warningSink, len(gens))
+	stdouts := make([]*bytes.Buffer, len(gens))
+	limit := make(chan struct{}, r.Parallelism)
+	var wg sync.WaitGroup
+	helmValuesLocks := make(map[string]*sync.Mutex)
+	for i, gen := range gens {
+		outputRule := r.OutputRules.ForGenerator(gen)
+		// generators writing to the same Helm chart take turns updating
+		// its values.
+		if helmRule, isHelm := outputRule.(OutputToHelm); isHelm {
+			outputRule = helmRule.withValuesLock(helmValuesLocks)
+		}
+		// buffer anything headed to stdout so that output from different
+		// generators doesn't get interleaved.
+		if _, isStdout := outputRule.(outputToStdout); isStdout {
+			stdouts[i] = &bytes.Buffer{}
+			outputRule = outputToWriter{Writer: stdouts[i]}
+		}
+
+		warnings[i] = &warningSink{}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			// record the errors added to packages during each generator's
+			// own run, since others may be adding errors at the same time.
+			var before errorCounts
+			if r.Diagnostics != nil {
+				before = countErrors(r.Roots)
+			}
+			errs[i] = r.runGenerator(gen, outputRule, warnings[i])
+			if r.Diagnostics != nil {
+				r.Diagnostics.claimErrors(r.Roots, before, r.GeneratorNames[gen])
+			}
+		}()
+	}
+	wg.Wait()
+
+	hadErrs := false
+	for i := range gens {
+		if stdouts[i] != nil {
+			if _, err := stdouts[i].WriteTo(os.Stdout); err != nil {
+				r.reportError(gens[i], err)
+				hadErrs = true
+			}
+		}
+		if r.reportWarnings(gens[i], warnings[i]) {
+			hadErrs = true
+		}
+		if errs[i] != nil {
+			r.reportError(gens[i], errs[i])
+			hadErrs = true
+		}
+	}
+	return hadErrs
+}
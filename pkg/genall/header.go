@@ -0,0 +1,271 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/version"
+)
+
+// ArtifactKind is a kind of generated artifact, which determines the comment
+// syntax that headers are wrapped in.
+type ArtifactKind int
+
+const (
+	// GoArtifact is Go source code, which uses Go comments.
+	GoArtifact ArtifactKind = iota
+	// YAMLArtifact is a YAML file, which uses "#" comments.
+	YAMLArtifact
+)
+
+// HeaderData contains the variables available to header templates.
+type HeaderData struct {
+	// Year is the year given to the generator (usually with its year
+	// option), or empty if none was given.
+	Year string
+	// Generator is the name of the generator producing the artifact (e.g.
+	// "crd"), if known.
+	Generator string
+	// Package is the import path of the package the artifact was generated
+	// for, for package-associated artifacts (like Go code), or empty
+	// otherwise.
+	Package string
+	// Version is the version of controller-gen.
+	Version string
+}
+
+// Header is the header text (e.g. a license) to prepend to generated
+// artifacts.
+//
+// Header files that refer to the fields of HeaderData (e.g.
+// `{{ .Generator }}`) are Go text/template templates, executed with
+// HeaderData.  Other header files are used as-is, even if they happen to
+// contain "{{".  Either way, " YEAR" is replaced with the year, for
+// compatibility.
+//
+// Header files may be plain text, or already be comments.  Plain text is
+// wrapped in the comment syntax for each kind of artifact ("//" lines for
+// Go, and "#" lines for YAML).  Comments in the syntax for a kind of
+// artifact are left as-is for it, while those in another syntax are
+// converted, so the same header file works for both Go and YAML artifacts.
+type Header struct {
+	// tmpl is the header's template, if it's a template.
+	tmpl *template.Template
+	// text is the header's text, if it's not a template.
+	text string
+	data HeaderData
+}
+
+// headerDataReference matches what looks like a reference to a field of
+// HeaderData in a template action, for reporting templates that fail to
+// parse.
+var headerDataReference = regexp.MustCompile(`\{\{-?\s*\.(Year|Generator|Package|Version)\b`)
+
+// LoadHeader loads the header from the given file for the Generator being
+// run, using the context's InputRule.  The given year is used for the
+// header's Year.  An empty path produces an empty header.
+func (g GenerationContext) LoadHeader(path, year string) (*Header, error) {
+	header := &Header{
+		data: HeaderData{
+			Year:      year,
+			Generator: g.generatorName,
+			Version:   version.Version(),
+		},
+	}
+	if path == "" {
+		return header, nil
+	}
+
+	contents, err := g.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(path).Option("missingkey=error").Parse(string(contents))
+	switch {
+	case err != nil && headerDataReference.Match(contents):
+		return nil, fmt.Errorf("unable to parse header file %s: %w", path, err)
+	case err != nil || !referencesHeaderData(tmpl.Root):
+		// not a template, just text that might contain "{{"
+		header.text = string(contents)
+	default:
+		header.tmpl = tmpl
+	}
+	return header, nil
+}
+
+// referencesHeaderData checks if the given template node refers to any of
+// the fields of HeaderData.
+func referencesHeaderData(node parse.Node) bool {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return false
+		}
+		for _, child := range node.Nodes {
+			if referencesHeaderData(child) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return referencesHeaderData(node.Pipe)
+	case *parse.PipeNode:
+		if node == nil {
+			return false
+		}
+		for _, cmd := range node.Cmds {
+			if referencesHeaderData(cmd) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			if referencesHeaderData(arg) {
+				return true
+			}
+		}
+	case *parse.FieldNode:
+		_, isField := reflect.TypeOf(HeaderData{}).FieldByName(node.Ident[0])
+		return isField
+	case *parse.IfNode:
+		return referencesBranchHeaderData(&node.BranchNode)
+	case *parse.RangeNode:
+		return referencesBranchHeaderData(&node.BranchNode)
+	case *parse.WithNode:
+		return referencesBranchHeaderData(&node.BranchNode)
+	case *parse.TemplateNode:
+		return referencesHeaderData(node.Pipe)
+	}
+	return false
+}
+
+// referencesBranchHeaderData checks if the given if, range, or with node
+// refers to any of the fields of HeaderData.
+func referencesBranchHeaderData(node *parse.BranchNode) bool {
+	return referencesHeaderData(node.Pipe) || referencesHeaderData(node.List) || referencesHeaderData(node.ElseList)
+}
+
+// Render renders the header for an artifact of the given kind, associated
+// with the given package (or nil, for artifacts not associated with a
+// package).
+func (h *Header) Render(kind ArtifactKind, pkg *loader.Package) (string, error) {
+	text := h.text
+	if h.tmpl != nil {
+		data := h.data
+		if pkg != nil {
+			data.Package = pkg.PkgPath
+		}
+		var out bytes.Buffer
+		if err := h.tmpl.Execute(&out, data); err != nil {
+			return "", fmt.Errorf("unable to render header file %s: %w", h.tmpl.Name(), err)
+		}
+		text = out.String()
+	}
+	text = strings.ReplaceAll(text, " YEAR", " "+h.data.Year)
+
+	return commentFor(kind, text), nil
+}
+
+// commentFor returns the given text as a comment in the syntax used by the
+// given kind of artifact.
+func commentFor(kind ArtifactKind, text string) string {
+	if strings.TrimSpace(text) == "" {
+		return text
+	}
+	switch kind {
+	case GoArtifact:
+		if isBlockComment(text) || isLineComment(text, "//") {
+			return text
+		}
+		return lineComment(uncomment(text), "//")
+	case YAMLArtifact:
+		if isLineComment(text, "#") {
+			return text
+		}
+		return lineComment(uncomment(text), "#")
+	default:
+		return text
+	}
+}
+
+// lineComment comments out each line of the given plain text with the given
+// prefix.
+func lineComment(text, prefix string) string {
+	var out strings.Builder
+	for line := range strings.Lines(text) {
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			out.WriteString(prefix + "\n")
+			continue
+		}
+		out.WriteString(prefix + " " + line + "\n")
+	}
+	return out.String()
+}
+
+// isBlockComment checks if the given text is a single /* */ comment.
+func isBlockComment(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.HasPrefix(text, "/*") && strings.HasSuffix(text, "*/") &&
+		strings.Index(text, "*/") == len(text)-len("*/")
+}
+
+// isLineComment checks if every non-blank line of the given text is a
+// comment starting with the given prefix.
+func isLineComment(text, prefix string) bool {
+	for line := range strings.Lines(text) {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// uncomment strips the comment syntax (if any) from the given text,
+// returning the plain text with a trailing newline.
+func uncomment(text string) string {
+	var prefix string
+	switch {
+	case isBlockComment(text):
+		text = strings.TrimSpace(text)
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+		text = strings.TrimPrefix(text, "\n")
+	case isLineComment(text, "//"):
+		prefix = "//"
+	case isLineComment(text, "#"):
+		prefix = "#"
+	}
+
+	var out strings.Builder
+	for line := range strings.Lines(text) {
+		line = strings.TrimSuffix(line, "\n")
+		if prefix != "" {
+			line = strings.TrimPrefix(strings.TrimSpace(line), prefix)
+			line = strings.TrimPrefix(line, " ")
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,112 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/tools/go/packages"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// headerGenerator renders a header file for both Go and YAML artifacts.
+type headerGenerator struct {
+	path    string
+	goOut   *string
+	yamlOut *string
+}
+
+func (headerGenerator) RegisterMarkers(*markers.Registry) error { return nil }
+
+func (g headerGenerator) Generate(ctx *genall.GenerationContext) error {
+	header, err := ctx.LoadHeader(g.path, "2042")
+	if err != nil {
+		return err
+	}
+	if *g.goOut, err = header.Render(genall.GoArtifact, ctx.Roots[0]); err != nil {
+		return err
+	}
+	*g.yamlOut, err = header.Render(genall.YAMLArtifact, nil)
+	return err
+}
+
+var _ = Describe("Header files", func() {
+	var goOut, yamlOut string
+
+	render := func(contents string) (failed bool) {
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/header\n\ngo 1.22\n"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0o644)).To(Succeed())
+		headerPath := filepath.Join(dir, "header.txt")
+		Expect(os.WriteFile(headerPath, []byte(contents), 0o644)).To(Succeed())
+
+		gen := genall.Generator(headerGenerator{path: headerPath, goOut: &goOut, yamlOut: &yamlOut})
+		rt, err := genall.Generators{&gen}.ForRootsWithConfig(&packages.Config{Dir: dir}, "./...")
+		Expect(err).NotTo(HaveOccurred())
+		rt.GeneratorNames = map[*genall.Generator]string{&gen: "header"}
+		rt.OutputRules = genall.OutputRules{Default: genall.OutputToNothing}
+		rt.ErrorWriter = GinkgoWriter
+		return rt.Run()
+	}
+
+	It("should render the template variables", func() {
+		Expect(render("Copyright {{ .Year }}, generated by {{ .Generator }} for {{ .Package }}.\n")).To(BeFalse())
+		Expect(goOut).To(Equal("// Copyright 2042, generated by header for example.com/header.\n"))
+		Expect(yamlOut).To(Equal("# Copyright 2042, generated by header for .\n"))
+	})
+
+	It("should still replace \" YEAR\" with the year", func() {
+		Expect(render("Copyright YEAR.\n")).To(BeFalse())
+		Expect(goOut).To(Equal("// Copyright 2042.\n"))
+	})
+
+	It("should wrap plain text in the comment syntax for each artifact kind", func() {
+		Expect(render("Licensed under the thing.\n\nSee the thing.\n")).To(BeFalse())
+		Expect(goOut).To(Equal("// Licensed under the thing.\n//\n// See the thing.\n"))
+		Expect(yamlOut).To(Equal("# Licensed under the thing.\n#\n# See the thing.\n"))
+	})
+
+	It("should keep Go comments for Go artifacts, and convert them for YAML ones", func() {
+		Expect(render("/*\nCopyright YEAR.\n\nLicensed under the thing.\n*/\n")).To(BeFalse())
+		Expect(goOut).To(Equal("/*\nCopyright 2042.\n\nLicensed under the thing.\n*/\n"))
+		Expect(yamlOut).To(Equal("# Copyright 2042.\n#\n# Licensed under the thing.\n"))
+	})
+
+	It("should keep YAML comments for YAML artifacts, and convert them for Go ones", func() {
+		Expect(render("# Copyright YEAR.\n")).To(BeFalse())
+		Expect(goOut).To(Equal("// Copyright 2042.\n"))
+		Expect(yamlOut).To(Equal("# Copyright 2042.\n"))
+	})
+
+	It("should use headers that don't refer to any template variables as-is", func() {
+		Expect(render("Copyright YEAR.  Use {{ .Nope }} or {{ in your templates.\n")).To(BeFalse())
+		Expect(goOut).To(Equal("// Copyright 2042.  Use {{ .Nope }} or {{ in your templates.\n"))
+	})
+
+	It("should fail on unknown template variables in templates", func() {
+		Expect(render("{{ .Year }} {{ .Nope }}\n")).To(BeTrue())
+	})
+
+	It("should fail on templates that don't parse", func() {
+		Expect(render("Copyright {{ .Year }.\n")).To(BeTrue())
+	})
+})
@@ -0,0 +1,419 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+	kyaml "sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// helmPlaceholders replaces the stand-ins for Helm placeholders that are
+// set in objects before they're marshaled, so that the placeholders
+// themselves aren't quoted or wrapped.
+var helmPlaceholders = strings.NewReplacer(
+	helmNamespace, "{{ .Values.namespace | default .Release.Namespace }}",
+	helmNamePrefix, "{{ .Values.namePrefix }}",
+	helmWebhookServiceName, "{{ .Values.webhook.service.name }}",
+	helmWebhookServiceNamespace, "{{ .Values.webhook.service.namespace | default (.Values.namespace | default .Release.Namespace) }}",
+)
+
+const (
+	// helmNamespace stands in for the namespace of namespaced objects.
+	helmNamespace = "__HELM_NAMESPACE__"
+	// helmNamePrefix stands in for the prefix of object names.
+	helmNamePrefix = "__HELM_NAME_PREFIX__"
+	// helmWebhookServiceName stands in for the name of the webhook service
+	// (without the name prefix).
+	helmWebhookServiceName = "__HELM_WEBHOOK_SERVICE_NAME__"
+	// helmWebhookServiceNamespace stands in for the namespace of the webhook
+	// service.
+	helmWebhookServiceNamespace = "__HELM_WEBHOOK_SERVICE_NAMESPACE__"
+	// helmCertManagerAnnotation is the (conditional) cert-manager CA
+	// injection annotation, to be inserted into a metadata.annotations map
+	// (before replacing the placeholders).
+	helmCertManagerAnnotation = `    {{- if .Values.webhook.certManager.enabled }}
+    cert-manager.io/inject-ca-from: ` + helmNamespace + `/` + helmNamePrefix + `{{ .Values.webhook.certManager.certificate }}
+    {{- end }}
+`
+	// helmCertManagerAnnotations is the (conditional) metadata.annotations
+	// map containing just the cert-manager CA injection annotation, to be
+	// inserted into metadata (before replacing the placeholders).
+	helmCertManagerAnnotations = `  {{- if .Values.webhook.certManager.enabled }}
+  annotations:
+    cert-manager.io/inject-ca-from: ` + helmNamespace + `/` + helmNamePrefix + `{{ .Values.webhook.certManager.certificate }}
+  {{- end }}
+`
+
+	// helmValues are the defaults for the values referred to by the
+	// placeholders.
+	helmValues = `# namePrefix is prepended to the names of the generated RBAC and webhook
+# configurations (and the webhook service).
+namePrefix: ""
+# namespace is the namespace of namespaced objects, defaulting to the
+# release's namespace.
+namespace: ""
+webhook:
+  service:
+    # name is the name of the service that serves the webhooks.
+    name: webhook-service
+    # namespace is the namespace of the webhook service, defaulting to
+    # the namespace above.
+    namespace: ""
+  certManager:
+    # enabled adds cert-manager's CA injection annotation to the webhook
+    # configurations.
+    enabled: false
+    # certificate is the name of the cert-manager Certificate (in the
+    # namespace above) whose CA is injected.
+    certificate: serving-cert
+`
+)
+
+// +controllertools:marker:generateHelp:category=""
+
+// OutputToHelm outputs artifacts into a Helm chart.
+//
+// CustomResourceDefinitions are written as-is to the chart's crds
+// directory.  Other config (like RBAC and webhook configurations) is
+// written to its templates directory, with Helm placeholders for the
+// namespace, a name prefix, the webhook service's name and namespace, and
+// cert-manager's CA injection annotation.  The defaults for the values
+// these use are added to the chart's values.yaml, keeping any existing
+// values.  Package-associated artifacts (code) are written alongside their
+// packages.
+type OutputToHelm struct {
+	// Chart is the directory of the Helm chart to write to.
+	Chart string
+
+	// valuesMu serializes updates to the chart's values.yaml, if set.  It's
+	// shared by the rules of generators that write to the same chart at
+	// once (see withValuesLock).
+	valuesMu *sync.Mutex
+	// crds and files, if set, replace the rules that write the chart's CRDs
+	// (given paths relative to the chart) and its other files and code (see
+	// mapFileRules), e.g. to check the files instead of writing them.
+	crds, files FileOutputRule
+}
+
+func (o OutputToHelm) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	if pkg != nil {
+		return o.filesRule().Open(pkg, itemPath)
+	}
+	return &bufferedArtifact{onClose: func(contents []byte) error {
+		if err := o.write(itemPath, contents); err != nil {
+			return fmt.Errorf("unable to write %s to Helm chart %s: %w", itemPath, o.Chart, err)
+		}
+		return nil
+	}}, nil
+}
+
+// PathFor returns the path that code is written to, or the path in the
+// chart's templates directory for config, although config consisting
+// only of CRDs is written to its crds directory instead.  An empty itemPath
+// yields the chart's directory.
+func (o OutputToHelm) PathFor(pkg *loader.Package, itemPath string) (string, error) {
+	if pkg != nil || itemPath == "" {
+		return o.filesRule().PathFor(pkg, itemPath)
+	}
+	return o.filesRule().PathFor(nil, filepath.Join("templates", itemPath))
+}
+
+func (o OutputToHelm) relativeTo(dir string) OutputRule {
+	o.Chart = pathRelativeTo(dir, o.Chart)
+	return o
+}
+
+// mapFileRules replaces the rules that write the chart's files using the
+// given functions: wrapResources for the CRDs (which are plain resources),
+// and wrapOther for everything else (templates, values.yaml, and code).
+// Nil functions leave the corresponding rules as-is.
+func (o OutputToHelm) mapFileRules(wrapResources, wrapOther func(FileOutputRule) FileOutputRule) OutputRule {
+	if wrapResources != nil {
+		o.crds = wrapResources(o.crdsRule())
+	}
+	if wrapOther != nil {
+		o.files = wrapOther(o.filesRule())
+	}
+	return o
+}
+
+// withValuesLock returns a copy of this rule that serializes updates to the
+// chart's values.yaml using the lock for its chart in the given map (adding
+// one, if needed).
+func (o OutputToHelm) withValuesLock(locks map[string]*sync.Mutex) OutputToHelm {
+	chart := filepath.Clean(o.Chart)
+	if locks[chart] == nil {
+		locks[chart] = &sync.Mutex{}
+	}
+	o.valuesMu = locks[chart]
+	return o
+}
+
+// crdsRule returns the rule that writes the chart's CRDs, which are given
+// paths relative to the chart.
+func (o OutputToHelm) crdsRule() FileOutputRule {
+	if o.crds != nil {
+		return o.crds
+	}
+	return OutputToDirectory(o.Chart)
+}
+
+// filesRule returns the rule that writes the chart's other files (given
+// paths relative to the chart), and code.
+func (o OutputToHelm) filesRule() FileOutputRule {
+	if o.files != nil {
+		return o.files
+	}
+	return OutputArtifacts{Config: OutputToDirectory(o.Chart)}
+}
+
+// write writes the given config artifact to the chart, splitting its CRDs
+// (which are written unchanged) from the rest of its objects.
+func (o OutputToHelm) write(itemPath string, contents []byte) error {
+	header, docs, err := splitYAMLDocuments(contents)
+	if err != nil {
+		return err
+	}
+
+	var crds, templates bytes.Buffer
+	for _, doc := range docs {
+		if doc.object["kind"] == "CustomResourceDefinition" {
+			crds.WriteString("---\n")
+			crds.Write(doc.raw)
+			continue
+		}
+
+		docContents, err := helmTemplateFor(doc.object)
+		if err != nil {
+			return err
+		}
+		templates.WriteString("---\n")
+		templates.WriteString(docContents)
+	}
+
+	if crds.Len() > 0 {
+		if err := writeArtifact(o.crdsRule(), filepath.Join("crds", itemPath), header+crds.String()); err != nil {
+			return err
+		}
+	}
+	if templates.Len() > 0 {
+		if err := writeArtifact(o.filesRule(), filepath.Join("templates", itemPath), header+templates.String()); err != nil {
+			return err
+		}
+		return o.addValues()
+	}
+	return nil
+}
+
+// addValues adds the defaults for the values used by the placeholders to
+// the chart's values.yaml, keeping any existing values.
+func (o OutputToHelm) addValues() error {
+	if o.valuesMu != nil {
+		o.valuesMu.Lock()
+		defer o.valuesMu.Unlock()
+	}
+
+	const valuesItem = "values.yaml"
+	valuesPath, err := o.filesRule().PathFor(nil, valuesItem)
+	if err != nil {
+		return err
+	}
+	existing, err := os.ReadFile(valuesPath)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(bytes.TrimSpace(existing)) == 0) {
+		return writeArtifact(o.filesRule(), valuesItem, helmValues)
+	}
+	if err != nil {
+		return err
+	}
+
+	var values, defaults yaml.Node
+	if err := yaml.Unmarshal(existing, &values); err != nil {
+		return fmt.Errorf("unable to parse %s: %w", valuesPath, err)
+	}
+	if err := yaml.Unmarshal([]byte(helmValues), &defaults); err != nil {
+		return err
+	}
+	if len(values.Content) != 1 || values.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("expected %s to contain a mapping", valuesPath)
+	}
+	if !addMissingKeys(values.Content[0], defaults.Content[0]) {
+		return nil
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&values); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return writeArtifact(o.filesRule(), valuesItem, out.String())
+}
+
+// addMissingKeys adds the keys (and their values) from the src mapping that
+// are missing from the dst mapping, recursing into mappings present in both.
+// It returns true if anything was added.
+func addMissingKeys(dst, src *yaml.Node) bool {
+	changed := false
+	for i := 0; i < len(src.Content)-1; i += 2 {
+		key, srcVal := src.Content[i], src.Content[i+1]
+		var dstVal *yaml.Node
+		for j := 0; j < len(dst.Content)-1; j += 2 {
+			if dst.Content[j].Value == key.Value {
+				dstVal = dst.Content[j+1]
+				break
+			}
+		}
+		switch {
+		case dstVal == nil:
+			dst.Content = append(dst.Content, key, srcVal)
+			changed = true
+		case dstVal.Kind == yaml.MappingNode && srcVal.Kind == yaml.MappingNode:
+			changed = addMissingKeys(dstVal, srcVal) || changed
+		}
+	}
+	return changed
+}
+
+// helmTemplateFor marshals the given object, replacing its name, namespace,
+// and webhook services with Helm placeholders.  Names are kept as suffixes
+// after the name prefix.
+func helmTemplateFor(doc map[string]any) (string, error) {
+	meta, _ := doc["metadata"].(map[string]any)
+	if meta == nil {
+		meta = make(map[string]any)
+		doc["metadata"] = meta
+	}
+	if name, hasName := meta["name"].(string); hasName {
+		meta["name"] = helmNamePrefix + name
+	}
+
+	if _, isNamespaced := meta["namespace"]; isNamespaced {
+		meta["namespace"] = helmNamespace
+	}
+
+	kind, _ := doc["kind"].(string)
+	isWebhookConfig := kind == "MutatingWebhookConfiguration" || kind == "ValidatingWebhookConfiguration"
+	if isWebhookConfig {
+		webhooks, _ := doc["webhooks"].([]any)
+		for _, webhook := range webhooks {
+			webhook, _ := webhook.(map[string]any)
+			clientConfig, _ := webhook["clientConfig"].(map[string]any)
+			service, _ := clientConfig["service"].(map[string]any)
+			if service == nil {
+				continue
+			}
+			service["name"] = helmNamePrefix + helmWebhookServiceName
+			service["namespace"] = helmWebhookServiceNamespace
+		}
+	}
+
+	contents, err := kyaml.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	text := string(contents)
+	if isWebhookConfig {
+		if _, hasAnnotations := meta["annotations"]; hasAnnotations {
+			text = strings.Replace(text, "\n  annotations:\n", "\n  annotations:\n"+helmCertManagerAnnotation, 1)
+		} else {
+			text = strings.Replace(text, "\nmetadata:\n", "\nmetadata:\n"+helmCertManagerAnnotations, 1)
+		}
+	}
+	return helmPlaceholders.Replace(text), nil
+}
+
+// yamlDocument is a single document from a YAML stream.
+type yamlDocument struct {
+	// raw is the document's source (without the separator before it), so
+	// that it can be written out unchanged.
+	raw []byte
+	// object is the decoded document.
+	object map[string]any
+}
+
+// splitYAMLDocuments splits the given YAML stream into its leading comments
+// (like a license header) and its (non-empty) documents.
+func splitYAMLDocuments(contents []byte) (string, []yamlDocument, error) {
+	var header strings.Builder
+	var docs []yamlDocument
+	var current bytes.Buffer
+	endDocument := func() error {
+		raw := bytes.Clone(current.Bytes())
+		current.Reset()
+		var object map[string]any
+		if err := yaml.Unmarshal(raw, &object); err != nil {
+			return err
+		}
+		if object == nil {
+			return nil
+		}
+		if !bytes.HasSuffix(raw, []byte("\n")) {
+			raw = append(raw, '\n')
+		}
+		docs = append(docs, yamlDocument{raw: raw, object: object})
+		return nil
+	}
+
+	inHeader := true
+	for line := range strings.Lines(string(contents)) {
+		if inHeader && (strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "") {
+			header.WriteString(line)
+			continue
+		}
+		inHeader = false
+		if strings.TrimRight(line, " \t\r\n") == "---" {
+			if err := endDocument(); err != nil {
+				return "", nil, err
+			}
+			continue
+		}
+		current.WriteString(line)
+	}
+	if err := endDocument(); err != nil {
+		return "", nil, err
+	}
+	return header.String(), docs, nil
+}
+
+// writeArtifact writes the given contents to the given config artifact
+// using the given rule.
+func writeArtifact(rule OutputRule, itemPath, contents string) error {
+	out, err := rule.Open(nil, itemPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(out, contents); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,245 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+)
+
+const helmCRD = `# a license header
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  annotations:
+    controller-gen.kubebuilder.io/version: (devel)
+  name: foos.bar.example.com
+spec:
+  group: bar.example.com
+  names:
+    plural: foos
+    kind: Foo
+  scope: Namespaced
+`
+
+const helmConfig = `---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: manager-role
+rules:
+- apiGroups:
+  - bar.example.com
+  resources:
+  - foos
+  verbs:
+  - get
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: manager-role
+  namespace: system
+rules: []
+---
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: validating-webhook-configuration
+webhooks:
+- clientConfig:
+    service:
+      name: webhook-service
+      namespace: system
+      path: /validate
+  name: vfoo.bar.example.com
+`
+
+var _ = Describe("OutputToHelm", func() {
+	var chartDir string
+	var gen fakeGenerator
+
+	BeforeEach(func() {
+		chartDir = GinkgoT().TempDir()
+		gen = fakeGenerator{artifacts: map[string]string{
+			"bar.example.com_foos.yaml": helmCRD,
+			"manifests.yaml":            helmConfig,
+		}}
+	})
+
+	// render renders the given template like Helm would (with a stand-in
+	// for Sprig's default function), returning the resulting objects.
+	render := func(path string, values map[string]any) []map[string]any {
+		contents, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		tmpl, err := template.New(path).Funcs(template.FuncMap{
+			"default": func(def, val any) any {
+				if val == nil || val == "" {
+					return def
+				}
+				return val
+			},
+		}).Parse(string(contents))
+		Expect(err).NotTo(HaveOccurred())
+
+		var out strings.Builder
+		Expect(tmpl.Execute(&out, map[string]any{
+			"Values":  values,
+			"Release": map[string]any{"Namespace": "prod"},
+		})).To(Succeed())
+
+		var objs []map[string]any
+		for _, doc := range strings.Split(out.String(), "---\n")[1:] {
+			var obj map[string]any
+			Expect(yaml.Unmarshal([]byte(doc), &obj)).To(Succeed())
+			objs = append(objs, obj)
+		}
+		return objs
+	}
+	values := func() map[string]any {
+		contents, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		var values map[string]any
+		Expect(yaml.Unmarshal(contents, &values)).To(Succeed())
+		return values
+	}
+
+	It("should write CRDs as-is to the crds directory", func() {
+		Expect(runtimeFor(gen, genall.OutputToHelm{Chart: chartDir}).Run()).To(BeFalse())
+
+		Expect(os.ReadFile(filepath.Join(chartDir, "crds", "bar.example.com_foos.yaml"))).To(BeEquivalentTo(helmCRD))
+		Expect(filepath.Join(chartDir, "templates", "bar.example.com_foos.yaml")).NotTo(BeAnExistingFile())
+	})
+
+	It("should write other config to the templates directory with placeholders, using the default values", func() {
+		Expect(runtimeFor(gen, genall.OutputToHelm{Chart: chartDir}).Run()).To(BeFalse())
+
+		objs := render(filepath.Join(chartDir, "templates", "manifests.yaml"), values())
+		Expect(objs).To(HaveLen(3))
+		Expect(objs[0]["metadata"]).To(Equal(map[string]any{"name": "manager-role"}))
+		Expect(objs[1]["metadata"]).To(Equal(map[string]any{"name": "manager-role", "namespace": "prod"}))
+		Expect(objs[2]["metadata"]).To(Equal(map[string]any{"name": "validating-webhook-configuration"}))
+		Expect(objs[2]["webhooks"]).To(Equal([]any{map[string]any{
+			"clientConfig": map[string]any{"service": map[string]any{
+				"name":      "webhook-service",
+				"namespace": "prod",
+				"path":      "/validate",
+			}},
+			"name": "vfoo.bar.example.com",
+		}}))
+	})
+
+	It("should use the given values for the placeholders", func() {
+		Expect(runtimeFor(gen, genall.OutputToHelm{Chart: chartDir}).Run()).To(BeFalse())
+
+		objs := render(filepath.Join(chartDir, "templates", "manifests.yaml"), map[string]any{
+			"namePrefix": "foo-",
+			"namespace":  "foo-system",
+			"webhook": map[string]any{
+				"service":     map[string]any{"name": "webhooks", "namespace": ""},
+				"certManager": map[string]any{"enabled": true, "certificate": "cert"},
+			},
+		})
+		Expect(objs[0]["metadata"]).To(Equal(map[string]any{"name": "foo-manager-role"}))
+		Expect(objs[1]["metadata"]).To(Equal(map[string]any{"name": "foo-manager-role", "namespace": "foo-system"}))
+		Expect(objs[2]["metadata"]).To(Equal(map[string]any{
+			"name":        "foo-validating-webhook-configuration",
+			"annotations": map[string]any{"cert-manager.io/inject-ca-from": "foo-system/foo-cert"},
+		}))
+		Expect(objs[2]["webhooks"]).To(ContainElement(HaveKeyWithValue("clientConfig", map[string]any{
+			"service": map[string]any{"name": "foo-webhooks", "namespace": "foo-system", "path": "/validate"},
+		})))
+	})
+
+	It("should add missing defaults to an existing values.yaml, keeping existing values", func() {
+		Expect(os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte(`# our values
+image: controller:latest
+namePrefix: foo-
+webhook:
+  service:
+    name: webhooks
+`), 0o644)).To(Succeed())
+
+		Expect(runtimeFor(gen, genall.OutputToHelm{Chart: chartDir}).Run()).To(BeFalse())
+
+		Expect(values()).To(Equal(map[string]any{
+			"image":      "controller:latest",
+			"namePrefix": "foo-",
+			"namespace":  "",
+			"webhook": map[string]any{
+				"service":     map[string]any{"name": "webhooks", "namespace": ""},
+				"certManager": map[string]any{"enabled": false, "certificate": "serving-cert"},
+			},
+		}))
+		Expect(os.ReadFile(filepath.Join(chartDir, "values.yaml"))).To(HavePrefix("# our values\n"))
+	})
+
+	It("should check the chart's files", func() {
+		Expect(runtimeFor(gen, genall.OutputToHelm{Chart: chartDir}).Run()).To(BeFalse())
+		crdPath := filepath.Join(chartDir, "crds", "bar.example.com_foos.yaml")
+		Expect(os.WriteFile(crdPath, []byte(strings.Replace(helmCRD, "Namespaced", "Cluster", 1)), 0o644)).To(Succeed())
+		Expect(os.Remove(filepath.Join(chartDir, "values.yaml"))).To(Succeed())
+
+		checker := &genall.OutputChecker{}
+		rt := runtimeFor(gen, genall.OutputToHelm{Chart: chartDir})
+		Expect(checker.WrapOutputRules(rt)).To(Succeed())
+		Expect(rt.Run()).To(BeFalse())
+
+		out := &strings.Builder{}
+		stale, err := checker.Report(out)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stale).To(BeTrue())
+		Expect(out.String()).To(ContainSubstring("-  scope: Cluster\n+  scope: Namespaced\n"))
+		Expect(out.String()).To(ContainSubstring("+namePrefix: \"\"\n"))
+		Expect(out.String()).NotTo(ContainSubstring("manifests.yaml"))
+
+		By("checking that nothing was written")
+		Expect(filepath.Join(chartDir, "values.yaml")).NotTo(BeAnExistingFile())
+	})
+
+	It("should list only the CRDs in a kustomization", func() {
+		kustomizer := &genall.Kustomizer{}
+		rt := runtimeFor(gen, genall.OutputToHelm{Chart: chartDir})
+		Expect(kustomizer.WrapOutputRules(rt)).To(Succeed())
+		Expect(rt.Run()).To(BeFalse())
+		Expect(kustomizer.Write()).To(Succeed())
+
+		Expect(os.ReadFile(filepath.Join(chartDir, genall.KustomizationFileName))).To(ContainSubstring("- crds/bar.example.com_foos.yaml # generated by controller-gen\n"))
+		Expect(filepath.Join(chartDir, "crds", genall.KustomizationFileName)).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(chartDir, "templates", genall.KustomizationFileName)).NotTo(BeAnExistingFile())
+	})
+
+	It("should update the values of a chart written by several generators at once", func() {
+		var other genall.Generator = fakeGenerator{artifacts: map[string]string{"other.yaml": helmConfig}}
+		rt := runtimeFor(gen, genall.OutputToHelm{Chart: chartDir})
+		rt.Generators = append(rt.Generators, &other)
+		rt.Parallelism = 2
+		Expect(rt.Run()).To(BeFalse())
+
+		Expect(values()).To(HaveKeyWithValue("namePrefix", ""))
+		Expect(filepath.Join(chartDir, "templates", "other.yaml")).To(BeAnExistingFile())
+	})
+})
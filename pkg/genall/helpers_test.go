@@ -0,0 +1,57 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// fakeGenerator writes a fixed set of config artifacts.
+type fakeGenerator struct {
+	artifacts map[string]string
+}
+
+func (fakeGenerator) RegisterMarkers(*markers.Registry) error { return nil }
+
+func (g fakeGenerator) Generate(ctx *genall.GenerationContext) error {
+	for path, contents := range g.artifacts {
+		out, err := ctx.Open(nil, path)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(out, contents); err != nil {
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runtimeFor produces a runtime for the given generator, with no roots.
+func runtimeFor(gen genall.Generator, rule genall.OutputRule) *genall.Runtime {
+	return &genall.Runtime{
+		Generators:  genall.Generators{&gen},
+		OutputRules: genall.OutputRules{Default: rule},
+		ErrorWriter: GinkgoWriter,
+	}
+}
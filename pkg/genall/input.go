@@ -17,8 +17,12 @@ limitations under the License.
 package genall
 
 import (
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
+	"path/filepath"
 )
 
 // InputRule describes how to load non-code boilerplate artifacts.
@@ -27,11 +31,69 @@ type InputRule interface {
 	// OpenForRead opens the given non-code artifact for reading.
 	OpenForRead(path string) (io.ReadCloser, error)
 }
+
+// DirectoryInputRule is an InputRule that can also list directories (e.g.
+// of manifests to read).  See GenerationContext.ReadDir.
+type DirectoryInputRule interface {
+	InputRule
+	// ReadDir lists the entries of the given directory, sorted by name.
+	ReadDir(path string) ([]fs.DirEntry, error)
+}
+
 type inputFromFileSystem struct{}
 
 func (inputFromFileSystem) OpenForRead(path string) (io.ReadCloser, error) {
 	return os.Open(path)
 }
 
+func (inputFromFileSystem) ReadDir(path string) ([]fs.DirEntry, error) {
+	return os.ReadDir(path)
+}
+
 // InputFromFileSystem reads from the filesystem as normal.
 var InputFromFileSystem = inputFromFileSystem{}
+
+// InputFromDirectory reads from the filesystem, with relative paths relative
+// to the given directory instead of the working directory.
+func InputFromDirectory(dir string) InputRule {
+	return inputFromDirectory{dir: dir}
+}
+
+type inputFromDirectory struct {
+	dir string
+}
+
+func (i inputFromDirectory) OpenForRead(path string) (io.ReadCloser, error) {
+	return os.Open(pathRelativeTo(i.dir, path))
+}
+
+func (i inputFromDirectory) ReadDir(path string) ([]fs.DirEntry, error) {
+	return os.ReadDir(pathRelativeTo(i.dir, path))
+}
+
+// InputFromFS reads from the given fs.FS (e.g. an embed.FS, or an
+// fstest.MapFS in tests).  Paths are interpreted relative to the root of
+// the FS, so they must be relative (a leading "./" is ignored).
+func InputFromFS(fsys fs.FS) InputRule {
+	return inputFromFS{fsys: fsys}
+}
+
+type inputFromFS struct {
+	fsys fs.FS
+}
+
+func (i inputFromFS) OpenForRead(filePath string) (io.ReadCloser, error) {
+	name := path.Clean(filepath.ToSlash(filePath))
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: filePath, Err: fmt.Errorf("%w: paths must be relative to the root of the FS", fs.ErrInvalid)}
+	}
+	return i.fsys.Open(name)
+}
+
+func (i inputFromFS) ReadDir(filePath string) ([]fs.DirEntry, error) {
+	name := path.Clean(filepath.ToSlash(filePath))
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: filePath, Err: fmt.Errorf("%w: paths must be relative to the root of the FS", fs.ErrInvalid)}
+	}
+	return fs.ReadDir(i.fsys, name)
+}
@@ -0,0 +1,274 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// KustomizationFileName is the name of the kustomization files written by
+// a Kustomizer.
+const KustomizationFileName = "kustomization.yaml"
+
+// Kustomizer records the YAML artifacts written via FileOutputRules, so that
+// a kustomization.yaml listing them as resources can be written to each
+// output directory once generation has finished.
+//
+// Existing kustomization files are merged with, rather than overwritten:
+// resources that weren't generated are kept, as is everything else in the
+// file.  Generated resources are marked with a comment, so that their
+// entries can be removed once the files themselves are (e.g. by an
+// OutputSyncer), while entries for files that still exist are kept, so that
+// several runs can share a directory.
+//
+// Call WrapOutputRules on a Runtime before running it (after wrapping it
+// with an OutputChecker or OutputSyncer, if any, so that kustomization files
+// are checked or synced too), then call Write.
+type Kustomizer struct {
+	mu sync.Mutex
+	// dirs maps the absolute path of each output directory to the
+	// resources written to it.
+	dirs map[string]*kustomizedDir
+}
+
+// kustomizedDir is an output directory that YAML artifacts were written to.
+type kustomizedDir struct {
+	rule FileOutputRule
+	// resources are the slash-separated paths of the YAML artifacts written
+	// to the directory, relative to it.
+	resources map[string]struct{}
+}
+
+// WrapOutputRules replaces the given runtime's output rules, such that the
+// YAML artifacts written via each FileOutputRule are recorded.  For Helm
+// charts, only the CRDs are recorded (in a kustomization.yaml in the chart's
+// directory), since templates aren't plain resources.
+func (k *Kustomizer) WrapOutputRules(rt *Runtime) error {
+	mapFileRules(rt, func(rule FileOutputRule) FileOutputRule {
+		return kustomizeOutput{rule: rule, kustomizer: k}
+	}, nil)
+	return nil
+}
+
+// record records that a YAML artifact was written to the given item path
+// via the given rule.
+func (k *Kustomizer) record(rule FileOutputRule, itemPath string) error {
+	dir, err := rule.PathFor(nil, "")
+	if err != nil {
+		return err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.dirs == nil {
+		k.dirs = make(map[string]*kustomizedDir)
+	}
+	kustomized, known := k.dirs[absDir]
+	if !known {
+		kustomized = &kustomizedDir{rule: rule, resources: make(map[string]struct{})}
+		k.dirs[absDir] = kustomized
+	}
+	kustomized.resources[path.Clean(filepath.ToSlash(itemPath))] = struct{}{}
+	return nil
+}
+
+// Write writes a kustomization.yaml to each directory that YAML artifacts
+// were written to, merging with any existing one.  It should only be called
+// after a successful run.
+func (k *Kustomizer) Write() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	dirs := make([]string, 0, len(k.dirs))
+	for dir := range k.dirs {
+		dirs = append(dirs, dir)
+	}
+	slices.Sort(dirs)
+
+	for _, dir := range dirs {
+		kustomized := k.dirs[dir]
+		existing, err := os.ReadFile(filepath.Join(dir, KustomizationFileName))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		contents, err := mergeKustomization(existing, dir, kustomized.resources)
+		if err != nil {
+			return fmt.Errorf("unable to update %s: %w", displayPath(filepath.Join(dir, KustomizationFileName)), err)
+		}
+
+		out, err := kustomized.rule.Open(nil, KustomizationFileName)
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(contents); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeKustomization merges the given generated resources into the given
+// existing kustomization (if any) from the given directory, returning the
+// new contents.  Existing contents are returned as-is if their resources
+// don't need to change.
+func mergeKustomization(existing []byte, dir string, generated map[string]struct{}) ([]byte, error) {
+	if len(bytes.TrimSpace(existing)) == 0 {
+		existing = []byte("apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\n")
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(existing, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected a mapping")
+	}
+	root := doc.Content[0]
+
+	var resources *yaml.Node
+	for i := 0; i < len(root.Content)-1; i += 2 {
+		if root.Content[i].Value == "resources" {
+			resources = root.Content[i+1]
+			break
+		}
+	}
+	if resources == nil {
+		resources = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "resources"}, resources)
+	} else if resources.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("expected resources to be a list")
+	}
+
+	changed := false
+	listed := make(map[string]struct{}, len(resources.Content))
+	kept := resources.Content[:0]
+	for _, entry := range resources.Content {
+		if entry.Kind != yaml.ScalarNode {
+			kept = append(kept, entry)
+			continue
+		}
+		if isStaleResource(dir, entry, generated) {
+			changed = true
+			continue
+		}
+		// mark generated entries (e.g. ones added by hand before they were
+		// generated), so that they're removed once they're not.
+		if _, isGenerated := generated[path.Clean(entry.Value)]; isGenerated && entry.LineComment != generatedResourceComment {
+			entry.LineComment = generatedResourceComment
+			changed = true
+		}
+		listed[entry.Value] = struct{}{}
+		kept = append(kept, entry)
+	}
+	resources.Content = kept
+
+	newResources := make([]string, 0, len(generated))
+	for resource := range generated {
+		if _, isListed := listed[resource]; !isListed {
+			newResources = append(newResources, resource)
+		}
+	}
+	slices.Sort(newResources)
+	for _, resource := range newResources {
+		changed = true
+		resources.Content = append(resources.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: resource, LineComment: generatedResourceComment})
+	}
+
+	if !changed {
+		return existing, nil
+	}
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// generatedResourceComment marks the kustomization resources that were
+// listed by controller-gen, so that they can be told apart from ones added
+// by hand once the files they refer to are gone.
+const generatedResourceComment = "# generated by controller-gen"
+
+// isStaleResource checks if the given kustomization resource entry (relative
+// to the given directory) was listed by controller-gen, but refers to a file
+// that no longer exists.  Existing files are kept even if they weren't
+// generated in this run, since they may be generated by other runs writing
+// to the same directory (e.g. with other generators), as are missing files
+// that weren't listed by controller-gen (e.g. ones produced by other tools).
+func isStaleResource(dir string, entry *yaml.Node, generated map[string]struct{}) bool {
+	resource := entry.Value
+	if entry.LineComment != generatedResourceComment {
+		return false
+	}
+	if _, isGenerated := generated[path.Clean(resource)]; isGenerated {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(resource)))
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// isYAMLPath checks if the given path looks like a YAML file.
+func isYAMLPath(itemPath string) bool {
+	ext := path.Ext(filepath.ToSlash(itemPath))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// kustomizeOutput records the YAML config artifacts written via the
+// underlying rule for a Kustomizer.
+type kustomizeOutput struct {
+	rule       FileOutputRule
+	kustomizer *Kustomizer
+}
+
+func (o kustomizeOutput) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	if pkg == nil && isYAMLPath(itemPath) && path.Base(filepath.ToSlash(itemPath)) != KustomizationFileName {
+		if err := o.kustomizer.record(o.rule, itemPath); err != nil {
+			return nil, err
+		}
+	}
+	return o.rule.Open(pkg, itemPath)
+}
+
+func (o kustomizeOutput) PathFor(pkg *loader.Package, itemPath string) (string, error) {
+	return o.rule.PathFor(pkg, itemPath)
+}
@@ -0,0 +1,181 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+)
+
+var _ = Describe("Kustomizer", func() {
+	var outDir string
+	var gen fakeGenerator
+	var kustomizer *genall.Kustomizer
+
+	BeforeEach(func() {
+		outDir = GinkgoT().TempDir()
+		gen = fakeGenerator{artifacts: map[string]string{
+			"foo.yaml":     generatedYAML,
+			"sub/bar.yaml": generatedYAML,
+			"notes.txt":    "not yaml\n",
+		}}
+		kustomizer = &genall.Kustomizer{}
+	})
+
+	run := func(rt *genall.Runtime) {
+		Expect(kustomizer.WrapOutputRules(rt)).To(Succeed())
+		Expect(rt.Run()).To(BeFalse())
+		Expect(kustomizer.Write()).To(Succeed())
+	}
+	kustomization := func() string {
+		contents, err := os.ReadFile(filepath.Join(outDir, genall.KustomizationFileName))
+		Expect(err).NotTo(HaveOccurred())
+		return string(contents)
+	}
+
+	It("should list the generated YAML files, sorted", func() {
+		run(runtimeFor(gen, genall.OutputToDirectory(outDir)))
+
+		Expect(kustomization()).To(Equal(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - foo.yaml # generated by controller-gen
+  - sub/bar.yaml # generated by controller-gen
+`))
+	})
+
+	It("should keep hand-added entries and other fields when merging", func() {
+		Expect(os.WriteFile(filepath.Join(outDir, "handwritten.yaml"), []byte("kind: Foo\n"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(outDir, genall.KustomizationFileName), []byte(`# hand-maintained
+apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: system
+resources:
+  - ../base
+  - handwritten.yaml
+  - foo.yaml
+patches:
+  - path: patch.yaml
+`), 0o644)).To(Succeed())
+
+		run(runtimeFor(gen, genall.OutputToDirectory(outDir)))
+
+		Expect(kustomization()).To(Equal(`# hand-maintained
+apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: system
+resources:
+  - ../base
+  - handwritten.yaml
+  - foo.yaml # generated by controller-gen
+  - sub/bar.yaml # generated by controller-gen
+patches:
+  - path: patch.yaml
+`))
+	})
+
+	It("should only remove entries for missing files that controller-gen listed", func() {
+		Expect(os.WriteFile(filepath.Join(outDir, "other-run.yaml"), []byte(generatedYAML), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(outDir, genall.KustomizationFileName), []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- other-run.yaml # generated by controller-gen
+- gone.yaml # generated by controller-gen
+- by-other-tool.yaml
+- foo.yaml # generated by controller-gen
+- sub/bar.yaml # generated by controller-gen
+`), 0o644)).To(Succeed())
+
+		run(runtimeFor(gen, genall.OutputToDirectory(outDir)))
+
+		Expect(kustomization()).To(Equal(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - other-run.yaml # generated by controller-gen
+  - by-other-tool.yaml
+  - foo.yaml # generated by controller-gen
+  - sub/bar.yaml # generated by controller-gen
+`))
+	})
+
+	It("should settle when separate runs write to the same directory", func() {
+		otherGen := fakeGenerator{artifacts: map[string]string{"role.yaml": generatedYAML}}
+		runBoth := func() {
+			run(runtimeFor(gen, genall.OutputToDirectory(outDir)))
+			kustomizer = &genall.Kustomizer{}
+			run(runtimeFor(otherGen, genall.OutputToDirectory(outDir)))
+			kustomizer = &genall.Kustomizer{}
+		}
+
+		By("running each generator separately, twice")
+		runBoth()
+		settled := kustomization()
+		Expect(settled).To(Equal(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - foo.yaml # generated by controller-gen
+  - sub/bar.yaml # generated by controller-gen
+  - role.yaml # generated by controller-gen
+`))
+		runBoth()
+		Expect(kustomization()).To(Equal(settled))
+
+		By("checking each run's kustomization against the shared directory")
+		for _, g := range []fakeGenerator{gen, otherGen} {
+			checker := &genall.OutputChecker{}
+			rt := runtimeFor(g, genall.OutputToDirectory(outDir))
+			Expect(checker.WrapOutputRules(rt)).To(Succeed())
+			run(rt)
+			kustomizer = &genall.Kustomizer{}
+			var diff strings.Builder
+			checker.Report(&diff)
+			Expect(diff.String()).NotTo(ContainSubstring(genall.KustomizationFileName))
+		}
+	})
+
+	It("should leave up-to-date kustomizations untouched", func() {
+		existing := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- sub/bar.yaml # generated by controller-gen
+- foo.yaml # generated by controller-gen
+`
+		Expect(os.WriteFile(filepath.Join(outDir, genall.KustomizationFileName), []byte(existing), 0o644)).To(Succeed())
+
+		run(runtimeFor(gen, genall.OutputToDirectory(outDir)))
+
+		Expect(kustomization()).To(Equal(existing))
+	})
+
+	It("should be checked along with the other artifacts", func() {
+		checker := &genall.OutputChecker{}
+		rt := runtimeFor(gen, genall.OutputToDirectory(outDir))
+		Expect(checker.WrapOutputRules(rt)).To(Succeed())
+		run(rt)
+
+		Expect(filepath.Join(outDir, genall.KustomizationFileName)).NotTo(BeAnExistingFile())
+		var diff strings.Builder
+		Expect(checker.Report(&diff)).To(BeTrue())
+		Expect(diff.String()).To(ContainSubstring("+  - sub/bar.yaml # generated by controller-gen\n"))
+	})
+})
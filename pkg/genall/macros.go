@@ -0,0 +1,118 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// MacroCategory is the help category that macros are listed under.
+const MacroCategory = "Macros"
+
+// RegisterMacroMarkers registers the marker used to define macros (see
+// markers.Macro) into the given registry.  Runtimes produced by ForRoots do
+// this automatically.
+func RegisterMacroMarkers(into *markers.Registry) error {
+	if err := into.Register(markers.MacroDefinition); err != nil {
+		return err
+	}
+	into.AddHelp(markers.MacroDefinition, markers.Macro{}.Help())
+	return nil
+}
+
+// RegisterMacroHelp registers the given macros into the given registry as
+// argument-less markers, with help describing what they expand to, so that
+// they're listed alongside the other markers in help output.  Each macro is
+// registered for the kinds of node it can be used on, based on the markers
+// in the registry that it expands to.
+func RegisterMacroHelp(into *markers.Registry, macros []markers.Macro) error {
+	type macroDef struct {
+		macro   markers.Macro
+		targets []markers.TargetType
+	}
+	// work out all the targets before registering anything, so that macros
+	// aren't mistaken for the markers they expand to
+	defs := make([]macroDef, len(macros))
+	for i, macro := range macros {
+		defs[i] = macroDef{macro: macro, targets: macro.Targets(into)}
+	}
+	for _, def := range defs {
+		summary := def.macro.Description
+		if summary == "" {
+			summary = fmt.Sprintf("expands to %s", strings.Join(def.macro.Markers(), " "))
+		}
+		for _, target := range def.targets {
+			markerDef, err := markers.MakeDefinition(def.macro.Name, target, struct{}{})
+			if err != nil {
+				return fmt.Errorf("macro %q: %w", def.macro.Name, err)
+			}
+			if err := into.Register(markerDef); err != nil {
+				return fmt.Errorf("macro %q: %w", def.macro.Name, err)
+			}
+			into.AddHelp(markerDef, &markers.DefinitionHelp{
+				Category: MacroCategory,
+				DetailedHelp: markers.DetailedHelp{
+					Summary: summary,
+					Details: "Expands to:\n\n\t" + strings.Join(def.macro.Markers(), "\n\t"),
+				},
+			})
+		}
+	}
+	return nil
+}
+
+// MacrosFromOptions returns the given macros (e.g. from a config file), along
+// with the ones defined in the packages given by the paths in the given
+// options (see FromOptions), for listing with RegistryFromOptions (see
+// RegisterMacroHelp).  Packages are only loaded if the options give paths.
+// Macros defined more than once are only returned the first time.
+func MacrosFromOptions(cfg *packages.Config, optionsRegistry, reg *markers.Registry, options []string, macros []markers.Macro) ([]markers.Macro, error) {
+	protoRt, err := protoFromOptions(optionsRegistry, options)
+	if err != nil {
+		return nil, err
+	}
+	res := append([]markers.Macro(nil), macros...)
+	if len(protoRt.Paths) == 0 {
+		return res, nil
+	}
+
+	roots, err := loader.LoadRootsWithConfig(cfg, protoRt.Paths...)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(macros))
+	for _, macro := range macros {
+		seen[macro.Name] = true
+	}
+	col := &markers.Collector{Registry: reg}
+	for _, root := range roots {
+		for _, macro := range col.MacrosInPackage(root) {
+			if seen[macro.Name] {
+				continue
+			}
+			seen[macro.Name] = true
+			res = append(res, macro)
+		}
+	}
+	return res, nil
+}
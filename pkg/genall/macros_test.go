@@ -0,0 +1,48 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+var _ = Describe("Macro help", func() {
+	It("should register macros for the kinds of node that all their markers are defined for", func() {
+		reg := &markers.Registry{}
+		Expect(reg.Register(markers.Must(markers.MakeDefinition("test:num", markers.DescribesField, 0)))).To(Succeed())
+		Expect(reg.Register(markers.Must(markers.MakeDefinition("test:num", markers.DescribesType, 0)))).To(Succeed())
+		Expect(reg.Register(markers.Must(markers.MakeDefinition("test:flag", markers.DescribesField, false)))).To(Succeed())
+
+		Expect(genall.RegisterMacroHelp(reg, []markers.Macro{
+			{Name: "both", Expands: []string{"test:num=1", "test:flag"}},
+			{Name: "num", Expands: []string{"+test:num=2"}, Description: "sets the number to 2"},
+		})).To(Succeed())
+
+		Expect(reg.Lookup("+both", markers.DescribesField)).NotTo(BeNil())
+		Expect(reg.Lookup("+both", markers.DescribesType)).To(BeNil())
+		Expect(reg.Lookup("+num", markers.DescribesType)).NotTo(BeNil())
+
+		bothHelp := reg.HelpFor(reg.Lookup("+both", markers.DescribesField))
+		Expect(bothHelp.Category).To(Equal(genall.MacroCategory))
+		Expect(bothHelp.Summary).To(Equal("expands to +test:num=1 +test:flag"))
+		Expect(reg.HelpFor(reg.Lookup("+num", markers.DescribesType)).Summary).To(Equal("sets the number to 2"))
+	})
+})
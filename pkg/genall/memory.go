@@ -0,0 +1,169 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// ErrGenerationFailed is returned by Runtime.Generate when not all
+// Generators ran successfully.
+var ErrGenerationFailed = errors.New("not all generators ran successfully")
+
+// Artifact is an artifact produced by a Generator, collected in memory.
+type Artifact struct {
+	// Generator is the Generator that produced the artifact, if known.
+	Generator *Generator
+	// GeneratorName is the name of the Generator that produced the
+	// artifact, if known.
+	GeneratorName string
+	// Package is the package that the artifact is associated with, or nil
+	// for non-package-associated artifacts (like config).
+	Package *loader.Package
+	// Path is the item path that the artifact was written to.
+	Path string
+	// Contents are the contents of the artifact.
+	Contents []byte
+}
+
+// Artifacts is a set of artifacts, in the order that they were first
+// written.
+type Artifacts []*Artifact
+
+// Get returns the artifact written by the Generator with the given name for
+// the given package (or nil for non-package-associated artifacts) at the
+// given path, or nil if there isn't one.
+func (a Artifacts) Get(generatorName string, pkg *loader.Package, itemPath string) *Artifact {
+	for _, artifact := range a {
+		if artifact.GeneratorName == generatorName && artifact.Package == pkg && artifact.Path == itemPath {
+			return artifact
+		}
+	}
+	return nil
+}
+
+// ForGenerator returns the artifacts written by the Generator with the
+// given name.
+func (a Artifacts) ForGenerator(generatorName string) Artifacts {
+	var res Artifacts
+	for _, artifact := range a {
+		if artifact.GeneratorName == generatorName {
+			res = append(res, artifact)
+		}
+	}
+	return res
+}
+
+// Paths returns the paths of the artifacts, in order.
+func (a Artifacts) Paths() []string {
+	paths := make([]string, len(a))
+	for i, artifact := range a {
+		paths[i] = artifact.Path
+	}
+	return paths
+}
+
+// OutputToMemory collects artifacts in memory, instead of writing them
+// anywhere.  It's mainly useful for using generators as a library (and for
+// testing them).  It's safe for concurrent use.
+//
+// Opening an artifact that was already written replaces it, like it would
+// for a file.  Use ForGenerator to get a rule that records which Generator
+// produced each artifact.
+type OutputToMemory struct {
+	mu        sync.Mutex
+	artifacts Artifacts
+}
+
+// Open opens an artifact that isn't associated with any particular
+// Generator.
+func (o *OutputToMemory) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	return o.open(nil, "", pkg, itemPath), nil
+}
+
+// ForGenerator returns an OutputRule that collects artifacts into this
+// OutputToMemory as having been produced by the given Generator.
+func (o *OutputToMemory) ForGenerator(gen *Generator, name string) OutputRule {
+	return memoryOutput{memory: o, gen: gen, name: name}
+}
+
+// Artifacts returns the artifacts collected so far.
+func (o *OutputToMemory) Artifacts() Artifacts {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append(Artifacts(nil), o.artifacts...)
+}
+
+// open returns a writer that records the given artifact once closed.
+func (o *OutputToMemory) open(gen *Generator, name string, pkg *loader.Package, itemPath string) io.WriteCloser {
+	return &bufferedArtifact{onClose: func(contents []byte) error {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		artifact := &Artifact{
+			Generator:     gen,
+			GeneratorName: name,
+			Package:       pkg,
+			Path:          itemPath,
+			Contents:      append([]byte(nil), contents...),
+		}
+		for i, existing := range o.artifacts {
+			if existing.Generator == gen && existing.GeneratorName == name && existing.Package == pkg && existing.Path == itemPath {
+				o.artifacts[i] = artifact
+				return nil
+			}
+		}
+		o.artifacts = append(o.artifacts, artifact)
+		return nil
+	}}
+}
+
+// memoryOutput collects artifacts produced by a particular Generator into an
+// OutputToMemory.
+type memoryOutput struct {
+	memory *OutputToMemory
+	gen    *Generator
+	name   string
+}
+
+func (o memoryOutput) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	return o.memory.open(o.gen, o.name, pkg, itemPath), nil
+}
+
+// Generate runs the Generators like Run, but collects the artifacts they
+// produce in memory instead of using the Runtime's OutputRules, returning
+// them along with ErrGenerationFailed if not all of the Generators ran
+// successfully.  Problems are still reported to ErrorWriter (or as
+// Diagnostics).
+func (r *Runtime) Generate() (Artifacts, error) {
+	memory := &OutputToMemory{}
+	orig := r.OutputRules
+	defer func() { r.OutputRules = orig }()
+
+	r.OutputRules = OutputRules{ByGenerator: make(map[*Generator]OutputRule, len(r.Generators))}
+	for _, gen := range r.Generators {
+		r.OutputRules.ByGenerator[gen] = memory.ForGenerator(gen, r.GeneratorNames[gen])
+	}
+
+	if hadErrs := r.Run(); hadErrs {
+		return memory.Artifacts(), ErrGenerationFailed
+	}
+	return memory.Artifacts(), nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"io"
+	"os"
+	"testing/fstest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+)
+
+var _ = Describe("OutputToMemory", func() {
+	It("should collect artifacts by generator and path", func() {
+		memory := &genall.OutputToMemory{}
+		var gen genall.Generator = fakeGenerator{}
+
+		out, err := memory.ForGenerator(&gen, "fake").Open(nil, "foo.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = io.WriteString(out, "foo\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(memory.Artifacts()).To(BeEmpty(), "artifacts should only be collected once closed")
+		Expect(out.Close()).To(Succeed())
+
+		out, err = memory.Open(nil, "foo.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = io.WriteString(out, "other\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.Close()).To(Succeed())
+
+		artifacts := memory.Artifacts()
+		Expect(artifacts).To(HaveLen(2))
+		Expect(artifacts.Get("fake", nil, "foo.yaml")).To(Equal(&genall.Artifact{
+			Generator:     &gen,
+			GeneratorName: "fake",
+			Path:          "foo.yaml",
+			Contents:      []byte("foo\n"),
+		}))
+		Expect(artifacts.Get("", nil, "foo.yaml").Contents).To(BeEquivalentTo("other\n"))
+		Expect(artifacts.Get("fake", nil, "bar.yaml")).To(BeNil())
+	})
+
+	It("should replace artifacts that are written again", func() {
+		memory := &genall.OutputToMemory{}
+		for _, contents := range []string{"first\n", "second\n"} {
+			out, err := memory.Open(nil, "foo.yaml")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = io.WriteString(out, contents)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out.Close()).To(Succeed())
+		}
+
+		Expect(memory.Artifacts()).To(HaveLen(1))
+		Expect(memory.Artifacts()[0].Contents).To(BeEquivalentTo("second\n"))
+	})
+})
+
+var _ = Describe("Runtime.Generate", func() {
+	It("should return the artifacts instead of writing them", func() {
+		outDir := GinkgoT().TempDir()
+		rt := runtimeFor(fakeGenerator{artifacts: map[string]string{"foo.yaml": "foo\n"}}, genall.OutputToDirectory(outDir))
+		var other genall.Generator = fakeGenerator{artifacts: map[string]string{"bar.yaml": "bar\n"}}
+		rt.Generators = append(rt.Generators, &other)
+		rt.GeneratorNames = map[*genall.Generator]string{rt.Generators[0]: "foo", &other: "bar"}
+
+		artifacts, err := rt.Generate()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(artifacts.Paths()).To(Equal([]string{"foo.yaml", "bar.yaml"}))
+		Expect(artifacts.ForGenerator("foo").Paths()).To(Equal([]string{"foo.yaml"}))
+		Expect(artifacts.Get("bar", nil, "bar.yaml").Contents).To(BeEquivalentTo("bar\n"))
+		Expect(os.ReadDir(outDir)).To(BeEmpty())
+		Expect(rt.OutputRules.Default).To(Equal(genall.OutputToDirectory(outDir)), "the output rules should be restored")
+	})
+
+	It("should return ErrGenerationFailed if a generator fails", func() {
+		rt := runtimeFor(fakeGenerator{artifacts: map[string]string{"foo.yaml": "foo\n"}}, genall.OutputToNothing)
+		var failing genall.Generator = failingGenerator{}
+		rt.Generators = append(rt.Generators, &failing)
+
+		artifacts, err := rt.Generate()
+		Expect(err).To(MatchError(genall.ErrGenerationFailed))
+		Expect(artifacts.Paths()).To(Equal([]string{"foo.yaml"}))
+	})
+})
+
+var _ = Describe("InputFromFS", func() {
+	fsys := fstest.MapFS{
+		"hack/boilerplate.txt": &fstest.MapFile{Data: []byte("header\n")},
+	}
+
+	It("should read files relative to the root of the FS", func() {
+		for _, path := range []string{"hack/boilerplate.txt", "./hack/boilerplate.txt", "hack/../hack/boilerplate.txt"} {
+			in, err := genall.InputFromFS(fsys).OpenForRead(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(io.ReadAll(in)).To(BeEquivalentTo("header\n"))
+			Expect(in.Close()).To(Succeed())
+		}
+	})
+
+	It("should refuse paths outside of the FS", func() {
+		for _, path := range []string{"/hack/boilerplate.txt", "../boilerplate.txt"} {
+			_, err := genall.InputFromFS(fsys).OpenForRead(path)
+			Expect(err).To(MatchError(ContainSubstring("paths must be relative to the root of the FS")))
+		}
+	})
+
+	It("should be usable for reading headers", func() {
+		ctx := &genall.GenerationContext{InputRule: genall.InputFromFS(fsys)}
+		header, err := ctx.LoadHeader("hack/boilerplate.txt", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(header.Render(genall.YAMLArtifact, nil)).To(Equal("# header\n"))
+	})
+})
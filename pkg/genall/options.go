@@ -61,6 +61,12 @@ func RegistryFromOptions(optionsRegistry *markers.Registry, options []string) (*
 	if err := protoRt.Generators.RegisterMarkers(reg); err != nil {
 		return nil, err
 	}
+	if err := RegisterNoLintMarkers(reg); err != nil {
+		return nil, err
+	}
+	if err := RegisterMacroMarkers(reg); err != nil {
+		return nil, err
+	}
 	return reg, nil
 }
 
@@ -75,6 +81,10 @@ func RegistryFromOptions(optionsRegistry *markers.Registry, options []string) (*
 // the generators and the specified output rules to produce a runtime that can be run or
 // further modified.  Not default generators are used if none are specified -- you can check
 // the output and rerun for that.
+//
+// FromOptionsWithConfig does the same with the given packages.Config.  If its
+// Dir is set, relative paths in the options (to roots, output directories,
+// header files, etc) are relative to it instead of the working directory.
 func FromOptions(optionsRegistry *markers.Registry, options []string) (*Runtime, error) {
 	return FromOptionsWithConfig(&packages.Config{}, optionsRegistry, options)
 }
@@ -90,6 +100,10 @@ func FromOptionsWithConfig(cfg *packages.Config, optionsRegistry *markers.Regist
 	if err != nil {
 		return nil, err
 	}
+	genRuntime.GeneratorNames = make(map[*Generator]string, len(protoRt.GeneratorsByName))
+	for name, gen := range protoRt.GeneratorsByName {
+		genRuntime.GeneratorNames[gen] = name
+	}
 
 	// attempt to figure out what the user wants without a lot of verbose specificity:
 	// if the user specifies a default rule, assume that they probably want to fall back
@@ -97,13 +111,18 @@ func FromOptionsWithConfig(cfg *packages.Config, optionsRegistry *markers.Regist
 	// set, and leave the rest in the standard configuration.
 	if protoRt.OutputRules.Default != nil {
 		genRuntime.OutputRules = protoRt.OutputRules
-		return genRuntime, nil
+	} else {
+		outRules := DirectoryPerGenerator("config", protoRt.GeneratorsByName)
+		maps.Copy(outRules.ByGenerator, protoRt.OutputRules.ByGenerator)
+		genRuntime.OutputRules = outRules
 	}
 
-	outRules := DirectoryPerGenerator("config", protoRt.GeneratorsByName)
-	maps.Copy(outRules.ByGenerator, protoRt.OutputRules.ByGenerator)
-
-	genRuntime.OutputRules = outRules
+	// like the roots, the other relative paths in the options (to output
+	// directories, header files, etc) are relative to cfg.Dir, if set.
+	if cfg.Dir != "" {
+		genRuntime.InputRule = InputFromDirectory(cfg.Dir)
+		genRuntime.OutputRules = genRuntime.OutputRules.relativeTo(cfg.Dir)
+	}
 	return genRuntime, nil
 }
 
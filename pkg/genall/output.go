@@ -73,6 +73,47 @@ func (o OutputRules) ForGenerator(gen *Generator) OutputRule {
 	return o.Default
 }
 
+// relativeTo returns a copy of these rules, with relative paths in them
+// relative to the given directory instead of the working directory.
+func (o OutputRules) relativeTo(dir string) OutputRules {
+	res := OutputRules{
+		Default:     ruleRelativeTo(o.Default, dir),
+		ByGenerator: make(map[*Generator]OutputRule, len(o.ByGenerator)),
+	}
+	for gen, rule := range o.ByGenerator {
+		res.ByGenerator[gen] = ruleRelativeTo(rule, dir)
+	}
+	return res
+}
+
+// relativeOutputRule is an OutputRule that writes to paths that may be
+// relative.
+type relativeOutputRule interface {
+	OutputRule
+	// relativeTo returns a copy of this rule, with relative paths relative
+	// to the given directory instead of the working directory.
+	relativeTo(dir string) OutputRule
+}
+
+// ruleRelativeTo makes the relative paths in the given rule relative to the
+// given directory, if it has any.
+func ruleRelativeTo(rule OutputRule, dir string) OutputRule {
+	if relRule, isRelative := rule.(relativeOutputRule); isRelative {
+		return relRule.relativeTo(dir)
+	}
+	return rule
+}
+
+// pathRelativeTo makes the given path relative to the given directory,
+// unless it's absolute.  An empty path (the working directory) becomes the
+// directory itself.
+func pathRelativeTo(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
 // OutputRule defines how to output artifacts from a generator.
 type OutputRule interface {
 	// Open opens the given artifact path for writing.  If a package is passed,
@@ -82,6 +123,15 @@ type OutputRule interface {
 	Open(pkg *loader.Package, path string) (io.WriteCloser, error)
 }
 
+// FileOutputRule is an OutputRule that writes artifacts to files on disk.
+type FileOutputRule interface {
+	OutputRule
+	// PathFor returns the path of the file that Open would write the given
+	// artifact to, without creating it.  An empty itemPath yields the
+	// directory that non-package-associated artifacts are written to.
+	PathFor(pkg *loader.Package, itemPath string) (string, error)
+}
+
 // OutputToNothing skips outputting anything.
 var OutputToNothing = outputToNothing{}
 
@@ -100,12 +150,28 @@ func (o outputToNothing) Open(_ *loader.Package, _ string) (io.WriteCloser, erro
 // of if it's package-associated or not.
 type OutputToDirectory string
 
-func (o OutputToDirectory) Open(_ *loader.Package, itemPath string) (io.WriteCloser, error) {
-	// ensure the directory exists
-	if err := os.MkdirAll(filepath.Dir(filepath.Join(string(o), itemPath)), os.ModePerm); err != nil {
+func (o OutputToDirectory) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	path, err := o.PathFor(pkg, itemPath)
+	if err != nil {
+		return nil, err
+	}
+	return createFile(path)
+}
+
+func (o OutputToDirectory) PathFor(_ *loader.Package, itemPath string) (string, error) {
+	return filepath.Join(string(o), itemPath), nil
+}
+
+func (o OutputToDirectory) relativeTo(dir string) OutputRule {
+	return OutputToDirectory(pathRelativeTo(dir, string(o)))
+}
+
+// createFile creates (or truncates) the file at the given path, making sure
+// that its parent directory exists.
+func createFile(path string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
 		return nil, err
 	}
-	path := filepath.Join(string(o), itemPath)
 	return os.Create(path)
 }
 
@@ -125,6 +191,16 @@ func (o outputToStdout) Open(_ *loader.Package, _ string) (io.WriteCloser, error
 	return nopCloser{os.Stdout}, nil
 }
 
+// outputToWriter outputs everything to the given writer, with no separation.
+// It's used to buffer standard-out output when running generators in parallel.
+type outputToWriter struct {
+	io.Writer
+}
+
+func (o outputToWriter) Open(_ *loader.Package, _ string) (io.WriteCloser, error) {
+	return nopCloser{o.Writer}, nil
+}
+
 // +controllertools:marker:generateHelp:category=""
 
 // OutputArtifacts outputs artifacts to different locations, depending on
@@ -141,24 +217,34 @@ type OutputArtifacts struct {
 }
 
 func (o OutputArtifacts) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	path, err := o.PathFor(pkg, itemPath)
+	if err != nil {
+		return nil, err
+	}
+	return createFile(path)
+}
+
+func (o OutputArtifacts) PathFor(pkg *loader.Package, itemPath string) (string, error) {
 	if pkg == nil {
-		return o.Config.Open(pkg, itemPath)
+		return o.Config.PathFor(pkg, itemPath)
 	}
 
 	if o.Code != "" {
-		return o.Code.Open(pkg, itemPath)
+		return o.Code.PathFor(pkg, itemPath)
 	}
 
 	if len(pkg.CompiledGoFiles) == 0 {
-		return nil, fmt.Errorf("cannot output to a package with no path on disk")
+		return "", fmt.Errorf("cannot output to a package with no path on disk")
 	}
 	outDir := filepath.Dir(pkg.CompiledGoFiles[0])
-	if _, err := os.Stat(outDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
-			return nil, err
-		}
-	}
+	return filepath.Join(outDir, itemPath), nil
+}
 
-	outPath := filepath.Join(outDir, itemPath)
-	return os.Create(outPath)
+func (o OutputArtifacts) relativeTo(dir string) OutputRule {
+	res := OutputArtifacts{Config: OutputToDirectory(pathRelativeTo(dir, string(o.Config)))}
+	if o.Code != "" {
+		// an empty Code means the package's directory, not the working directory
+		res.Code = OutputToDirectory(pathRelativeTo(dir, string(o.Code)))
+	}
+	return res
 }
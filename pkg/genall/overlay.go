@@ -0,0 +1,136 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"golang.org/x/tools/go/packages"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// OverlayVersion is the version of the marker overlay file format understood
+// by ParseOverlay.
+const OverlayVersion = "v1alpha1"
+
+// OverlayFile attaches markers to types and fields whose source can't be
+// edited, such as types from other modules (see markers.Overlay).  It's
+// usually loaded from a YAML file like:
+//
+//	version: v1alpha1
+//	markers:
+//	  k8s.io/api/core/v1.Container:
+//	  - +kubebuilder:validation:XValidation:rule="self.image != ''",message="image is required"
+//	  k8s.io/api/core/v1.Container.Name:
+//	  - +kubebuilder:validation:MaxLength=63
+type OverlayFile struct {
+	// Version is the version of the overlay file format.  It must be
+	// OverlayVersion.
+	Version string `json:"version"`
+	// Markers maps targets, of the form `<package path>.<Type>` or
+	// `<package path>.<Type>.<Field>`, to the markers to attach to them.
+	Markers map[string][]string `json:"markers"`
+}
+
+// ParseOverlay parses the given YAML (or JSON) marker overlay file contents,
+// failing on unknown fields, unsupported versions, and invalid targets.  The
+// source (e.g. the file name) is used in error messages.
+func ParseOverlay(source string, data []byte) (markers.Overlay, error) {
+	var file OverlayFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return markers.Overlay{}, fmt.Errorf("%s: %w", source, err)
+	}
+	if file.Version != OverlayVersion {
+		return markers.Overlay{}, fmt.Errorf("%s: unsupported overlay version %q (expected %q)", source, file.Version, OverlayVersion)
+	}
+	for rawTarget, targetMarkers := range file.Markers {
+		if _, err := markers.ParseOverlayTarget(rawTarget); err != nil {
+			return markers.Overlay{}, fmt.Errorf("%s: %w", source, err)
+		}
+		if len(targetMarkers) == 0 {
+			return markers.Overlay{}, fmt.Errorf("%s: overlay target %s has no markers", source, rawTarget)
+		}
+	}
+	return markers.Overlay{Source: source, Markers: file.Markers}, nil
+}
+
+// LoadOverlays reads and parses the given marker overlay files, then checks
+// that all their targets exist (so that overlays for types that were renamed
+// or removed, e.g. in a new version of the module they're from, fail loudly
+// instead of silently doing nothing), loading the packages containing them
+// with the given config.
+func LoadOverlays(cfg *packages.Config, paths ...string) ([]markers.Overlay, error) {
+	var overlays []markers.Overlay
+	var pkgPaths []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		overlay, err := ParseOverlay(path, data)
+		if err != nil {
+			return nil, err
+		}
+		overlays = append(overlays, overlay)
+		for rawTarget := range overlay.Markers {
+			// already validated when parsing
+			target, _ := markers.ParseOverlayTarget(rawTarget)
+			pkgPaths = append(pkgPaths, target.Package)
+		}
+	}
+	if len(pkgPaths) == 0 {
+		return overlays, nil
+	}
+	slices.Sort(pkgPaths)
+	pkgPaths = slices.Compact(pkgPaths)
+
+	pkgs, err := loader.LoadRootsWithConfig(cfg, pkgPaths...)
+	if err != nil {
+		return nil, err
+	}
+	pkgsByPath := make(map[string]*loader.Package, len(pkgs))
+	for _, pkg := range pkgs {
+		if len(pkg.GoFiles) > 0 {
+			pkgsByPath[pkg.PkgPath] = pkg
+		}
+	}
+
+	var errs []error
+	for _, overlay := range overlays {
+		for _, rawTarget := range sortedKeys(overlay.Markers) {
+			target, _ := markers.ParseOverlayTarget(rawTarget)
+			pkg, found := pkgsByPath[target.Package]
+			if !found {
+				errs = append(errs, fmt.Errorf("%s: package %s for overlay target %s not found", overlay.Source, target.Package, target))
+				continue
+			}
+			if markers.FindOverlayTarget(pkg, target) == nil {
+				errs = append(errs, fmt.Errorf("%s: overlay target %s doesn't exist", overlay.Source, target))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return overlays, nil
+}
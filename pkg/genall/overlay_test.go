@@ -0,0 +1,87 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/tools/go/packages"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+)
+
+var _ = Describe("Marker overlay files", func() {
+	It("should parse markers by target", func() {
+		overlay, err := genall.ParseOverlay("overlay.yaml", []byte(`
+version: v1alpha1
+markers:
+  k8s.io/api/core/v1.Container.Name:
+  - +kubebuilder:validation:MaxLength=63
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(overlay.Source).To(Equal("overlay.yaml"))
+		Expect(overlay.Markers).To(Equal(map[string][]string{
+			"k8s.io/api/core/v1.Container.Name": {"+kubebuilder:validation:MaxLength=63"},
+		}))
+	})
+
+	It("should reject unsupported versions, invalid targets, and targets without markers", func() {
+		_, err := genall.ParseOverlay("overlay.yaml", []byte("version: v2\n"))
+		Expect(err).To(MatchError(ContainSubstring("unsupported overlay version")))
+
+		_, err = genall.ParseOverlay("overlay.yaml", []byte("version: v1alpha1\nmarkers:\n  k8s.io/api/core/v1: [+a]\n"))
+		Expect(err).To(MatchError(ContainSubstring("invalid overlay target")))
+
+		_, err = genall.ParseOverlay("overlay.yaml", []byte("version: v1alpha1\nmarkers:\n  k8s.io/api/core/v1.Container: []\n"))
+		Expect(err).To(MatchError(ContainSubstring("has no markers")))
+	})
+
+	Context("when loading", func() {
+		var dir string
+		writeOverlay := func(contents string) string {
+			path := filepath.Join(dir, "overlay.yaml")
+			Expect(os.WriteFile(path, []byte(contents), 0o644)).To(Succeed())
+			return path
+		}
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/overlays\n\ngo 1.22\n"), 0o644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\ntype Foo struct {\n\tA string\n}\n"), 0o644)).To(Succeed())
+		})
+
+		It("should load overlays whose targets exist", func() {
+			path := writeOverlay("version: v1alpha1\nmarkers:\n  example.com/overlays.Foo.A: [+a]\n  example.com/overlays.Foo: [+b]\n")
+			overlays, err := genall.LoadOverlays(&packages.Config{Dir: dir}, path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(overlays).To(HaveLen(1))
+			Expect(overlays[0].Source).To(Equal(path))
+		})
+
+		It("should fail loudly when targets or their packages no longer exist", func() {
+			path := writeOverlay("version: v1alpha1\nmarkers:\n  example.com/overlays.Foo.B: [+a]\n  example.com/overlays/gone.Foo: [+b]\n")
+			_, err := genall.LoadOverlays(&packages.Config{Dir: dir}, path)
+			Expect(err).To(MatchError(And(
+				ContainSubstring("overlay target example.com/overlays.Foo.B doesn't exist"),
+				ContainSubstring("package example.com/overlays/gone for overlay target example.com/overlays/gone.Foo not found"),
+			)))
+		})
+	})
+})
@@ -0,0 +1,251 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/metrics"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"sigs.k8s.io/controller-tools/pkg/internal/profile"
+)
+
+// allocMetrics are the runtime metrics read at the start and end of each span.
+var allocMetrics = []string{"/gc/heap/allocs:bytes", "/gc/heap/allocs:objects"}
+
+// Profile records how long each phase of generation takes, and how much it
+// allocates, per Generator.  The phases are loading packages ("load"),
+// type-checking them ("typecheck"), collecting markers ("markers"),
+// flattening CRD schemata ("flatten"), running gengo for the
+// applyconfiguration generator ("gengo"), and running each Generator
+// ("generate").
+//
+// Phases started while a single Generator is running are attributed to it.
+// When running Generators in parallel, phases that happen while several are
+// running aren't attributed to any of them (and allocations are always
+// counted for the whole process, so include those from anything else running
+// at the same time).
+type Profile struct {
+	mu    sync.Mutex
+	start time.Time
+	spans []profileSpan
+	// running are the names of the Generators currently running.
+	running []string
+	// tracks maps Generator names to their track in the trace ("" is the
+	// track for everything not attributed to a Generator).
+	tracks map[string]int
+}
+
+// profileSpan is a span of time spent in a phase.
+type profileSpan struct {
+	phase        string
+	generator    string
+	start, end   time.Duration
+	allocBytes   uint64
+	allocObjects uint64
+}
+
+// StartProfile starts recording a Profile.  Call Stop once done.
+func StartProfile() *Profile {
+	p := &Profile{
+		start:  time.Now(),
+		tracks: map[string]int{"": 0},
+	}
+	profile.SetRecorder(p)
+	return p
+}
+
+// Stop stops recording the Profile.
+func (p *Profile) Stop() {
+	profile.SetRecorder(nil)
+}
+
+// StartSpan implements the internal profile.Recorder interface.
+func (p *Profile) StartSpan(phase, generator string) func() {
+	p.mu.Lock()
+	if generator != "" {
+		p.running = append(p.running, generator)
+		if _, known := p.tracks[generator]; !known {
+			p.tracks[generator] = len(p.tracks)
+		}
+	} else if len(p.running) == 1 {
+		generator = p.running[0]
+	}
+	span := profileSpan{phase: phase, generator: generator, start: time.Since(p.start)}
+	p.mu.Unlock()
+	allocsBefore := readAllocs()
+
+	return func() {
+		allocsAfter := readAllocs()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		span.end = time.Since(p.start)
+		span.allocBytes = allocsAfter[0] - allocsBefore[0]
+		span.allocObjects = allocsAfter[1] - allocsBefore[1]
+		p.spans = append(p.spans, span)
+		if phase == profile.Generate {
+			for i, running := range p.running {
+				if running == generator {
+					p.running = append(p.running[:i], p.running[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+}
+
+// readAllocs reads the total bytes and objects allocated so far.
+func readAllocs() [2]uint64 {
+	samples := make([]metrics.Sample, len(allocMetrics))
+	for i, name := range allocMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	var res [2]uint64
+	for i, sample := range samples {
+		if sample.Value.Kind() == metrics.KindUint64 {
+			res[i] = sample.Value.Uint64()
+		}
+	}
+	return res
+}
+
+// WriteReport writes a table of the total time spent in (and allocations
+// made by) each phase, per Generator, in the order that they were first
+// started.  Times include those of phases nested within them (e.g.
+// type-checking done by a Generator counts for both).
+func (p *Profile) WriteReport(out io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	type phaseKey struct{ phase, generator string }
+	type phaseTotal struct {
+		phaseKey
+		calls        int
+		firstStart   time.Duration
+		wall         time.Duration
+		allocBytes   uint64
+		allocObjects uint64
+	}
+	var totals []*phaseTotal
+	byKey := make(map[phaseKey]*phaseTotal)
+	for _, span := range p.spans {
+		key := phaseKey{phase: span.phase, generator: span.generator}
+		total, known := byKey[key]
+		if !known {
+			total = &phaseTotal{phaseKey: key, firstStart: span.start}
+			byKey[key] = total
+			totals = append(totals, total)
+		}
+		total.calls++
+		total.firstStart = min(total.firstStart, span.start)
+		total.wall += span.end - span.start
+		total.allocBytes += span.allocBytes
+		total.allocObjects += span.allocObjects
+	}
+	// spans are recorded as they end, so order by when they started
+	slices.SortStableFunc(totals, func(a, b *phaseTotal) int {
+		return cmp.Compare(a.firstStart, b.firstStart)
+	})
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PHASE\tGENERATOR\tCALLS\tTIME\tALLOCATED\tOBJECTS")
+	for _, total := range totals {
+		generator := total.generator
+		if generator == "" {
+			generator = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%d\n", total.phase, generator, total.calls,
+			total.wall.Round(time.Millisecond), formatBytes(total.allocBytes), total.allocObjects)
+	}
+	return w.Flush()
+}
+
+// formatBytes formats the given number of bytes in binary units.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// traceEvent is an event in the Chrome trace-event format.
+type traceEvent struct {
+	Name  string         `json:"name"`
+	Cat   string         `json:"cat,omitempty"`
+	Phase string         `json:"ph"`
+	TS    float64        `json:"ts"`
+	Dur   float64        `json:"dur,omitempty"`
+	PID   int            `json:"pid"`
+	TID   int            `json:"tid"`
+	Args  map[string]any `json:"args,omitempty"`
+}
+
+// WriteTrace writes the recorded spans in the Chrome trace-event format
+// (as read by chrome://tracing or Perfetto), with a track per Generator.
+func (p *Profile) WriteTrace(out io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	events := make([]traceEvent, 0, len(p.tracks)+len(p.spans))
+	for generator, track := range p.tracks {
+		name := generator
+		if name == "" {
+			name = "controller-gen"
+		}
+		events = append(events, traceEvent{Name: "thread_name", Phase: "M", TID: track, Args: map[string]any{"name": name}})
+	}
+	slices.SortFunc(events, func(a, b traceEvent) int { return cmp.Compare(a.TID, b.TID) })
+
+	for _, span := range p.spans {
+		args := map[string]any{
+			"allocatedBytes":   span.allocBytes,
+			"allocatedObjects": span.allocObjects,
+		}
+		if span.generator != "" {
+			args["generator"] = span.generator
+		}
+		events = append(events, traceEvent{
+			Name:  span.phase,
+			Cat:   span.generator,
+			Phase: "X",
+			TS:    float64(span.start.Microseconds()),
+			Dur:   float64((span.end - span.start).Microseconds()),
+			TID:   p.tracks[span.generator],
+			Args:  args,
+		})
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]any{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	})
+}
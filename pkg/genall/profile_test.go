@@ -0,0 +1,92 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/tools/go/packages"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+)
+
+var _ = Describe("Profile", func() {
+	var prof *genall.Profile
+
+	BeforeEach(func() {
+		prof = genall.StartProfile()
+		DeferCleanup(prof.Stop)
+
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/profile\n\ngo 1.22\n"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\ntype Foo struct {\n\tA string\n}\n"), 0o644)).To(Succeed())
+
+		gens := genall.Generators{new(genall.Generator)}
+		*gens[0] = warningGenerator{}
+		rt, err := gens.ForRootsWithConfig(&packages.Config{Dir: dir}, "./...")
+		Expect(err).NotTo(HaveOccurred())
+		rt.GeneratorNames = map[*genall.Generator]string{gens[0]: "warner"}
+		rt.ErrorWriter = GinkgoWriter
+		Expect(rt.Run()).To(BeFalse())
+		prof.Stop()
+	})
+
+	It("should report each phase per generator", func() {
+		var out bytes.Buffer
+		Expect(prof.WriteReport(&out)).To(Succeed())
+
+		Expect(out.String()).To(MatchRegexp(`^PHASE +GENERATOR +CALLS +TIME +ALLOCATED +OBJECTS\n`))
+		Expect(out.String()).To(MatchRegexp(`\nload +- +1 +\S+ +\S+ \S*B +\d+\n`))
+		Expect(out.String()).To(MatchRegexp(`\ngenerate +warner +1 +`))
+		Expect(out.String()).To(MatchRegexp(`\nmarkers +warner +1 +`))
+	})
+
+	It("should write a trace with a track per generator", func() {
+		var out bytes.Buffer
+		Expect(prof.WriteTrace(&out)).To(Succeed())
+
+		var trace struct {
+			TraceEvents []struct {
+				Name  string         `json:"name"`
+				Phase string         `json:"ph"`
+				TID   int            `json:"tid"`
+				Dur   float64        `json:"dur"`
+				Args  map[string]any `json:"args"`
+			} `json:"traceEvents"`
+		}
+		Expect(json.Unmarshal(out.Bytes(), &trace)).To(Succeed())
+
+		tracks := map[string]int{}
+		phases := map[string]int{}
+		for _, event := range trace.TraceEvents {
+			switch event.Phase {
+			case "M":
+				tracks[event.Args["name"].(string)] = event.TID
+			case "X":
+				phases[event.Name] = event.TID
+				Expect(event.Args).To(HaveKey("allocatedBytes"))
+			}
+		}
+		Expect(tracks).To(Equal(map[string]int{"controller-gen": 0, "warner": 1}))
+		Expect(phases).To(Equal(map[string]int{"load": 0, "generate": 1, "markers": 1}))
+	})
+})
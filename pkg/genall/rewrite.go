@@ -0,0 +1,189 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/controller-tools/pkg/internal/diff"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// RewrittenFile is a source file with some of its markers rewritten, as
+// produced by MigrateMarkers or FormatMarkers.
+type RewrittenFile struct {
+	// Path is the path to the file.
+	Path string
+	// Original is the current contents of the file.
+	Original []byte
+	// Rewritten is the contents of the file with the markers rewritten.
+	Rewritten []byte
+	// Rewrites are the markers that were rewritten.
+	Rewrites []markers.MarkerRewrite
+}
+
+// Diff returns a unified diff between the current and rewritten contents.
+func (f RewrittenFile) Diff() string {
+	name := displayPath(f.Path)
+	return diff.Unified(name, name, f.Original, f.Rewritten)
+}
+
+// Write replaces the file with its rewritten contents.
+func (f RewrittenFile) Write() error {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.Path, f.Rewritten, info.Mode().Perm())
+}
+
+// markerEdit is a rewrite of the marker in the comments between the given
+// byte offsets of a file.
+type markerEdit struct {
+	start, end int
+	rewrite    markers.MarkerRewrite
+}
+
+// MigrateMarkers rewrites the deprecated markers in the source files of the
+// given packages, using the migrations registered with the Collector's
+// registry (see markers.Registry.AddMigration).  Only the markers themselves
+// are changed, so comments keep their layout.  Markers that are replaced by
+// several markers are split over several lines, and those that are replaced
+// by none are removed.
+//
+// It returns the files with markers to rewrite, sorted by path, along with
+// errors for the markers that couldn't be migrated (which are left as they
+// are).
+func MigrateMarkers(col *markers.Collector, roots []*loader.Package) ([]RewrittenFile, error) {
+	return rewriteMarkers(roots, col.MigrationsInPackage)
+}
+
+// FormatMarkers rewrites the markers in the source files of the given
+// packages that are known to the Collector's registry into their canonical
+// form (see markers.Definition.Format), so that the same marker is always
+// written the same way.  Formatting is idempotent.
+//
+// It returns the files with markers to rewrite, sorted by path, along with
+// errors for the markers that couldn't be parsed (which are left as they
+// are).
+func FormatMarkers(col *markers.Collector, roots []*loader.Package) ([]RewrittenFile, error) {
+	return rewriteMarkers(roots, col.UnformattedMarkers)
+}
+
+// rewriteMarkers applies the marker rewrites found by the given function in
+// each root to the files containing them.
+func rewriteMarkers(roots []*loader.Package, find func(*loader.Package) []markers.MarkerRewrite) ([]RewrittenFile, error) {
+	var errs []error
+	byFile := make(map[string][]markerEdit)
+	for _, root := range roots {
+		fset := root.FileSet()
+		for _, rewrite := range find(root) {
+			last := rewrite.Comment
+			if len(rewrite.Continuations) > 0 {
+				last = rewrite.Continuations[len(rewrite.Continuations)-1]
+			}
+			start, end := fset.Position(rewrite.Comment.Pos()), fset.Position(last.End())
+			if rewrite.Err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", start, rewrite.Err))
+				continue
+			}
+			byFile[start.Filename] = append(byFile[start.Filename], markerEdit{start: start.Offset, end: end.Offset, rewrite: rewrite})
+		}
+	}
+
+	var files []RewrittenFile
+	for path, edits := range byFile {
+		original, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rewritten, err := rewriteFile(original, edits)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to rewrite markers in %s: %w", path, err))
+			continue
+		}
+		file := RewrittenFile{Path: path, Original: original, Rewritten: rewritten}
+		for _, edit := range edits {
+			file.Rewrites = append(file.Rewrites, edit.rewrite)
+		}
+		files = append(files, file)
+	}
+	slices.SortFunc(files, func(a, b RewrittenFile) int { return strings.Compare(a.Path, b.Path) })
+
+	return files, loader.MaybeErrList(errs)
+}
+
+// rewriteFile applies the given edits (sorted by position) to the given file
+// contents.
+func rewriteFile(original []byte, edits []markerEdit) ([]byte, error) {
+	var out bytes.Buffer
+	last := 0
+	for _, edit := range edits {
+		lastComment := edit.rewrite.Comment
+		if len(edit.rewrite.Continuations) > 0 {
+			lastComment = edit.rewrite.Continuations[len(edit.rewrite.Continuations)-1]
+		}
+		if edit.end > len(original) || !bytes.HasPrefix(original[edit.start:edit.end], []byte(edit.rewrite.Comment.Text)) || !bytes.HasSuffix(original[edit.start:edit.end], []byte(lastComment.Text)) {
+			return nil, fmt.Errorf("file changed since it was loaded")
+		}
+		comment := edit.rewrite.Comment.Text
+		// keep everything up to the marker (`//`, and any spaces)
+		prefix := comment[:strings.Index(comment, "+")]
+
+		lineStart := bytes.LastIndexByte(original[:edit.start], '\n') + 1
+		indent := string(original[lineStart:edit.start])
+		ownLine := strings.TrimLeft(indent, " \t") == ""
+
+		start, end := edit.start, edit.end
+		var replacement string
+		switch {
+		case len(edit.rewrite.Replacements) > 0:
+			var lines []string
+			for _, marker := range edit.rewrite.Replacements {
+				// markers may be continued over several lines
+				for _, line := range strings.Split(marker, "\n") {
+					lines = append(lines, prefix+line)
+				}
+			}
+			// continue on lines indented the same as the one the comment
+			// starts on.
+			lineIndent := indent[:len(indent)-len(strings.TrimLeft(indent, " \t"))]
+			replacement = strings.Join(lines, "\n"+lineIndent)
+		case ownLine:
+			// remove the whole line
+			start = lineStart
+			if end < len(original) && original[end] == '\n' {
+				end++
+			}
+		default:
+			// remove the comment, along with the space before it
+			start = lineStart + len(strings.TrimRight(indent, " \t"))
+		}
+
+		out.Write(original[last:start])
+		out.WriteString(replacement)
+		last = end
+	}
+	out.Write(original[last:])
+	return out.Bytes(), nil
+}
@@ -0,0 +1,185 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/tools/go/packages"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+var _ = Describe("MigrateMarkers", func() {
+	var col *markers.Collector
+	var dir string
+
+	BeforeEach(func() {
+		reg := &markers.Registry{}
+		addMigration := func(name string, migration markers.Migration) {
+			def := markers.Must(markers.MakeDefinition(name, markers.DescribesType, struct{}{}))
+			Expect(reg.Register(def)).To(Succeed())
+			reg.AddMigration(def, migration)
+		}
+		Expect(reg.Register(markers.Must(markers.MakeDefinition("testing:old", markers.DescribesField, 0)))).To(Succeed())
+		reg.AddMigration(reg.Lookup("+testing:old", markers.DescribesField), markers.RenameTo("testing:new"))
+		addMigration("testing:split", func(*markers.Definition, string) ([]string, error) {
+			return []string{"+testing:one", "+testing:two"}, nil
+		})
+		addMigration("testing:drop", func(*markers.Definition, string) ([]string, error) {
+			return nil, nil
+		})
+		addMigration("testing:bad", func(*markers.Definition, string) ([]string, error) {
+			return nil, errors.New("no equivalent")
+		})
+		col = &markers.Collector{Registry: reg}
+		dir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/migrate\n\ngo 1.22\n"), 0o644)).To(Succeed())
+	})
+
+	migrate := func(contents string) ([]genall.RewrittenFile, error) {
+		Expect(os.WriteFile(filepath.Join(dir, "a.go"), []byte(contents), 0o644)).To(Succeed())
+		roots, err := loader.LoadRootsWithConfig(&packages.Config{Dir: dir}, "./...")
+		Expect(err).NotTo(HaveOccurred())
+		return genall.MigrateMarkers(col, roots)
+	}
+
+	It("should rename markers, keeping their arguments and the rest of the comment", func() {
+		files, err := migrate("package a\n\ntype Foo struct {\n\t// A is a field.\n\t//  +testing:old=5\n\tA string\n}\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		Expect(files[0].Path).To(Equal(filepath.Join(dir, "a.go")))
+		Expect(files[0].Rewrites).To(HaveLen(1))
+		Expect(files[0].Rewrites[0].Marker).To(Equal("+testing:old=5"))
+		Expect(string(files[0].Rewritten)).To(Equal("package a\n\ntype Foo struct {\n\t// A is a field.\n\t//  +testing:new=5\n\tA string\n}\n"))
+	})
+
+	It("should rewrite all the lines of markers continued over several lines", func() {
+		files, err := migrate("package a\n\ntype Foo struct {\n\t// +testing:old=\\\n\t//   5\n\tA string\n}\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		// the line break is replaced by a space, like when parsing
+		Expect(string(files[0].Rewritten)).To(Equal("package a\n\ntype Foo struct {\n\t// +testing:new= 5\n\tA string\n}\n"))
+	})
+
+	It("should split markers with several replacements over several lines", func() {
+		files, err := migrate("package a\n\n// +testing:split\ntype Foo struct{}\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		Expect(string(files[0].Rewritten)).To(Equal("package a\n\n// +testing:one\n// +testing:two\ntype Foo struct{}\n"))
+	})
+
+	It("should remove markers without replacements", func() {
+		files, err := migrate("package a\n\n// Foo is a type.\n// +testing:drop\ntype Foo struct{}\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		Expect(string(files[0].Rewritten)).To(Equal("package a\n\n// Foo is a type.\ntype Foo struct{}\n"))
+	})
+
+	It("should produce a diff of the changes, and only write them when asked", func() {
+		files, err := migrate("package a\n\n// +testing:split\ntype Foo struct{}\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		Expect(files[0].Diff()).To(And(
+			ContainSubstring("\n-// +testing:split\n"),
+			ContainSubstring("\n+// +testing:one\n+// +testing:two\n"),
+		))
+
+		Expect(os.ReadFile(filepath.Join(dir, "a.go"))).To(BeEquivalentTo(files[0].Original))
+		Expect(files[0].Write()).To(Succeed())
+		Expect(os.ReadFile(filepath.Join(dir, "a.go"))).To(BeEquivalentTo(files[0].Rewritten))
+	})
+
+	It("should report markers that can't be migrated, and migrate the rest", func() {
+		files, err := migrate("package a\n\n// +testing:bad\ntype Foo struct{}\n\n// +testing:split\ntype Bar struct{}\n")
+		Expect(err).To(MatchError(MatchRegexp(`a\.go:3:1: unable to migrate \+testing:bad: no equivalent`)))
+		Expect(files).To(HaveLen(1))
+		Expect(string(files[0].Rewritten)).To(Equal("package a\n\n// +testing:bad\ntype Foo struct{}\n\n// +testing:one\n// +testing:two\ntype Bar struct{}\n"))
+	})
+
+	It("should leave files without deprecated markers alone", func() {
+		files, err := migrate("package a\n\n// +testing:one\ntype Foo struct{}\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(BeEmpty())
+	})
+})
+
+var _ = Describe("FormatMarkers", func() {
+	var col *markers.Collector
+	var dir string
+
+	type enumStruct struct {
+		Values []string
+		Strict bool `marker:",optional"`
+	}
+
+	BeforeEach(func() {
+		reg := &markers.Registry{}
+		Expect(reg.Define("testing:enum", markers.DescribesField, enumStruct{})).To(Succeed())
+		Expect(reg.Define("testing:pattern", markers.DescribesField, "")).To(Succeed())
+		col = &markers.Collector{Registry: reg}
+		dir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/format\n\ngo 1.22\n"), 0o644)).To(Succeed())
+	})
+
+	format := func(contents string) ([]genall.RewrittenFile, error) {
+		Expect(os.WriteFile(filepath.Join(dir, "a.go"), []byte(contents), 0o644)).To(Succeed())
+		roots, err := loader.LoadRootsWithConfig(&packages.Config{Dir: dir}, "./...")
+		Expect(err).NotTo(HaveOccurred())
+		return genall.FormatMarkers(col, roots)
+	}
+
+	It("should rewrite markers into their canonical form, and leave the rest alone", func() {
+		files, err := format("package a\n\ntype Foo struct {\n\t// A is a field.\n\t// +testing:enum:strict=true,values=a;b\n\t// +testing:pattern=\"^\\\\d$\"\n\t// +testing:unknown=a;b\n\tA string\n}\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		Expect(files[0].Rewrites).To(HaveLen(2))
+		Expect(string(files[0].Rewritten)).To(Equal("package a\n\ntype Foo struct {\n\t// A is a field.\n\t// +testing:enum:values={a, b},strict=true\n\t// +testing:pattern=`^\\d$`\n\t// +testing:unknown=a;b\n\tA string\n}\n"))
+	})
+
+	It("should continue long markers over several lines, one argument per line", func() {
+		values := "{aaaaaaaaaa, bbbbbbbbbb, cccccccccc, dddddddddd, eeeeeeeeee, ffffffffff, gggggggggg}"
+		files, err := format("package a\n\ntype Foo struct {\n\t// +testing:enum:strict=true,values=" + values + "\n\tA string\n}\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		formatted := "package a\n\ntype Foo struct {\n\t// +testing:enum:values=" + values + ",\\\n\t// strict=true\n\tA string\n}\n"
+		Expect(string(files[0].Rewritten)).To(Equal(formatted))
+
+		By("formatting the result again")
+		files, err = format(formatted)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(BeEmpty())
+	})
+
+	It("should leave formatted files alone", func() {
+		files, err := format("package a\n\ntype Foo struct {\n\t// +testing:enum:values={a, b}\n\tA string\n}\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(BeEmpty())
+	})
+
+	It("should report markers that fail to parse", func() {
+		files, err := format("package a\n\ntype Foo struct {\n\t// +testing:enum:strict=yes\n\tA string\n}\n")
+		Expect(err).To(MatchError(MatchRegexp(`a\.go:4:2: unable to parse \+testing:enum:strict=yes`)))
+		Expect(files).To(BeEmpty())
+	})
+})
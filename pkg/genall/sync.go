@@ -0,0 +1,113 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// OutputSyncer writes artifacts via FileOutputRules only when their contents
+// differ from the file already on disk, so that unchanged files keep their
+// modification times (and don't trigger needless rebuilds in tools like Make
+// or Bazel).  Once generation has finished, Prune removes generated files
+// that are no longer produced.
+//
+// Call WrapOutputRules on a Runtime before running it.  Only
+// FileOutputRules are affected -- other rules (like stdout) are left as-is.
+type OutputSyncer struct {
+	artifactTracker
+}
+
+// WrapOutputRules replaces the given runtime's output rules, such that each
+// FileOutputRule only writes artifacts whose contents have changed.
+func (s *OutputSyncer) WrapOutputRules(rt *Runtime) error {
+	return wrapFileRules(rt, &s.artifactTracker, func(rule FileOutputRule) FileOutputRule {
+		return syncOutput{rule: rule, syncer: s}
+	})
+}
+
+// Prune removes files in the configured output directories that carry
+// controller-gen's attribution, but weren't produced during this run,
+// returning the paths of the removed files.  Subdirectories are only checked
+// if something was written to them during this run, and directories that
+// artifacts are written to outside of the output directories (like Go package
+// directories) are never checked.
+//
+// It should only be called after a successful run, otherwise files from
+// generators that failed part of the way through would be removed.
+func (s *OutputSyncer) Prune() ([]string, error) {
+	orphans, err := s.orphans()
+	if err != nil {
+		return nil, err
+	}
+	removed := make([]string, 0, len(orphans))
+	for _, path := range orphans {
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, displayPath(path))
+	}
+	return removed, nil
+}
+
+// syncOutput buffers artifacts for an OutputSyncer, writing them with the
+// underlying rule only if they've changed.
+type syncOutput struct {
+	rule   FileOutputRule
+	syncer *OutputSyncer
+}
+
+func (o syncOutput) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	path, err := o.rule.PathFor(pkg, itemPath)
+	if err != nil {
+		return nil, err
+	}
+	return &bufferedArtifact{onClose: func(contents []byte) error {
+		if err := o.syncer.produce(path, contents); err != nil {
+			return err
+		}
+
+		existing, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err == nil && bytes.Equal(existing, contents) {
+			return nil
+		}
+
+		out, err := o.rule.Open(pkg, itemPath)
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(contents); err != nil {
+			out.Close()
+			return fmt.Errorf("unable to write %s: %w", path, err)
+		}
+		return out.Close()
+	}}, nil
+}
+
+func (o syncOutput) PathFor(pkg *loader.Package, itemPath string) (string, error) {
+	return o.rule.PathFor(pkg, itemPath)
+}
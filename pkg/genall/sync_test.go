@@ -0,0 +1,132 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+var _ = Describe("OutputSyncer", func() {
+	var outDir string
+	var gen fakeGenerator
+	var syncer *genall.OutputSyncer
+
+	BeforeEach(func() {
+		outDir = GinkgoT().TempDir()
+		gen = fakeGenerator{artifacts: map[string]string{"foo.yaml": generatedYAML}}
+		syncer = &genall.OutputSyncer{}
+	})
+
+	run := func(rt *genall.Runtime) {
+		Expect(syncer.WrapOutputRules(rt)).To(Succeed())
+		Expect(rt.Run()).To(BeFalse())
+	}
+
+	It("should leave unchanged files alone", func() {
+		path := filepath.Join(outDir, "foo.yaml")
+		Expect(os.WriteFile(path, []byte(generatedYAML), 0o644)).To(Succeed())
+		longAgo := time.Now().Add(-time.Hour).Truncate(time.Second)
+		Expect(os.Chtimes(path, longAgo, longAgo)).To(Succeed())
+
+		run(runtimeFor(gen, genall.OutputToDirectory(outDir)))
+
+		info, err := os.Stat(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.ModTime()).To(BeTemporally("==", longAgo))
+	})
+
+	It("should write new and changed files", func() {
+		Expect(os.WriteFile(filepath.Join(outDir, "foo.yaml"), []byte("old\n"), 0o644)).To(Succeed())
+		gen.artifacts["sub/bar.yaml"] = "bar\n"
+
+		run(runtimeFor(gen, genall.OutputToDirectory(outDir)))
+
+		Expect(os.ReadFile(filepath.Join(outDir, "foo.yaml"))).To(BeEquivalentTo(generatedYAML))
+		Expect(os.ReadFile(filepath.Join(outDir, "sub", "bar.yaml"))).To(BeEquivalentTo("bar\n"))
+	})
+
+	It("should prune generated files that are no longer produced", func() {
+		Expect(os.WriteFile(filepath.Join(outDir, "old.yaml"), []byte(generatedYAML), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(outDir, "handwritten.yaml"), []byte("kind: Foo\n"), 0o644)).To(Succeed())
+
+		run(runtimeFor(gen, genall.OutputToDirectory(outDir)))
+		removed, err := syncer.Prune()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(removed).To(ConsistOf(HaveSuffix("old.yaml")))
+		Expect(filepath.Join(outDir, "old.yaml")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(outDir, "handwritten.yaml")).To(BeAnExistingFile())
+		Expect(filepath.Join(outDir, "foo.yaml")).To(BeAnExistingFile())
+	})
+
+	It("should not prune directories that belong to rules no generator uses", func() {
+		unusedDir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(unusedDir, "other.yaml"), []byte(generatedYAML), 0o644)).To(Succeed())
+
+		rt := runtimeFor(gen, genall.OutputToDirectory(unusedDir))
+		rt.OutputRules.ByGenerator = map[*genall.Generator]genall.OutputRule{
+			rt.Generators[0]: genall.OutputToDirectory(outDir),
+		}
+		run(rt)
+		removed, err := syncer.Prune()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(removed).To(BeEmpty())
+		Expect(filepath.Join(unusedDir, "other.yaml")).To(BeAnExistingFile())
+	})
+
+	It("should not prune directories that artifacts are written to outside of the output directories", func() {
+		Expect(os.WriteFile(filepath.Join(outDir, "zz_generated.other.go"), []byte("// Code generated by controller-gen. DO NOT EDIT.\n\npackage foo\n"), 0o644)).To(Succeed())
+
+		run(runtimeFor(gen, packageDirRule(outDir)))
+		removed, err := syncer.Prune()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(removed).To(BeEmpty())
+		Expect(filepath.Join(outDir, "zz_generated.other.go")).To(BeAnExistingFile())
+		Expect(filepath.Join(outDir, "foo.yaml")).To(BeAnExistingFile())
+	})
+})
+
+// packageDirRule writes artifacts into a directory that isn't an output
+// directory (like a Go package's directory), so its PathFor yields no
+// directory for non-package-associated artifacts.
+type packageDirRule string
+
+func (r packageDirRule) Open(pkg *loader.Package, itemPath string) (io.WriteCloser, error) {
+	path, err := r.PathFor(pkg, itemPath)
+	if err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+func (r packageDirRule) PathFor(_ *loader.Package, itemPath string) (string, error) {
+	if itemPath == "" {
+		return "", nil
+	}
+	return filepath.Join(string(r), itemPath), nil
+}
@@ -0,0 +1,261 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// attributionMarkers are strings that controller-gen writes into the
+// artifacts it produces, used to recognize generated files on disk.
+var attributionMarkers = [][]byte{
+	[]byte("Code generated by controller-gen. DO NOT EDIT."),
+	[]byte("controller-gen.kubebuilder.io/version"),
+}
+
+// hasAttribution checks if the given file contents look like they were
+// produced by controller-gen.
+func hasAttribution(contents []byte) bool {
+	for _, marker := range attributionMarkers {
+		if bytes.Contains(contents, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// artifactTracker records the artifacts produced through a set of
+// FileOutputRules, as well as the output directories configured for those
+// rules, so that generated files that are no longer produced can be found.
+//
+// Only the configured output directories are checked, never the directories
+// that artifacts happen to be written to (like Go package directories), since
+// those may contain generated files from other generators or runs.
+type artifactTracker struct {
+	mu sync.Mutex
+	// produced maps the absolute path of each produced artifact to its
+	// contents.
+	produced map[string][]byte
+	// dirs are the absolute paths of the directories to check for
+	// generated files that weren't produced.
+	dirs map[string]struct{}
+}
+
+// trackDir marks the given directory as containing generated artifacts.
+// An empty dir is ignored, since it doesn't point at an output directory
+// (and would otherwise mean the working directory).
+func (t *artifactTracker) trackDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.dirs == nil {
+		t.dirs = make(map[string]struct{})
+	}
+	t.dirs[absDir] = struct{}{}
+	return nil
+}
+
+// produce records the contents of an artifact produced at the given path.
+func (t *artifactTracker) produce(path string, contents []byte) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.produced == nil {
+		t.produced = make(map[string][]byte)
+	}
+	t.produced[absPath] = contents
+	return nil
+}
+
+// producedPaths returns the absolute paths of all produced artifacts, sorted.
+func (t *artifactTracker) producedPaths() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	paths := make([]string, 0, len(t.produced))
+	for path := range t.produced {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// displayPath returns the path to show to users for the given absolute
+// path, preferring a path relative to the working directory.
+func displayPath(absPath string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return absPath
+	}
+	relPath, err := filepath.Rel(wd, absPath)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return absPath
+	}
+	return relPath
+}
+
+// withinDir checks if the given absolute path is inside the given absolute
+// directory (or is the directory itself).
+func withinDir(dir, path string) bool {
+	relPath, err := filepath.Rel(dir, path)
+	return err == nil && relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
+
+// orphans returns the absolute paths of files in the tracked directories
+// that carry controller-gen's attribution, but weren't produced, sorted.
+// Subdirectories of the tracked directories are only checked if something
+// was produced in them during this run (e.g. with per-group layouts).
+func (t *artifactTracker) orphans() ([]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	dirs := make(map[string]struct{}, len(t.dirs))
+	for dir := range t.dirs {
+		dirs[dir] = struct{}{}
+	}
+	for path := range t.produced {
+		producedDir := filepath.Dir(path)
+		for dir := range t.dirs {
+			if withinDir(dir, producedDir) {
+				dirs[producedDir] = struct{}{}
+				break
+			}
+		}
+	}
+
+	var orphans []string
+	for dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if _, wasProduced := t.produced[path]; wasProduced {
+				continue
+			}
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if hasAttribution(contents) {
+				orphans = append(orphans, path)
+			}
+		}
+	}
+	slices.Sort(orphans)
+	return orphans, nil
+}
+
+// bufferedArtifact buffers the contents of an artifact, handing them to
+// the given function once closed.
+type bufferedArtifact struct {
+	bytes.Buffer
+	onClose func(contents []byte) error
+}
+
+func (b *bufferedArtifact) Close() error {
+	return b.onClose(b.Bytes())
+}
+
+// wrapFileRules replaces each FileOutputRule in the given runtime's output
+// rules using the given function, tracking the output directories (where
+// non-package-associated artifacts are written) of the rules used by the
+// runtime's generators.
+func wrapFileRules(rt *Runtime, tracker *artifactTracker, wrap func(FileOutputRule) FileOutputRule) error {
+	// only track directories for rules that are actually used, so that we
+	// don't go poking around in directories that belong to generators that
+	// aren't being run.
+	for _, gen := range rt.Generators {
+		fileRule, isFileRule := rt.OutputRules.ForGenerator(gen).(FileOutputRule)
+		if !isFileRule {
+			continue
+		}
+		dir, err := fileRule.PathFor(nil, "")
+		if err != nil {
+			return err
+		}
+		if err := tracker.trackDir(dir); err != nil {
+			return err
+		}
+	}
+
+	mapFileRules(rt, wrap, wrap)
+	return nil
+}
+
+// chartOutputRule is an OutputRule (like OutputToHelm) that writes each
+// artifact as several files, using FileOutputRules of its own.
+type chartOutputRule interface {
+	OutputRule
+	// mapFileRules replaces the rules used to write plain resources, and
+	// other files, using the given functions (leaving the rules as-is for
+	// nil functions).
+	mapFileRules(wrapResources, wrapOther func(FileOutputRule) FileOutputRule) OutputRule
+}
+
+// mapFileRules replaces each FileOutputRule in the given runtime's output
+// rules using the given function, leaving other rules as-is.  Rules that
+// write files using rules of their own (chartOutputRules) have those
+// replaced instead: the ones that write plain resources using wrap, and
+// the ones that write the chart's other files using wrapChartFiles (or
+// left as-is, if it's nil).
+func mapFileRules(rt *Runtime, wrap, wrapChartFiles func(FileOutputRule) FileOutputRule) {
+	wrapRule := func(rule OutputRule) OutputRule {
+		if chartRule, isChartRule := rule.(chartOutputRule); isChartRule {
+			return chartRule.mapFileRules(wrap, wrapChartFiles)
+		}
+		if fileRule, isFileRule := rule.(FileOutputRule); isFileRule {
+			return wrap(fileRule)
+		}
+		return rule
+	}
+
+	res := OutputRules{
+		ByGenerator: make(map[*Generator]OutputRule, len(rt.OutputRules.ByGenerator)),
+	}
+	if rt.OutputRules.Default != nil {
+		res.Default = wrapRule(rt.OutputRules.Default)
+	}
+	for gen, rule := range rt.OutputRules.ByGenerator {
+		res.ByGenerator[gen] = wrapRule(rule)
+	}
+	rt.OutputRules = res
+}
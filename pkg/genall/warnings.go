@@ -0,0 +1,237 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// NoLintMarkerName is the name of the marker used to suppress warnings.
+const NoLintMarkerName = "kubebuilder:nolint"
+
+const (
+	// UnknownMarkerCode is the code of warnings about unknown (e.g.
+	// misspelled) markers (see Runtime.KnownMarkers).
+	UnknownMarkerCode = "unknown-marker"
+	// MisplacedMarkerCode is the code of warnings about markers that are
+	// on the wrong kind of node (e.g. field markers on a type).
+	MisplacedMarkerCode = "misplaced-marker"
+)
+
+var (
+	noLintMarkers = []*markers.Definition{
+		noLintDefinition(markers.DescribesPackage),
+		noLintDefinition(markers.DescribesType),
+		noLintDefinition(markers.DescribesField),
+	}
+)
+
+func noLintDefinition(target markers.TargetType) *markers.Definition {
+	def := markers.Must(markers.MakeDefinition(NoLintMarkerName, target, NoLint{}))
+	def.MatchesSubNames = true
+	return def
+}
+
+// +controllertools:marker:generateHelp:category=Warnings
+
+// NoLint suppresses warnings about the package, type, or field it's on.
+//
+// Warnings about anything inside a suppressed package or type are suppressed too.
+//
+// The code of the warning to suppress is given as part of the marker's name, as in
+// `+kubebuilder:nolint:some-code`.  Without a code, as in `+kubebuilder:nolint`,
+// all warnings are suppressed.
+type NoLint struct {
+	// code is the code of the warning to suppress, or empty for all of them.
+	// It's unexported since it's not specified as an argument.
+	code string
+}
+
+// ParseMarker implements the markers package's custom parsing hook, taking the
+// warning code from the marker's name.
+func (n *NoLint) ParseMarker(_ string, anonymousName string, restFields string) error {
+	if restFields != "" {
+		return fmt.Errorf("%s takes no arguments (use +%s:<code> to suppress a particular warning)", NoLintMarkerName, NoLintMarkerName)
+	}
+	n.code = strings.TrimPrefix(strings.TrimPrefix(anonymousName, NoLintMarkerName), ":")
+	return nil
+}
+
+// suppresses checks if this marker suppresses warnings with the given code.
+func (n NoLint) suppresses(code string) bool {
+	return n.code == "" || n.code == code
+}
+
+// RegisterNoLintMarkers registers the markers used to suppress warnings into
+// the given registry.  Runtimes produced by ForRoots do this automatically.
+func RegisterNoLintMarkers(into *markers.Registry) error {
+	for _, def := range noLintMarkers {
+		if err := into.Register(def); err != nil {
+			return err
+		}
+		// NB: optional, like for InputPaths, to avoid a bootstrap problem with helpgen
+		if helpGiver, hasHelp := ((any)(NoLint{})).(HasHelp); hasHelp {
+			into.AddHelp(def, helpGiver.Help())
+		}
+	}
+	return nil
+}
+
+// Warning is a problem that doesn't stop a Generator from producing its
+// output, but that the user probably wants to know about.
+type Warning struct {
+	// Code identifies the kind of warning.  Codes should be stable, since
+	// they're used to suppress warnings (see NoLint).
+	Code string
+	// Message describes the problem.
+	Message string
+	// Position is where the problem is, if known.
+	Position token.Position
+}
+
+// format formats the warning as text, labeled as e.g. "warning".
+func (w Warning) format(label string) string {
+	if w.Position.IsValid() {
+		return fmt.Sprintf("%s: %s: %s [%s]", w.Position, label, w.Message, w.Code)
+	}
+	return fmt.Sprintf("%s: %s [%s]", label, w.Message, w.Code)
+}
+
+// warningSink collects the warnings reported by a single Generator.
+type warningSink struct {
+	mu       sync.Mutex
+	warnings []Warning
+}
+
+func (s *warningSink) add(w Warning) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.warnings = append(s.warnings, w)
+}
+
+// Warn reports a warning with the given code about the given node in the
+// given package, unless it's been suppressed with a NoLint marker on the
+// node, or on the type or package containing it.  The node may be nil if
+// there's no particular position to report.
+func (g GenerationContext) Warn(pkg *loader.Package, node ast.Node, code, message string) {
+	warning := Warning{Code: code, Message: message}
+	if pkg != nil && node != nil {
+		if g.suppressed(pkg, node, code) {
+			return
+		}
+		if fset := pkg.FileSet(); fset != nil {
+			warning.Position = fset.Position(node.Pos())
+		}
+	}
+	if g.warnings == nil {
+		// not being run by a Runtime, so there's nowhere else to put it.
+		fmt.Fprintln(os.Stderr, warning.format("warning"))
+		return
+	}
+	g.warnings.add(warning)
+}
+
+// suppressed checks if warnings with the given code are suppressed for the
+// given node by a NoLint marker on it, or on something containing it.
+func (g GenerationContext) suppressed(pkg *loader.Package, node ast.Node, code string) bool {
+	if g.Collector == nil {
+		return false
+	}
+	// errors here are reported by the Generators when they collect markers.
+	nodeMarkers, err := g.Collector.MarkersInPackage(pkg)
+	if err != nil {
+		return false
+	}
+	for markedNode, markerValues := range nodeMarkers {
+		noLints := markerValues[NoLintMarkerName]
+		if len(noLints) == 0 {
+			continue
+		}
+		// package-level markers apply to the whole package, not just their file.
+		if _, isFile := markedNode.(*ast.File); !isFile && (node.Pos() < markedNode.Pos() || node.End() > markedNode.End()) {
+			continue
+		}
+		for _, noLint := range noLints {
+			if noLint.(NoLint).suppresses(code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reportWarnings reports the warnings from the given Generator, as errors
+// if WarningsAsErrors is set, returning true if any were reported as errors.
+func (r *Runtime) reportWarnings(gen *Generator, sink *warningSink) bool {
+	severity, label := SeverityWarning, "warning"
+	if r.WarningsAsErrors {
+		severity, label = SeverityError, "error"
+	}
+	for _, warning := range sink.warnings {
+		if r.Diagnostics == nil {
+			fmt.Fprintln(r.ErrorWriter, warning.format(label))
+			continue
+		}
+		diag := Diagnostic{
+			Severity:  severity,
+			Message:   warning.Message,
+			Code:      warning.Code,
+			Generator: r.GeneratorNames[gen],
+		}
+		if warning.Position.IsValid() {
+			diag.Filename, diag.Line, diag.Column = warning.Position.Filename, warning.Position.Line, warning.Position.Column
+		}
+		r.Diagnostics.Add(diag)
+	}
+	return r.WarningsAsErrors && len(sink.warnings) > 0
+}
+
+// checkMarkers finds the unknown and misplaced markers in the roots (see
+// Runtime.KnownMarkers), returning them as warnings.
+func (r *Runtime) checkMarkers() *warningSink {
+	reg := &markers.Registry{}
+	for _, known := range []*markers.Registry{r.KnownMarkers, r.Collector.Registry} {
+		for _, def := range known.AllDefinitions() {
+			// can't fail, since the definitions were already registered once
+			_ = reg.Register(def)
+		}
+	}
+	col := &markers.Collector{Registry: reg, Macros: r.Collector.Macros}
+
+	sink := &warningSink{}
+	for _, root := range r.Roots {
+		for _, unknown := range col.UnknownMarkers(root, markers.UnknownMarkerPrefixes) {
+			code := UnknownMarkerCode
+			if unknown.Misplaced() {
+				code = MisplacedMarkerCode
+			}
+			if r.suppressed(root, unknown.Node, code) {
+				continue
+			}
+			sink.add(Warning{Code: code, Message: unknown.Message(), Position: root.FileSet().Position(unknown.Pos)})
+		}
+	}
+	return sink
+}
@@ -0,0 +1,163 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/tools/go/packages"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// warningGenerator warns about every field of every type.
+type warningGenerator struct{}
+
+func (warningGenerator) RegisterMarkers(*markers.Registry) error { return nil }
+
+func (warningGenerator) Generate(ctx *genall.GenerationContext) error {
+	for _, root := range ctx.Roots {
+		if err := markers.EachType(ctx.Collector, root, func(info *markers.TypeInfo) {
+			for _, field := range info.Fields {
+				ctx.Warn(root, field.RawField, "test-field", "field "+info.Name+"."+field.Name)
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ = Describe("Warnings", func() {
+	var rt *genall.Runtime
+	var errOut *bytes.Buffer
+
+	load := func(contents string) {
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/warnings\n\ngo 1.22\n"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "a.go"), []byte(contents), 0o644)).To(Succeed())
+
+		gens := genall.Generators{new(genall.Generator)}
+		*gens[0] = warningGenerator{}
+		var err error
+		rt, err = gens.ForRootsWithConfig(&packages.Config{Dir: dir}, "./...")
+		Expect(err).NotTo(HaveOccurred())
+		errOut = &bytes.Buffer{}
+		rt.ErrorWriter = errOut
+	}
+
+	It("should report warnings with their positions and codes, without failing", func() {
+		load("package a\n\ntype Foo struct {\n\tA string\n}\n")
+		Expect(rt.Run()).To(BeFalse())
+		Expect(errOut.String()).To(MatchRegexp(`a\.go:4:2: warning: field Foo\.A \[test-field\]\n$`))
+	})
+
+	It("should fail when reporting warnings as errors", func() {
+		load("package a\n\ntype Foo struct {\n\tA string\n}\n")
+		rt.WarningsAsErrors = true
+		Expect(rt.Run()).To(BeTrue())
+		Expect(errOut.String()).To(MatchRegexp(`a\.go:4:2: error: field Foo\.A \[test-field\]\n$`))
+	})
+
+	It("should skip warnings suppressed on a field", func() {
+		load("package a\n\ntype Foo struct {\n\t// +kubebuilder:nolint:test-field\n\tA string\n\tB string\n}\n")
+		Expect(rt.Run()).To(BeFalse())
+		Expect(errOut.String()).NotTo(ContainSubstring("Foo.A"))
+		Expect(errOut.String()).To(ContainSubstring("Foo.B"))
+	})
+
+	It("should skip warnings suppressed on the containing type", func() {
+		load("package a\n\n// +kubebuilder:nolint:test-field\ntype Foo struct {\n\tA string\n}\n\ntype Bar struct {\n\tB string\n}\n")
+		Expect(rt.Run()).To(BeFalse())
+		Expect(errOut.String()).NotTo(ContainSubstring("Foo.A"))
+		Expect(errOut.String()).To(ContainSubstring("Bar.B"))
+	})
+
+	It("should skip warnings suppressed on the package", func() {
+		load("// +kubebuilder:nolint\npackage a\n\ntype Foo struct {\n\tA string\n}\n")
+		rt.WarningsAsErrors = true
+		Expect(rt.Run()).To(BeFalse())
+		Expect(errOut.String()).To(BeEmpty())
+	})
+
+	It("shouldn't skip warnings with other codes", func() {
+		load("package a\n\ntype Foo struct {\n\t// +kubebuilder:nolint:other-code\n\tA string\n}\n")
+		Expect(rt.Run()).To(BeFalse())
+		Expect(errOut.String()).To(ContainSubstring("Foo.A"))
+	})
+
+	It("should report warnings as diagnostics", func() {
+		load("package a\n\ntype Foo struct {\n\tA string\n}\n")
+		rt.Diagnostics = &genall.Diagnostics{}
+		Expect(rt.Run()).To(BeFalse())
+		Expect(rt.Diagnostics.Items()).To(ConsistOf(And(
+			HaveField("Severity", genall.SeverityWarning),
+			HaveField("Code", "test-field"),
+			HaveField("Line", 4),
+			HaveField("Column", 2),
+		)))
+	})
+
+	Context("with known markers", func() {
+		var known *markers.Registry
+
+		BeforeEach(func() {
+			known = &markers.Registry{}
+			Expect(known.Define("kubebuilder:validation:Minimum", markers.DescribesField, 0)).To(Succeed())
+		})
+
+		It("should warn about misspelled markers, with suggestions", func() {
+			load("package a\n\ntype Foo struct {\n\t// +kubebuilder:validation:Minimun=1\n\tA int\n}\n")
+			rt.KnownMarkers = known
+			Expect(rt.Run()).To(BeFalse())
+			Expect(errOut.String()).To(ContainSubstring(`a.go:4:2: warning: unknown marker "+kubebuilder:validation:Minimun=1" (did you mean +kubebuilder:validation:Minimum?) [unknown-marker]`))
+		})
+
+		It("should warn about markers on the wrong kind of node", func() {
+			load("package a\n\n// +kubebuilder:validation:Minimum=1\ntype Foo int\n")
+			rt.KnownMarkers = known
+			Expect(rt.Run()).To(BeFalse())
+			Expect(errOut.String()).To(ContainSubstring(`a.go:3:1: warning: marker "+kubebuilder:validation:Minimum=1" is not valid on a type (only on a field) [misplaced-marker]`))
+		})
+
+		It("should fail on unknown markers when reporting warnings as errors", func() {
+			load("// +kubebuilder:validaton:Required\npackage a\n")
+			rt.KnownMarkers = known
+			rt.WarningsAsErrors = true
+			Expect(rt.Run()).To(BeTrue())
+			Expect(errOut.String()).To(ContainSubstring(`error: unknown marker "+kubebuilder:validaton:Required" [unknown-marker]`))
+		})
+
+		It("should skip unknown markers suppressed on the package", func() {
+			load("// +kubebuilder:nolint:unknown-marker\npackage a\n\n// +kubebuilder:nonsense\ntype Foo int\n")
+			rt.KnownMarkers = known
+			Expect(rt.Run()).To(BeFalse())
+			Expect(errOut.String()).To(BeEmpty())
+		})
+
+		It("shouldn't check markers unless asked to", func() {
+			load("package a\n\n// +kubebuilder:nonsense\ntype Foo int\n")
+			Expect(rt.Run()).To(BeFalse())
+			Expect(errOut.String()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,517 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// DefaultWatchInterval is the default interval at which a Watcher polls for
+// changes.
+const DefaultWatchInterval = 500 * time.Millisecond
+
+// Watcher keeps a Runtime alive, polling the Go files of its roots (and any
+// files that Generators read through their InputRule) for changes, and
+// re-running the Generators affected by each change.
+//
+// Changed packages (and the loaded packages that depend on them) are
+// re-parsed and re-checked in place, so unchanged packages and their
+// collected markers are reused.  When that's not possible (files were added
+// or removed, or imports changed), the Runtime is loaded from scratch.
+//
+// A Generator is considered affected by a change to a Go package if the
+// package (before or after the change) contains any of its markers, or if
+// it re-checks a package that does.  It's affected by a change to a file it
+// read if it read that file during its last run.
+type Watcher struct {
+	// Load constructs the Runtime to run.  It's called once when starting,
+	// and again whenever changes can't be applied to the existing Runtime.
+	Load func() (*Runtime, error)
+	// Interval is how often to poll for changes.  Defaults to
+	// DefaultWatchInterval.
+	Interval time.Duration
+	// Log receives messages about changes and reloads.  Defaults to
+	// os.Stderr.
+	Log io.Writer
+
+	rt *Runtime
+	// markerNames are the names of the markers registered by each Generator.
+	markerNames map[*Generator]map[string]struct{}
+	// pkgMarkers are the names of the markers found in each root as of the
+	// last run.
+	pkgMarkers map[*loader.Package]map[string]struct{}
+	// reads tracks the files each Generator read during its last run.
+	reads *readTracker
+
+	// files are the stamps of the watched files, as of the last poll.
+	files map[string]fileStamp
+	// dirs are the Go files in each root's directory, as of the last poll.
+	dirs map[string][]string
+	// filePkgs maps the Go files of the roots to their packages.
+	filePkgs map[string]*loader.Package
+}
+
+// Watch loads the Runtime and runs all of its Generators, then polls for
+// changes until the given context is done.  Errors from Generators are
+// reported (like Runtime.Run does) but don't stop the watch.  It only
+// returns an error if the initial load fails.
+func (w *Watcher) Watch(ctx context.Context) error {
+	if w.Log == nil {
+		w.Log = os.Stderr
+	}
+	if w.Interval <= 0 {
+		w.Interval = DefaultWatchInterval
+	}
+	if err := w.reload(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			w.poll()
+		}
+	}
+}
+
+// reload loads a fresh Runtime and runs all of its Generators.
+func (w *Watcher) reload() error {
+	rt, err := w.Load()
+	if err != nil {
+		return err
+	}
+	w.rt = rt
+	w.reads = &readTracker{}
+	rt.inputFor = w.reads.inputFor
+	if rt.ErrorWriter == nil {
+		rt.ErrorWriter = os.Stderr
+	}
+
+	w.markerNames = make(map[*Generator]map[string]struct{}, len(rt.Generators))
+	for _, gen := range rt.Generators {
+		reg := &markers.Registry{}
+		if err := (*gen).RegisterMarkers(reg); err != nil {
+			return err
+		}
+		names := make(map[string]struct{})
+		for _, defn := range reg.AllDefinitions() {
+			names[defn.Name] = struct{}{}
+		}
+		w.markerNames[gen] = names
+	}
+	w.pkgMarkers = make(map[*loader.Package]map[string]struct{}, len(rt.Roots))
+
+	w.filePkgs = make(map[string]*loader.Package)
+	for _, root := range rt.Roots {
+		for _, file := range root.GoFiles {
+			w.filePkgs[file] = root
+		}
+	}
+
+	w.run(rt.Generators)
+	return nil
+}
+
+// run runs the given Generators (resetting any errors from previous runs
+// first), and records what they depended on.
+func (w *Watcher) run(gens Generators) {
+	for _, pkg := range w.checkedPackages() {
+		pkg.ResetErrors()
+	}
+	for _, gen := range gens {
+		w.reads.forget(gen)
+	}
+
+	if len(gens) > 0 {
+		if hadErrs := w.rt.run(gens); hadErrs {
+			fmt.Fprintln(w.Log, "not all generators ran successfully")
+		}
+	}
+
+	for _, root := range w.rt.Roots {
+		if _, known := w.pkgMarkers[root]; !known {
+			w.pkgMarkers[root] = w.markerNamesIn(root)
+		}
+	}
+	w.files, w.dirs = w.snapshot()
+}
+
+// poll checks for changes, and re-runs the affected Generators.
+func (w *Watcher) poll() {
+	files, dirs := w.snapshot()
+	var changed []string
+	for path, stamp := range files {
+		if oldStamp, known := w.files[path]; !known || oldStamp != stamp {
+			changed = append(changed, path)
+		}
+	}
+	structural := false
+	for dir, goFiles := range dirs {
+		if !slices.Equal(goFiles, w.dirs[dir]) {
+			structural = true
+		}
+	}
+	if len(changed) == 0 && !structural {
+		return
+	}
+	slices.Sort(changed)
+
+	// figure out which root packages changed
+	changedPkgs := make(map[*loader.Package]struct{})
+	var changedReads []string
+	for _, path := range changed {
+		if pkg, isGo := w.filePkgs[path]; isGo {
+			changedPkgs[pkg] = struct{}{}
+			if importsChanged(pkg) {
+				structural = true
+			}
+			continue
+		}
+		changedReads = append(changedReads, path)
+	}
+
+	if structural {
+		fmt.Fprintf(w.Log, "reloading packages after changes to %s\n", describePaths(changed, dirs, w.dirs))
+		if err := w.reload(); err != nil {
+			fmt.Fprintln(w.Log, err)
+			// don't keep retrying until something changes again
+			w.files, w.dirs = files, dirs
+		}
+		return
+	}
+
+	affected := w.invalidate(changedPkgs)
+	for _, gen := range w.rt.Generators {
+		if w.reads.readAny(gen, changedReads) {
+			affected[gen] = struct{}{}
+		}
+	}
+	var gens Generators
+	for _, gen := range w.rt.Generators {
+		if _, isAffected := affected[gen]; isAffected {
+			gens = append(gens, gen)
+		}
+	}
+
+	fmt.Fprintf(w.Log, "re-running %d of %d generators after changes to %s\n", len(gens), len(w.rt.Generators), describePaths(changed, nil, nil))
+	w.run(gens)
+}
+
+// invalidate invalidates the given packages, along with every checked
+// package that depends on them, returning the Generators affected by the
+// invalidated roots.
+func (w *Watcher) invalidate(changed map[*loader.Package]struct{}) map[*Generator]struct{} {
+	// compute reverse dependencies amongst the packages we've checked --
+	// anything that hasn't been checked doesn't have stale type information.
+	importedBy := make(map[*loader.Package][]*loader.Package)
+	for _, pkg := range w.checkedPackages() {
+		for _, imp := range pkg.Imports() {
+			importedBy[imp] = append(importedBy[imp], pkg)
+		}
+	}
+
+	invalid := make(map[*loader.Package]struct{})
+	queue := make([]*loader.Package, 0, len(changed))
+	for pkg := range changed {
+		queue = append(queue, pkg)
+	}
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		if _, seen := invalid[pkg]; seen {
+			continue
+		}
+		invalid[pkg] = struct{}{}
+		queue = append(queue, importedBy[pkg]...)
+	}
+
+	affected := make(map[*Generator]struct{})
+	for pkg := range invalid {
+		pkg.Invalidate()
+		if w.rt.Checker != nil {
+			w.rt.Checker.Forget(pkg)
+		}
+		w.rt.Collector.Forget(pkg)
+
+		oldMarkers, isRoot := w.pkgMarkers[pkg]
+		if !isRoot {
+			continue
+		}
+		newMarkers := w.markerNamesIn(pkg)
+		w.pkgMarkers[pkg] = newMarkers
+		for gen, names := range w.markerNames {
+			if len(names) == 0 || intersects(names, oldMarkers) || intersects(names, newMarkers) {
+				affected[gen] = struct{}{}
+			}
+		}
+	}
+	return affected
+}
+
+// markerNamesIn returns the names of all markers in the given package.
+func (w *Watcher) markerNamesIn(pkg *loader.Package) map[string]struct{} {
+	names := make(map[string]struct{})
+	byNode, err := w.rt.Collector.MarkersInPackage(pkg)
+	if err != nil {
+		// we'll just treat this as having no markers -- the error will be
+		// reported by whichever generator tries to collect them.
+		return names
+	}
+	for _, values := range byNode {
+		for name := range values {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}
+
+// checkedPackages returns the roots, plus all the packages reachable from
+// them that have been type-checked.
+func (w *Watcher) checkedPackages() []*loader.Package {
+	seen := make(map[*loader.Package]struct{})
+	var res []*loader.Package
+	var visit func(pkg *loader.Package)
+	visit = func(pkg *loader.Package) {
+		if _, alreadySeen := seen[pkg]; alreadySeen {
+			return
+		}
+		seen[pkg] = struct{}{}
+		res = append(res, pkg)
+		if pkg.TypesInfo == nil {
+			return
+		}
+		for _, imp := range pkg.Imports() {
+			if imp.TypesInfo != nil {
+				visit(imp)
+			}
+		}
+	}
+	for _, root := range w.rt.Roots {
+		visit(root)
+	}
+	return res
+}
+
+// snapshot stamps all the watched files, and lists the Go files in the
+// directories of the roots.
+func (w *Watcher) snapshot() (map[string]fileStamp, map[string][]string) {
+	files := make(map[string]fileStamp, len(w.filePkgs))
+	dirs := make(map[string][]string)
+	for path := range w.filePkgs {
+		files[path] = stampFile(path)
+		dir := filepath.Dir(path)
+		if _, listed := dirs[dir]; !listed {
+			dirs[dir] = listGoFiles(dir)
+		}
+	}
+	for _, path := range w.reads.allPaths() {
+		files[path] = stampFile(path)
+	}
+	return files, dirs
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func stampFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// listGoFiles lists the non-test Go files in the given directory.
+func listGoFiles(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+// importsChanged checks if the imports in the given package's files differ
+// from those it was loaded with.
+func importsChanged(pkg *loader.Package) bool {
+	fset := token.NewFileSet()
+	imports := make(map[string]struct{})
+	for _, path := range pkg.GoFiles {
+		file, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+		if err != nil {
+			// we'll let the regular parse report this
+			continue
+		}
+		for _, spec := range file.Imports {
+			impPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil || impPath == "C" {
+				continue
+			}
+			imports[impPath] = struct{}{}
+		}
+	}
+	if len(imports) != len(pkg.Package.Imports) {
+		return true
+	}
+	for impPath := range imports {
+		if _, known := pkg.Package.Imports[impPath]; !known {
+			return true
+		}
+	}
+	return false
+}
+
+// describePaths summarizes the given changed paths (and added or removed Go
+// files, if directory listings are given) for logging.
+func describePaths(changed []string, dirs, oldDirs map[string][]string) string {
+	paths := make([]string, 0, len(changed))
+	for _, path := range changed {
+		paths = append(paths, displayPath(path))
+	}
+	for dir, files := range dirs {
+		for _, file := range files {
+			if !slices.Contains(oldDirs[dir], file) {
+				paths = append(paths, displayPath(filepath.Join(dir, file)))
+			}
+		}
+		for _, file := range oldDirs[dir] {
+			if !slices.Contains(files, file) {
+				paths = append(paths, displayPath(filepath.Join(dir, file)))
+			}
+		}
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+	if len(paths) > 3 {
+		return fmt.Sprintf("%s, and %d other files", strings.Join(paths[:3], ", "), len(paths)-3)
+	}
+	return strings.Join(paths, ", ")
+}
+
+// intersects checks if the two sets have any elements in common.
+func intersects(a, b map[string]struct{}) bool {
+	for key := range a {
+		if _, inB := b[key]; inB {
+			return true
+		}
+	}
+	return false
+}
+
+// readTracker records which files each Generator reads through its
+// InputRule.
+type readTracker struct {
+	mu    sync.Mutex
+	reads map[*Generator]map[string]struct{}
+}
+
+// inputFor wraps the given InputRule to track reads by the given Generator.
+func (t *readTracker) inputFor(gen *Generator, rule InputRule) InputRule {
+	return trackedInput{InputRule: rule, gen: gen, tracker: t}
+}
+
+func (t *readTracker) record(gen *Generator, path string) {
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.reads == nil {
+		t.reads = make(map[*Generator]map[string]struct{})
+	}
+	if t.reads[gen] == nil {
+		t.reads[gen] = make(map[string]struct{})
+	}
+	t.reads[gen][path] = struct{}{}
+}
+
+// forget discards the reads recorded for the given Generator.
+func (t *readTracker) forget(gen *Generator) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.reads, gen)
+}
+
+// readAny checks if the given Generator read any of the given paths.
+func (t *readTracker) readAny(gen *Generator, paths []string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, path := range paths {
+		if _, read := t.reads[gen][path]; read {
+			return true
+		}
+	}
+	return false
+}
+
+// allPaths returns every path read by any Generator.
+func (t *readTracker) allPaths() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var paths []string
+	for _, genReads := range t.reads {
+		for path := range genReads {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// trackedInput is an InputRule that records reads with a readTracker.
+type trackedInput struct {
+	InputRule
+	gen     *Generator
+	tracker *readTracker
+}
+
+func (i trackedInput) OpenForRead(path string) (io.ReadCloser, error) {
+	i.tracker.record(i.gen, path)
+	return i.InputRule.OpenForRead(path)
+}
+
+func (i trackedInput) ReadDir(path string) ([]fs.DirEntry, error) {
+	return GenerationContext{InputRule: i.InputRule}.ReadDir(path)
+}
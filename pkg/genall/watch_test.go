@@ -0,0 +1,143 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genall_test
+
+import (
+	"context"
+	"go/ast"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/tools/go/packages"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// countingGenerator counts its runs, type-checking the roots and optionally
+// reading a header file.
+type countingGenerator struct {
+	marker     string
+	headerFile string
+	runs       *atomic.Int32
+}
+
+func (g countingGenerator) RegisterMarkers(into *markers.Registry) error {
+	return into.Register(markers.Must(markers.MakeDefinition(g.marker, markers.DescribesPackage, struct{}{})))
+}
+
+func (countingGenerator) CheckFilter() loader.NodeFilter {
+	return func(ast.Node) bool { return true }
+}
+
+func (g countingGenerator) Generate(ctx *genall.GenerationContext) error {
+	g.runs.Add(1)
+	if g.headerFile != "" {
+		if _, err := ctx.ReadFile(g.headerFile); err != nil {
+			return err
+		}
+	}
+	for _, root := range ctx.Roots {
+		ctx.Checker.Check(root)
+		if _, err := markers.PackageMarkers(ctx.Collector, root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ = Describe("Watcher", func() {
+	var dir string
+	var genA, genB countingGenerator
+	var cancel context.CancelFunc
+	var done chan error
+
+	writeFile := func(path, contents string) {
+		Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, path), []byte(contents), 0o644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		writeFile("go.mod", "module example.com/watched\n\ngo 1.22\n")
+		writeFile("a/a.go", "// +test:a\npackage a\n\nimport _ \"example.com/watched/c\"\n")
+		writeFile("b/b.go", "// +test:b\npackage b\n")
+		writeFile("c/c.go", "package c\n")
+		writeFile("header.txt", "// header\n")
+
+		genA = countingGenerator{marker: "test:a", runs: &atomic.Int32{}}
+		genB = countingGenerator{marker: "test:b", headerFile: filepath.Join(dir, "header.txt"), runs: &atomic.Int32{}}
+		watcher := &genall.Watcher{
+			Load: func() (*genall.Runtime, error) {
+				gens := genall.Generators{new(genall.Generator), new(genall.Generator)}
+				*gens[0], *gens[1] = genA, genB
+				rt, err := gens.ForRootsWithConfig(&packages.Config{Dir: dir}, "./...")
+				if err != nil {
+					return nil, err
+				}
+				rt.ErrorWriter = GinkgoWriter
+				return rt, nil
+			},
+			Interval: 10 * time.Millisecond,
+			Log:      GinkgoWriter,
+		}
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		done = make(chan error)
+		go func() {
+			defer GinkgoRecover()
+			done <- watcher.Watch(ctx)
+		}()
+		Eventually(genA.runs.Load).Should(BeEquivalentTo(1))
+		Eventually(genB.runs.Load).Should(BeEquivalentTo(1))
+	})
+
+	AfterEach(func() {
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	It("should only re-run generators that read a changed file", func() {
+		writeFile("header.txt", "// a different header\n")
+		Eventually(genB.runs.Load).Should(BeEquivalentTo(2))
+		Consistently(genA.runs.Load, 100*time.Millisecond).Should(BeEquivalentTo(1))
+	})
+
+	It("should only re-run generators whose markers are in a changed package", func() {
+		writeFile("a/a.go", "// +test:a\npackage a\n\nimport _ \"example.com/watched/c\"\n\ntype Foo struct{}\n")
+		Eventually(genA.runs.Load).Should(BeEquivalentTo(2))
+		Consistently(genB.runs.Load, 100*time.Millisecond).Should(BeEquivalentTo(1))
+	})
+
+	It("should re-run generators whose markers are in packages that import a changed package", func() {
+		writeFile("c/c.go", "package c\n\ntype Bar struct{}\n")
+		Eventually(genA.runs.Load).Should(BeEquivalentTo(2))
+		Consistently(genB.runs.Load, 100*time.Millisecond).Should(BeEquivalentTo(1))
+	})
+
+	It("should reload and re-run everything when files are added", func() {
+		writeFile("b/more.go", "package b\n")
+		Eventually(genA.runs.Load).Should(BeEquivalentTo(2))
+		Eventually(genB.runs.Load).Should(BeEquivalentTo(2))
+	})
+})
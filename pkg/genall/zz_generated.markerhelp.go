@@ -24,6 +24,38 @@ import (
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
 
+func (Config) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "config file",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "is a declarative description of one or more runs of a set of",
+			Details: "generators, each equivalent to a set of command line options.  It's usually\nloaded from a YAML file like:\n\n\tversion: v1alpha1\n\truns:\n\t- name: api\n\t  paths: [\"./api/...\"]\n\t  generators:\n\t    crd:\n\t      maxDescLen: 0\n\t    object:\n\t      headerFile: hack/boilerplate.go.txt\n\t  output:\n\t    crd:\n\t      artifacts:\n\t        config: config/crd/bases\n\nwhich is equivalent to\n\n\tpaths=./api/... crd:maxDescLen=0 object:headerFile=hack/boilerplate.go.txt output:crd:artifacts:config=config/crd/bases",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"Version": {
+				Summary: "is the version of the config file format, which must be v1alpha1.",
+				Details: "",
+			},
+			"Plugins": {
+				Summary: "are additional generators implemented by external executables,",
+				Details: "usable in any of the runs (see the plugin package).",
+			},
+			"Macros": {
+				Summary: "are marker macros usable in every package in any of the runs,",
+				Details: "each standing for a list of other markers (see markers.Macro).",
+			},
+			"Overlays": {
+				Summary: "are paths to marker overlay files used in every run, as for",
+				Details: "--marker-overlay (see OverlayFile).",
+			},
+			"Runs": {
+				Summary: "are the runs to perform, in order.",
+				Details: "",
+			},
+		},
+	}
+}
+
 func (InputPaths) Help() *markers.DefinitionHelp {
 	return &markers.DefinitionHelp{
 		Category: "",
@@ -35,6 +67,17 @@ func (InputPaths) Help() *markers.DefinitionHelp {
 	}
 }
 
+func (NoLint) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "Warnings",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "suppresses warnings about the package, type, or field it's on.",
+			Details: "Warnings about anything inside a suppressed package or type are suppressed too.\n\nThe code of the warning to suppress is given as part of the marker's name, as in\n`+kubebuilder:nolint:some-code`.  Without a code, as in `+kubebuilder:nolint`,\nall warnings are suppressed.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{},
+	}
+}
+
 func (OutputArtifacts) Help() *markers.DefinitionHelp {
 	return &markers.DefinitionHelp{
 		Category: "",
@@ -66,6 +109,70 @@ func (OutputToDirectory) Help() *markers.DefinitionHelp {
 	}
 }
 
+func (OutputToHelm) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "outputs artifacts into a Helm chart.",
+			Details: "CustomResourceDefinitions are written as-is to the chart's crds\ndirectory.  Other config (like RBAC and webhook configurations) is\nwritten to its templates directory, with Helm placeholders for the\nnamespace, a name prefix, the webhook service's name and namespace, and\ncert-manager's CA injection annotation.  The defaults for the values\nthese use are added to the chart's values.yaml, keeping any existing\nvalues.  Package-associated artifacts (code) are written alongside their\npackages.",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"Chart": {
+				Summary: "is the directory of the Helm chart to write to.",
+				Details: "",
+			},
+		},
+	}
+}
+
+func (PluginConfig) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "config file",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "names a generator plugin executable.",
+			Details: "",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"Name": {
+				Summary: "is the name of the generator, as used in the generators of each run.",
+				Details: "",
+			},
+			"Path": {
+				Summary: "is the path to the plugin executable.",
+				Details: "",
+			},
+		},
+	}
+}
+
+func (RunConfig) Help() *markers.DefinitionHelp {
+	return &markers.DefinitionHelp{
+		Category: "config file",
+		DetailedHelp: markers.DetailedHelp{
+			Summary: "describes a single run of a set of generators.",
+			Details: "",
+		},
+		FieldHelp: map[string]markers.DetailedHelp{
+			"Name": {
+				Summary: "identifies this run in error messages.",
+				Details: "",
+			},
+			"Paths": {
+				Summary: "are the package roots, as for the `paths` option.",
+				Details: "",
+			},
+			"Generators": {
+				Summary: "maps generator names to their arguments, as for the",
+				Details: "`<generator>:<arg>=<value>` options.  A null value runs the generator\nwith no arguments.",
+			},
+			"Output": {
+				Summary: "maps generator names (or \"default\") to a single output rule name",
+				Details: "and its arguments, as for the `output:<generator>:<rule>` (or\n`output:<rule>`) options.",
+			},
+		},
+	}
+}
+
 func (outputToNothing) Help() *markers.DefinitionHelp {
 	return &markers.DefinitionHelp{
 		Category: "",
@@ -0,0 +1,258 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package diff produces line-based unified diffs, as printed by `diff -u`.
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+// maxEditDistance bounds the number of line edits that are searched for in
+// the changed region.  Past this, the whole changed region is reported as
+// replaced, which is still a correct (if not minimal) diff.
+const maxEditDistance = 4096
+
+// opKind is the kind of a single line edit.
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+// edit is a single line of an edit script.
+type edit struct {
+	kind opKind
+	line string
+}
+
+// Unified returns a unified diff turning from into to, labelling the two
+// sides with the given names.  It returns the empty string if the two are
+// identical.
+func Unified(fromName, toName string, from, to []byte) string {
+	if string(from) == string(to) {
+		return ""
+	}
+
+	edits := lineEdits(splitLines(string(from)), splitLines(string(to)))
+
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", fromName, toName)
+	writeHunks(out, edits)
+	return out.String()
+}
+
+// splitLines splits text into lines, keeping the trailing newline on each
+// line so that a missing newline at the end of the text shows up as a change.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineEdits computes an edit script turning from into to.
+func lineEdits(from, to []string) []edit {
+	// trim the common prefix and suffix, which is usually most of the file.
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix && from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]edit, 0, len(from)+len(to))
+	for _, line := range from[:prefix] {
+		edits = append(edits, edit{kind: opEqual, line: line})
+	}
+	edits = append(edits, middleEdits(from[prefix:len(from)-suffix], to[prefix:len(to)-suffix])...)
+	for _, line := range from[len(from)-suffix:] {
+		edits = append(edits, edit{kind: opEqual, line: line})
+	}
+	return edits
+}
+
+// middleEdits computes a minimal edit script for the given lines using
+// Myers' O(ND) algorithm.
+func middleEdits(from, to []string) []edit {
+	n, m := len(from), len(to)
+	offset := n + m + 1
+	// v[k+offset] is the furthest x reached on diagonal k (where y = x - k)
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= n+m && d <= maxEditDistance && !found; d++ {
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // move down (insert)
+			} else {
+				x = v[offset+k-1] + 1 // move right (delete)
+			}
+			y := x - k
+			for x < n && y < m && from[x] == to[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	if !found {
+		edits := make([]edit, 0, n+m)
+		for _, line := range from {
+			edits = append(edits, edit{kind: opDelete, line: line})
+		}
+		for _, line := range to {
+			edits = append(edits, edit{kind: opInsert, line: line})
+		}
+		return edits
+	}
+
+	// walk back through the trace to recover the path, collecting edits in
+	// reverse.
+	var reversed []edit
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d] // v as it was at the start of step d, indexed from -d
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[k-1+d] < prev[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, edit{kind: opEqual, line: from[x]})
+		}
+		if x == prevX {
+			y--
+			reversed = append(reversed, edit{kind: opInsert, line: to[y]})
+		} else {
+			x--
+			reversed = append(reversed, edit{kind: opDelete, line: from[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		reversed = append(reversed, edit{kind: opEqual, line: from[x]})
+	}
+
+	slices.Reverse(reversed)
+	return reversed
+}
+
+// writeHunks writes the given edit script as unified diff hunks, each with
+// up to contextLines lines of context.
+func writeHunks(out *strings.Builder, edits []edit) {
+	for start := 0; start < len(edits); {
+		// find the next change
+		for start < len(edits) && edits[start].kind == opEqual {
+			start++
+		}
+		if start == len(edits) {
+			return
+		}
+
+		// extend the hunk until we see more than twice the context's worth
+		// of unchanged lines (or run out of edits).
+		end := start
+		for end < len(edits) {
+			if edits[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].kind == opEqual {
+				run++
+			}
+			if run == len(edits) || run-end > 2*contextLines {
+				break
+			}
+			end = run
+		}
+
+		hunkStart := max(start-contextLines, 0)
+		hunkEnd := min(end+contextLines, len(edits))
+
+		// figure out the line numbers of the hunk on each side
+		fromLine, toLine := 1, 1
+		for _, e := range edits[:hunkStart] {
+			if e.kind != opInsert {
+				fromLine++
+			}
+			if e.kind != opDelete {
+				toLine++
+			}
+		}
+		fromCount, toCount := 0, 0
+		for _, e := range edits[hunkStart:hunkEnd] {
+			if e.kind != opInsert {
+				fromCount++
+			}
+			if e.kind != opDelete {
+				toCount++
+			}
+		}
+
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(fromLine, fromCount), hunkRange(toLine, toCount))
+		for _, e := range edits[hunkStart:hunkEnd] {
+			out.WriteByte(byte(e.kind))
+			out.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		start = hunkEnd
+	}
+}
+
+// hunkRange formats the line range of one side of a hunk header.
+func hunkRange(line, count int) string {
+	if count == 0 {
+		// empty ranges refer to the line *before* the change
+		return fmt.Sprintf("%d,0", line-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-tools/pkg/internal/diff"
+)
+
+var _ = Describe("Unified", func() {
+	It("should return nothing for identical inputs", func() {
+		Expect(diff.Unified("a", "b", []byte("x\ny\n"), []byte("x\ny\n"))).To(BeEmpty())
+	})
+
+	It("should show changed lines with surrounding context", func() {
+		from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+		to := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n"
+		Expect(diff.Unified("old", "new", []byte(from), []byte(to))).To(Equal(`--- old
++++ new
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`))
+	})
+
+	It("should split far-apart changes into separate hunks", func() {
+		from := "a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n"
+		to := "A\n1\n2\n3\n4\n5\n6\n7\n8\nB\n"
+		Expect(diff.Unified("old", "new", []byte(from), []byte(to))).To(Equal(`--- old
++++ new
+@@ -1,4 +1,4 @@
+-a
++A
+ 1
+ 2
+ 3
+@@ -7,4 +7,4 @@
+ 6
+ 7
+ 8
+-b
++B
+`))
+	})
+
+	It("should handle creating and removing whole files", func() {
+		Expect(diff.Unified("/dev/null", "new", nil, []byte("x\n"))).To(Equal("--- /dev/null\n+++ new\n@@ -0,0 +1 @@\n+x\n"))
+		Expect(diff.Unified("old", "/dev/null", []byte("x\n"), nil)).To(Equal("--- old\n+++ /dev/null\n@@ -1 +0,0 @@\n-x\n"))
+	})
+
+	It("should mark missing trailing newlines", func() {
+		Expect(diff.Unified("old", "new", []byte("x\n"), []byte("x"))).To(Equal("--- old\n+++ new\n@@ -1 +1 @@\n-x\n+x\n\\ No newline at end of file\n"))
+	})
+})
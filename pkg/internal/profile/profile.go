@@ -0,0 +1,81 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package profile records the phases of generation (like loading packages
+// or type-checking them) for profiling, when a Recorder is installed.  It
+// lives apart from genall so that the lower-level packages can mark their
+// phases without depending on genall.
+package profile
+
+import (
+	"sync/atomic"
+)
+
+// The phases of generation.
+const (
+	// Load is loading packages.
+	Load = "load"
+	// TypeCheck is type-checking packages.
+	TypeCheck = "typecheck"
+	// Markers is collecting the markers in packages.
+	Markers = "markers"
+	// Flatten is flattening CRD schemata.
+	Flatten = "flatten"
+	// Gengo is running gengo.
+	Gengo = "gengo"
+	// Generate is running a Generator.
+	Generate = "generate"
+)
+
+// Recorder records spans of time spent in phases of generation.
+type Recorder interface {
+	// StartSpan starts a span of the given phase, returning a function that
+	// ends it.  If generator is non-empty, the span is the run of the
+	// Generator with that name (and phases started while it runs belong to
+	// it).
+	StartSpan(phase, generator string) (end func())
+}
+
+// recorder holds the currently installed Recorder.
+var recorder atomic.Pointer[Recorder]
+
+// SetRecorder installs the given Recorder (or removes the current one, if
+// nil).
+func SetRecorder(r Recorder) {
+	if r == nil {
+		recorder.Store(nil)
+		return
+	}
+	recorder.Store(&r)
+}
+
+// Start starts a span of the given phase, returning a function that ends
+// it.  It does nothing if no Recorder is installed.
+//
+// Use it as `defer profile.Start("phase")()`.
+func Start(phase string) (end func()) {
+	return StartGenerator(phase, "")
+}
+
+// StartGenerator starts a span of the given phase for the Generator with the
+// given name, as per Recorder.StartSpan.
+func StartGenerator(phase, generator string) (end func()) {
+	r := recorder.Load()
+	if r == nil {
+		return func() {}
+	}
+	return (*r).StartSpan(phase, generator)
+}
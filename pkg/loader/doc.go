@@ -33,6 +33,11 @@ limitations under the License.
 // Packages are suitable for comparison, as each unique package only ever has
 // one *Package object returned.
 //
+// Roots may span several modules, including those tied together by a Go
+// workspace (go.work file).  Roots from a workspace's modules are loaded
+// together, and packages shared between roots in different modules are still
+// only ever represented by one *Package.
+//
 // # Syntax and TypeChecking
 //
 // ASTs and type-checking information can be loaded with NeedSyntax and
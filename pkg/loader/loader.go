@@ -26,10 +26,13 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strings"
 	"sync"
 
 	"golang.org/x/tools/go/packages"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-tools/pkg/internal/profile"
 )
 
 // Much of this is strongly inspired by the contents of go/packages,
@@ -42,37 +45,115 @@ import (
 // any errors of the kinds specified in filterKinds.  It will
 // return true if any errors were printed.
 func PrintErrors(pkgs []*Package, filterKinds ...packages.ErrorKind) bool {
-	pkgsRaw := make([]*packages.Package, len(pkgs))
-	for i, pkg := range pkgs {
-		pkgsRaw[i] = pkg.Package
-	}
 	toSkip := make(map[packages.ErrorKind]struct{})
 	for _, errKind := range filterKinds {
 		toSkip[errKind] = struct{}{}
 	}
 	hadErrors := false
-	packages.Visit(pkgsRaw, nil, func(pkgRaw *packages.Package) {
-		for _, err := range pkgRaw.Errors {
-			if _, skip := toSkip[err.Kind]; skip {
-				continue
-			}
-			hadErrors = true
-			fmt.Fprintln(os.Stderr, err)
+	VisitErrors(pkgs, func(_ *packages.Package, err packages.Error, _ error) {
+		if _, skip := toSkip[err.Kind]; skip {
+			return
 		}
+		hadErrors = true
+		fmt.Fprintln(os.Stderr, err)
 	})
 	return hadErrors
 }
 
+// VisitErrors calls the given function for each error associated with the
+// packages in the package graph starting at the given root packages (like
+// PrintErrors, but without filtering), along with the cause of the error as
+// per Package.ErrorCause, if known.
+func VisitErrors(pkgs []*Package, visit func(pkg *packages.Package, err packages.Error, cause error)) {
+	visitPackages(pkgs, func(pkgRaw *packages.Package, pkg *Package) {
+		for i, err := range pkgRaw.Errors {
+			var cause error
+			if pkg != nil {
+				cause = pkg.ErrorCause(i)
+			}
+			visit(pkgRaw, err, cause)
+		}
+	})
+}
+
+// CountErrors returns the number of errors associated with each package in
+// the package graph starting at the given root packages.  Unlike counting
+// them with VisitErrors, it's safe to call while errors are being added
+// (e.g. by generators running in parallel).
+func CountErrors(pkgs []*Package) map[*packages.Package]int {
+	counts := make(map[*packages.Package]int)
+	visitPackages(pkgs, func(pkgRaw *packages.Package, pkg *Package) {
+		if pkg == nil {
+			counts[pkgRaw] = len(pkgRaw.Errors)
+			return
+		}
+		pkg.errorsMu.Lock()
+		counts[pkgRaw] = len(pkgRaw.Errors)
+		pkg.errorsMu.Unlock()
+	})
+	return counts
+}
+
+// visitPackages calls the given function once for each package in the
+// package graph starting at the given root packages, along with the
+// corresponding Package, if known.
+func visitPackages(pkgs []*Package, visit func(pkgRaw *packages.Package, pkg *Package)) {
+	if len(pkgs) == 0 {
+		return
+	}
+	l := pkgs[0].loader
+	pkgsRaw := make([]*packages.Package, len(pkgs))
+	for i, pkg := range pkgs {
+		pkgsRaw[i] = pkg.Package
+	}
+	seen := make(map[*packages.Package]struct{})
+	packages.Visit(pkgsRaw, nil, func(pkgRaw *packages.Package) {
+		var pkg *Package
+		if l != nil {
+			l.packagesMu.Lock()
+			pkg = l.packages[pkgRaw]
+			l.packagesMu.Unlock()
+		}
+		if pkg != nil {
+			// report errors against the package that's actually used, in
+			// case this is a duplicate from loading a different module.
+			pkgRaw = pkg.Package
+		}
+		if _, visited := seen[pkgRaw]; visited {
+			return
+		}
+		seen[pkgRaw] = struct{}{}
+		visit(pkgRaw, pkg)
+	})
+}
+
 // Package is a single, unique Go package that can be
 // lazily parsed and type-checked.  Packages should not
 // be constructed directly -- instead, use LoadRoots.
 // For a given call to LoadRoots, only a single instance
 // of each package exists, and thus they may be used as keys
 // and for comparison.
+//
+// The Need* methods, Imports, and AddError are safe for concurrent use.
 type Package struct {
 	*packages.Package
 
-	imports map[string]*Package
+	imports   map[string]*Package
+	importsMu sync.Mutex
+
+	// syntaxMu guards lazily populating Syntax.
+	syntaxMu sync.Mutex
+	// typesMu guards lazily populating Types, TypesInfo, and IllTyped.
+	typesMu sync.Mutex
+	// errorsMu guards appending to Errors (and errorCauses).
+	errorsMu sync.Mutex
+	// errorCauses holds the error passed to AddError for each entry in Errors
+	// (or nil for errors from loading).  It's only as long as the last entry
+	// with a cause.
+	errorCauses []error
+	// loadErrors are the errors reported when the package was loaded,
+	// restored by ResetErrors.
+	loadErrors []packages.Error
 
 	loader *loader
 	sync.Mutex
@@ -81,6 +162,9 @@ type Package struct {
 // Imports returns the imports for the given package, indexed by
 // package path (*not* name in any particular file).
 func (p *Package) Imports() map[string]*Package {
+	p.importsMu.Lock()
+	defer p.importsMu.Unlock()
+
 	if p.imports == nil {
 		p.imports = p.loader.packagesFor(p.Package.Imports)
 	}
@@ -91,6 +175,9 @@ func (p *Package) Imports() map[string]*Package {
 // NeedTypesInfo indicates that type-checking information is needed for this package.
 // Actual type-checking information can be accessed via the Types and TypesInfo fields.
 func (p *Package) NeedTypesInfo() {
+	p.typesMu.Lock()
+	defer p.typesMu.Unlock()
+
 	if p.TypesInfo != nil {
 		return
 	}
@@ -98,9 +185,44 @@ func (p *Package) NeedTypesInfo() {
 	p.loader.typeCheck(p)
 }
 
+// completeTypes returns the type-checked types for this package, or nil if
+// the package hasn't been (completely) type-checked yet.  It waits for any
+// in-progress type-checking of this package to finish.
+func (p *Package) completeTypes() *types.Package {
+	p.typesMu.Lock()
+	defer p.typesMu.Unlock()
+
+	if p.Types != nil && p.Types.Complete() {
+		return p.Types
+	}
+	return nil
+}
+
+// isIllTyped returns whether this package (or one of its imports) had
+// type-checking errors.
+func (p *Package) isIllTyped() bool {
+	p.typesMu.Lock()
+	defer p.typesMu.Unlock()
+
+	return p.IllTyped
+}
+
+// FileSet returns the file set for positions in this package's syntax,
+// which is shared by all packages loaded together.  Unlike the Fset field,
+// it's available before the package has been type-checked.
+func (p *Package) FileSet() *token.FileSet {
+	if p.loader == nil {
+		return p.Fset
+	}
+	return p.loader.cfg.Fset
+}
+
 // NeedSyntax indicates that a parsed AST is needed for this package.
 // Actual ASTs can be accessed via the Syntax field.
 func (p *Package) NeedSyntax() {
+	p.syntaxMu.Lock()
+	defer p.syntaxMu.Unlock()
+
 	if p.Syntax != nil {
 		return
 	}
@@ -131,8 +253,76 @@ func (p *Package) NeedSyntax() {
 	p.Syntax = out
 }
 
+// Invalidate discards the parsed syntax and type-checking information for
+// this package, along with any errors added since it was loaded, so that
+// they're recomputed from the files on disk the next time they're needed.
+// The set of files in the package and its imports are not reloaded.
+//
+// Since type-checking information refers to the types of imported packages,
+// packages that (transitively) import this one must be invalidated as well.
+// Invalidate must not be called concurrently with other uses of the package.
+func (p *Package) Invalidate() {
+	p.syntaxMu.Lock()
+	p.Syntax = nil
+	p.syntaxMu.Unlock()
+
+	p.typesMu.Lock()
+	p.Types = nil
+	p.TypesInfo = nil
+	p.IllTyped = false
+	p.typesMu.Unlock()
+
+	p.ResetErrors()
+}
+
+// ResetErrors discards any errors added to this package since it was loaded.
+func (p *Package) ResetErrors() {
+	p.errorsMu.Lock()
+	defer p.errorsMu.Unlock()
+
+	p.Errors = slices.Clone(p.loadErrors)
+	p.errorCauses = nil
+}
+
+// ErrorCause returns the error that was passed to AddError to produce the
+// entry at the given index in Errors, or nil if there isn't one (e.g. for
+// errors from loading the package).  This allows recovering details (like
+// the kind of a marker parsing error) that aren't kept in Errors.
+func (p *Package) ErrorCause(index int) error {
+	p.errorsMu.Lock()
+	defer p.errorsMu.Unlock()
+
+	if index < 0 || index >= len(p.errorCauses) {
+		return nil
+	}
+	return p.errorCauses[index]
+}
+
 // AddError adds an error to the errors associated with the given package.
 func (p *Package) AddError(err error) {
+	p.errorsMu.Lock()
+	defer p.errorsMu.Unlock()
+
+	p.addError(err)
+}
+
+// setCause records the cause of all errors from the given index onwards.
+// It must be called with errorsMu held.
+func (p *Package) setCause(from int, cause error) {
+	for len(p.errorCauses) < from {
+		p.errorCauses = append(p.errorCauses, nil)
+	}
+	for i := len(p.errorCauses); i < len(p.Errors); i++ {
+		p.errorCauses = append(p.errorCauses, cause)
+	}
+}
+
+// addError contains the internals of AddError, and must be called with
+// errorsMu held.
+func (p *Package) addError(err error) {
+	from := len(p.Errors)
+	defer p.setCause(from, err)
+
 	switch typedErr := err.(type) {
 	case *os.PathError:
 		// file-reading errors
@@ -159,7 +349,7 @@ func (p *Package) AddError(err error) {
 		})
 	case ErrList:
 		for _, subErr := range typedErr {
-			p.AddError(subErr)
+			p.addError(subErr)
 		}
 	case PositionedError:
 		p.Errors = append(p.Errors, packages.Error{
@@ -191,7 +381,12 @@ type loader struct {
 	// packages contains the cache of Packages indexed by the underlying
 	// package.Package, so that we don't ever produce two Packages with
 	// the same underlying packages.Package.
-	packages   map[*packages.Package]*Package
+	packages map[*packages.Package]*Package
+	// bySource contains the cache of Packages indexed by their ID and source
+	// files, so that packages loaded more than once (by loading roots from
+	// different modules separately) still share a single Package, and thus
+	// the same types.
+	bySource   map[string]*Package
 	packagesMu sync.Mutex
 }
 
@@ -199,13 +394,22 @@ type loader struct {
 // ensuring that there's a one-to-one mapping between the two.
 // It's *not* threadsafe -- use packagesFor for that.
 func (l *loader) packageFor(pkgRaw *packages.Package) *Package {
-	if l.packages[pkgRaw] == nil {
-		l.packages[pkgRaw] = &Package{
-			Package: pkgRaw,
-			loader:  l,
+	if pkg := l.packages[pkgRaw]; pkg != nil {
+		return pkg
+	}
+
+	sourceKey := pkgRaw.ID + "\x00" + strings.Join(pkgRaw.GoFiles, "\x00")
+	pkg := l.bySource[sourceKey]
+	if pkg == nil {
+		pkg = &Package{
+			Package:    pkgRaw,
+			loader:     l,
+			loadErrors: slices.Clone(pkgRaw.Errors),
 		}
+		l.bySource[sourceKey] = pkg
 	}
-	return l.packages[pkgRaw]
+	l.packages[pkgRaw] = pkg
+	return pkg
 }
 
 // packagesFor returns a map of Package objects for each packages.Package in the input
@@ -222,11 +426,14 @@ func (l *loader) packagesFor(pkgsRaw map[string]*packages.Package) map[string]*P
 	return out
 }
 
-// typeCheck type-checks the given package.
+// typeCheck type-checks the given package.  It must be called with the
+// package's typesMu held.
 func (l *loader) typeCheck(pkg *Package) {
 	// don't conflict with typeCheckFromExportData
 
-	pkg.TypesInfo = &types.Info{
+	// NB: everything here is published to the package only once checking is
+	// done, so that nobody observes half-checked type information.
+	typesInfo := &types.Info{
 		Types:      make(map[ast.Expr]types.TypeAndValue),
 		Defs:       make(map[*ast.Ident]types.Object),
 		Uses:       make(map[*ast.Ident]types.Object),
@@ -235,8 +442,7 @@ func (l *loader) typeCheck(pkg *Package) {
 		Selections: make(map[*ast.SelectorExpr]*types.Selection),
 	}
 
-	pkg.Fset = l.cfg.Fset
-	pkg.Types = types.NewPackage(pkg.PkgPath, pkg.Name)
+	typesPkg := types.NewPackage(pkg.PkgPath, pkg.Name)
 
 	importer := importerFunc(func(path string) (*types.Package, error) {
 		if path == "unsafe" {
@@ -254,14 +460,10 @@ func (l *loader) typeCheck(pkg *Package) {
 		// but another doesn't (so one wants a "placeholder" package here, and another
 		// wants the full check).
 		//
-		// Thus, we need to lock here (at least for the time being) to avoid
-		// races between the above write to `pkg.Types` and this checking of
-		// importedPkg.Types.
-		importedPkg.Lock()
-		defer importedPkg.Unlock()
-
-		if importedPkg.Types != nil && importedPkg.Types.Complete() {
-			return importedPkg.Types, nil
+		// Thus, completeTypes locks to avoid races between publishing
+		// importedPkg.Types and this check.
+		if importedTypes := importedPkg.completeTypes(); importedTypes != nil {
+			return importedTypes, nil
 		}
 
 		// if we haven't already loaded typecheck data, we don't care about this package's types
@@ -282,7 +484,7 @@ func (l *loader) typeCheck(pkg *Package) {
 
 		Sizes: pkg.TypesSizes,
 	}
-	if err := types.NewChecker(checkConfig, l.cfg.Fset, pkg.Types, pkg.TypesInfo).Files(pkg.Syntax); err != nil {
+	if err := types.NewChecker(checkConfig, l.cfg.Fset, typesPkg, typesInfo).Files(pkg.Syntax); err != nil {
 		errs = append(errs, err)
 	}
 
@@ -290,15 +492,16 @@ func (l *loader) typeCheck(pkg *Package) {
 	illTyped := len(errs) > 0
 	if !illTyped {
 		for _, importedPkg := range pkg.Imports() {
-			importedPkg.Lock()
-			isIllTyped := importedPkg.IllTyped
-			importedPkg.Unlock()
-			if isIllTyped {
+			if importedPkg.isIllTyped() {
 				illTyped = true
 				break
 			}
 		}
 	}
+
+	pkg.Fset = l.cfg.Fset
+	pkg.Types = typesPkg
+	pkg.TypesInfo = typesInfo
 	pkg.IllTyped = illTyped
 
 	// publish errors to the package error list.
@@ -366,12 +569,25 @@ func LoadRoots(roots ...string) ([]*Package, error) {
 //     descendants include any nested, Go modules. If so, add the directory
 //     that contains the nested Go module to the filesystem path roots.
 //
-//  5. Load the filesystem path roots and return the load packages for the
-//     package/module roots AND the filesystem path roots.
+//  5. Load the filesystem path roots in Go workspaces (go.work files) with a
+//     single call to packages.Load per workspace, so that the roots from all
+//     the workspace's modules are resolved together.
+//
+//  6. Load the rest of the filesystem path roots (with workspace mode off,
+//     if they're not part of the workspace the go command would otherwise
+//     use) and return the load packages for the package/module roots AND the
+//     filesystem path roots.
+//
+// Packages that are loaded more than once (e.g. a dependency shared by roots
+// in different modules) are represented by a single Package, so that their
+// types are shared as well.
 func LoadRootsWithConfig(cfg *packages.Config, roots ...string) ([]*Package, error) {
+	defer profile.Start(profile.Load)()
+
 	l := &loader{
 		cfg:      cfg,
 		packages: make(map[*packages.Package]*Package),
+		bySource: make(map[string]*Package),
 	}
 	l.cfg.Mode |= packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedImports | packages.NeedTypesSizes
 	if l.cfg.Fset == nil {
@@ -482,9 +698,10 @@ func LoadRootsWithConfig(cfg *packages.Config, roots ...string) ([]*Package, err
 	// returning from this function. it should honestly be fine if it is
 	// not given most callers will not send in the cfg parameter directly,
 	// as it's largely for testing, but still, let's be good stewards.
-	defer func(d string) {
+	defer func(d string, env []string) {
 		cfg.Dir = d
-	}(cfg.Dir)
+		cfg.Env = env
+	}(cfg.Dir, cfg.Env)
 
 	// store the value of cfg.Dir so we can use it later if it is non-empty.
 	// we need to store it now as the value of cfg.Dir will be updated by
@@ -560,7 +777,56 @@ func LoadRootsWithConfig(cfg *packages.Config, roots ...string) ([]*Package, err
 		}
 	}
 
-	// in the second pass over the filesystem path roots we:
+	// roots in Go workspaces (go.work files) are loaded together, in a
+	// single call per workspace from the workspace's directory, so that the
+	// go command resolves them (and their dependencies) together.  roots in
+	// modules that aren't part of the workspace that the go command would
+	// otherwise use are loaded with workspace mode off, since it refuses to
+	// load them otherwise.
+	var (
+		wss            = newWorkspaces(l.cfg)
+		workspaceOrder []*workspace
+		workspaceRoots = make(map[*workspace][]string)
+		otherRoots     []string
+		noWorkspace    = make(map[string]bool)
+	)
+	for _, r := range fspRoots {
+		b, d := filepath.Base(r), filepath.Dir(r)
+		if b != "..." {
+			d = r
+		}
+		ws, err := wss.forDir(d)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case ws == nil:
+			otherRoots = append(otherRoots, r)
+		case ws.contains(d):
+			if _, seen := workspaceRoots[ws]; !seen {
+				workspaceOrder = append(workspaceOrder, ws)
+			}
+			workspaceRoots[ws] = append(workspaceRoots[ws], r)
+		case b == "..." && moduleRoot(d) == "":
+			// this is above any module (e.g. the workspace directory
+			// itself), so there's nothing to load except the nested
+			// modules, which were added above.
+		default:
+			otherRoots = append(otherRoots, r)
+			noWorkspace[r] = true
+		}
+	}
+	fspRoots = otherRoots
+	for _, ws := range workspaceOrder {
+		l.cfg.Dir = ws.dir
+		pkgs, err := loadPackages(workspaceRoots[ws]...)
+		if err != nil {
+			return nil, err
+		}
+		l.Roots = append(l.Roots, pkgs...)
+	}
+
+	// in the second pass over the (remaining) filesystem path roots we:
 	//
 	//    1. determine the directory from which to execute the loader
 	//
@@ -588,6 +854,10 @@ func LoadRootsWithConfig(cfg *packages.Config, roots ...string) ([]*Package, err
 		// update the loader configuration's Dir field to the directory part of
 		// the root
 		l.cfg.Dir = d
+		l.cfg.Env = wss.env
+		if noWorkspace[r] {
+			l.cfg.Env = wss.withoutWorkspace()
+		}
 
 		// update the root to be "./..." or "./."
 		// (with OS-specific filepath separator). please note filepath.Join
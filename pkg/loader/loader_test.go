@@ -17,10 +17,12 @@ limitations under the License.
 package loader_test
 
 import (
+	"go/types"
 	"os"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"golang.org/x/tools/go/packages"
 	"sigs.k8s.io/controller-tools/pkg/loader"
 )
 
@@ -188,3 +190,70 @@ var _ = Describe("Loader parsing root module", func() {
 		})
 	})
 })
+
+var _ = Describe("Loader parsing Go workspaces", func() {
+	const workspacePkg = "sigs.k8s.io/controller-tools/pkg/loader/testdata/workspace"
+
+	var cfg *packages.Config
+	BeforeEach(func() {
+		// workspace mode doesn't allow -mod=mod, which might be set in the
+		// environment (e.g. by CI).
+		cfg = &packages.Config{Env: append(os.Environ(), "GOFLAGS=-mod=readonly")}
+	})
+
+	findPkg := func(pkgID string, pkgs []*loader.Package) *loader.Package {
+		for _, pkg := range pkgs {
+			if pkg.ID == pkgID {
+				return pkg
+			}
+		}
+		Fail("package " + pkgID + " was not loaded")
+		return nil
+	}
+
+	Context("with roots=[./testdata/workspace/...]", func() {
+		It("should load the packages from all the modules, without errors", func() {
+			pkgs, err := loader.LoadRootsWithConfig(cfg, "./testdata/workspace/...")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pkgs).To(HaveLen(4))
+			for _, pkg := range pkgs {
+				Expect(pkg.Errors).To(BeEmpty(), "package %s", pkg.ID)
+			}
+			findPkg(workspacePkg+"/api1", pkgs)
+			findPkg(workspacePkg+"/api2", pkgs)
+			findPkg(workspacePkg+"/common", pkgs)
+			findPkg(workspacePkg+"/outside", pkgs)
+		})
+
+		It("should share packages between the modules", func() {
+			pkgs, err := loader.LoadRootsWithConfig(cfg, "./testdata/workspace/...")
+			Expect(err).ToNot(HaveOccurred())
+			common := findPkg(workspacePkg+"/common", pkgs)
+			common.NeedTypesInfo()
+
+			for _, mod := range []string{"api1", "api2", "outside"} {
+				pkg := findPkg(workspacePkg+"/"+mod, pkgs)
+				Expect(pkg.Imports()).To(HaveKeyWithValue(workspacePkg+"/common", BeIdenticalTo(common)), "module %s", mod)
+
+				pkg.NeedTypesInfo()
+				Expect(pkg.Errors).To(BeEmpty(), "module %s", mod)
+				field := pkg.Types.Scope().Lookup("Type").Type().Underlying().(*types.Struct).Field(0)
+				Expect(field.Type()).To(BeIdenticalTo(common.Types.Scope().Lookup("Shared").Type()), "module %s", mod)
+			}
+		})
+	})
+
+	Context("with roots=[./testdata/workspace/api1, ./testdata/workspace/outside]", func() {
+		It("should share packages between the workspace and other modules", func() {
+			pkgs, err := loader.LoadRootsWithConfig(cfg,
+				"./testdata/workspace/api1",
+				"./testdata/workspace/outside")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pkgs).To(HaveLen(2))
+
+			api1 := findPkg(workspacePkg+"/api1", pkgs)
+			outside := findPkg(workspacePkg+"/outside", pkgs)
+			Expect(api1.Imports()[workspacePkg+"/common"]).To(BeIdenticalTo(outside.Imports()[workspacePkg+"/common"]))
+		})
+	})
+})
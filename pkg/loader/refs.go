@@ -21,6 +21,8 @@ import (
 	"go/ast"
 	"strconv"
 	"sync"
+
+	"sigs.k8s.io/controller-tools/pkg/internal/profile"
 )
 
 // NB(directxman12): most of this is done by the typechecker,
@@ -188,6 +190,8 @@ func allReferencedPackages(pkg *Package, filterNodes NodeFilter) []*Package {
 // TypeChecker performs type-checking on a limitted subset of packages by
 // checking each package's types' externally-referenced types, and only
 // type-checking those packages.
+//
+// It's safe for concurrent use.
 type TypeChecker struct {
 	// NodeFilters are used to filter the set of references that are followed
 	// when typechecking.  If any of the filters returns true for a given node,
@@ -206,13 +210,19 @@ type TypeChecker struct {
 // Check type-checks the given package and all packages referenced by types
 // that pass through (have true returned by) any of the NodeFilters.
 func (c *TypeChecker) Check(root *Package) {
+	defer profile.Start(profile.TypeCheck)()
 	c.init()
+	c.check(root)
+}
 
-	// use a sub-checker with the appropriate settings
-	(&TypeChecker{
-		NodeFilters:     c.NodeFilters,
-		checkedPackages: c.checkedPackages,
-	}).check(root)
+// Forget discards the record that the given package has been checked, so
+// that it's checked again by the next call to Check that reaches it (for
+// instance, after the package has been invalidated).
+func (c *TypeChecker) Forget(pkg *Package) {
+	c.init()
+	c.Lock()
+	defer c.Unlock()
+	delete(c.checkedPackages, pkg)
 }
 
 func (c *TypeChecker) isNodeInteresting(node ast.Node) bool {
@@ -231,6 +241,8 @@ func (c *TypeChecker) isNodeInteresting(node ast.Node) bool {
 }
 
 func (c *TypeChecker) init() {
+	c.Lock()
+	defer c.Unlock()
 	if c.checkedPackages == nil {
 		c.checkedPackages = make(map[*Package]struct{})
 	}
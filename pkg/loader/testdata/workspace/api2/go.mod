@@ -0,0 +1,5 @@
+module sigs.k8s.io/controller-tools/pkg/loader/testdata/workspace/api2
+
+go 1.22
+
+require sigs.k8s.io/controller-tools/pkg/loader/testdata/workspace/common v0.0.0-00010101000000-000000000000
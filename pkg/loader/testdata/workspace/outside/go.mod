@@ -0,0 +1,7 @@
+module sigs.k8s.io/controller-tools/pkg/loader/testdata/workspace/outside
+
+go 1.22
+
+replace sigs.k8s.io/controller-tools/pkg/loader/testdata/workspace/common => ../common
+
+require sigs.k8s.io/controller-tools/pkg/loader/testdata/workspace/common v0.0.0-00010101000000-000000000000
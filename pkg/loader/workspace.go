@@ -0,0 +1,165 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/packages"
+)
+
+// workspace describes the Go workspace (go.work file) that packages are
+// being loaded in.
+type workspace struct {
+	// dir is the directory containing the go.work file.
+	dir string
+	// modules are the (absolute) root directories of the modules used by
+	// the workspace.
+	modules map[string]struct{}
+}
+
+// workspaces finds and caches the Go workspaces used for different
+// directories.
+type workspaces struct {
+	// env is the environment the go command is run with.
+	env []string
+	// byPath contains the workspaces found so far, by go.work path.
+	byPath map[string]*workspace
+}
+
+// newWorkspaces returns a workspace finder for the given config.
+func newWorkspaces(cfg *packages.Config) *workspaces {
+	env := cfg.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	return &workspaces{env: env, byPath: make(map[string]*workspace)}
+}
+
+// forDir returns the Go workspace that the go command would use when run in
+// the given (absolute) directory, or nil if it wouldn't use one.
+//
+// Like the go command, it uses the file given by the GOWORK environment
+// variable if it's set, and otherwise looks for a go.work file in the
+// directory and its parents.
+func (w *workspaces) forDir(dir string) (*workspace, error) {
+	workPath := w.getenv("GOWORK")
+	switch {
+	case workPath == "off":
+		return nil, nil
+	case workPath == "":
+		workPath = findUp(dir, "go.work")
+		if workPath == "" {
+			return nil, nil
+		}
+	case !filepath.IsAbs(workPath):
+		return nil, fmt.Errorf("invalid GOWORK: %q is not an absolute path", workPath)
+	}
+
+	if ws, known := w.byPath[workPath]; known {
+		return ws, nil
+	}
+	ws, err := loadWorkspace(workPath)
+	if err != nil {
+		return nil, err
+	}
+	w.byPath[workPath] = ws
+	return ws, nil
+}
+
+// getenv returns the value of the given environment variable, as the go
+// command would see it.
+func (w *workspaces) getenv(key string) string {
+	for i := len(w.env) - 1; i >= 0; i-- {
+		if value, found := strings.CutPrefix(w.env[i], key+"="); found {
+			return value
+		}
+	}
+	return ""
+}
+
+// loadWorkspace loads the workspace described by the given go.work file.
+func loadWorkspace(workPath string) (*workspace, error) {
+	contents, err := os.ReadFile(workPath)
+	if err != nil {
+		return nil, err
+	}
+	workFile, err := modfile.ParseWork(workPath, contents, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &workspace{
+		dir:     filepath.Dir(workPath),
+		modules: make(map[string]struct{}, len(workFile.Use)),
+	}
+	for _, use := range workFile.Use {
+		modDir := filepath.FromSlash(use.Path)
+		if !filepath.IsAbs(modDir) {
+			modDir = filepath.Join(ws.dir, modDir)
+		}
+		ws.modules[filepath.Clean(modDir)] = struct{}{}
+	}
+	return ws, nil
+}
+
+// contains checks if the given (absolute) directory is part of one of the
+// workspace's modules.
+func (w *workspace) contains(dir string) bool {
+	modDir := moduleRoot(dir)
+	if modDir == "" {
+		return false
+	}
+	_, used := w.modules[modDir]
+	return used
+}
+
+// moduleRoot returns the root directory of the module containing the given
+// (absolute) directory, or "" if it's not in a module.
+func moduleRoot(dir string) string {
+	if modPath := findUp(dir, "go.mod"); modPath != "" {
+		return filepath.Dir(modPath)
+	}
+	return ""
+}
+
+// findUp returns the path of the file with the given name in the given
+// (absolute) directory or its closest parent that has one, or "" if none do.
+func findUp(dir, name string) string {
+	for {
+		candidate := filepath.Join(dir, name)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// withoutWorkspace returns the environment the go command is run with, but
+// with workspace mode turned off (for loading modules that aren't part of
+// the workspace that would otherwise be used).
+func (w *workspaces) withoutWorkspace() []string {
+	return append(w.env[:len(w.env):len(w.env)], "GOWORK=off")
+}
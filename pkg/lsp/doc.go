@@ -0,0 +1,32 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lsp implements a language server for controller-tools markers.
+//
+// The server speaks the Language Server Protocol over a pair of streams
+// (usually standard input and output, as with `controller-gen lsp`).  It
+// knows about the markers in a markers.Registry, and works on the marker
+// comments in open Go files, without loading any packages:
+//
+//   - completion offers marker names, the names of their arguments, and
+//     values for boolean arguments
+//   - hover shows the help for a marker (as generated by helpgen), and, for
+//     CRD validation markers, the fragment of the JSON schema it produces
+//   - diagnostics report errors from parsing markers
+//
+// Only full-line `//` comments are considered, since those are the only
+// ones that are collected as markers.
+package lsp
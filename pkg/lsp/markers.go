@@ -0,0 +1,454 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+
+	"sigs.k8s.io/controller-tools/pkg/crd"
+	crdmarkers "sigs.k8s.io/controller-tools/pkg/crd/markers"
+	"sigs.k8s.io/controller-tools/pkg/genall/help"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// diagnosticSource identifies the diagnostics from this server.
+const diagnosticSource = "controller-gen"
+
+// lookupOrder is the order in which targets are tried when looking up the
+// definition of a marker, since we don't know what node it's on.
+var lookupOrder = []markers.TargetType{markers.DescribesField, markers.DescribesType, markers.DescribesFunc, markers.DescribesConst, markers.DescribesPackage}
+
+// schemaTypes are the schema types tried (in order) when working out the
+// schema fragment produced by a marker, since markers often only apply to
+// some types.
+var schemaTypes = []string{"", "string", "integer", "number", "boolean", "array", "object"}
+
+// splitLines splits the given text into lines, without line endings.
+func splitLines(text string) []string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// markerStart returns the byte offset of the start of the marker (its
+// leading `+`) in the given line, if the line is a marker comment.
+func markerStart(line string) (int, bool) {
+	comment := strings.TrimLeft(line, " \t")
+	if !strings.HasPrefix(comment, "//") {
+		return 0, false
+	}
+	marker := strings.TrimLeft(comment[2:], " \t")
+	if !strings.HasPrefix(marker, "+") {
+		return 0, false
+	}
+	return len(line) - len(marker), true
+}
+
+// continuedMarker returns the marker starting at the given byte offset of
+// the given line, joined with the lines that it's continued onto (see
+// markers.JoinMarkerLines), along with the number of the last line that it
+// spans.
+func continuedMarker(lines []string, lineNum, start int) (string, int) {
+	markerLines := []string{lines[lineNum][start:]}
+	for _, line := range lines[lineNum+1:] {
+		comment, isComment := strings.CutPrefix(strings.TrimLeft(line, " \t"), "//")
+		if !isComment {
+			break
+		}
+		markerLines = append(markerLines, comment)
+	}
+	marker, spans := markers.JoinMarkerLines(markerLines)
+	return marker, lineNum + spans - 1
+}
+
+// byteOffset converts an offset in UTF-16 code units in the given line to
+// an offset in bytes.
+func byteOffset(line string, character int) int {
+	units := 0
+	for i, r := range line {
+		if units >= character {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len(line)
+}
+
+// utf16Offset converts an offset in bytes in the given line to an offset in
+// UTF-16 code units.
+func utf16Offset(line string, offset int) int {
+	units := 0
+	for _, r := range line[:offset] {
+		units += utf16.RuneLen(r)
+	}
+	return units
+}
+
+// rangeOnLine returns the range between the given byte offsets on the given
+// line.
+func rangeOnLine(lineNum int, line string, start, end int) textRange {
+	return textRange{
+		Start: position{Line: lineNum, Character: utf16Offset(line, start)},
+		End:   position{Line: lineNum, Character: utf16Offset(line, end)},
+	}
+}
+
+// lookup finds the definition for the given marker (including the leading
+// `+`), trying each target in turn.
+func (s *Server) lookup(marker string) *markers.Definition {
+	for _, target := range lookupOrder {
+		if def := s.Registry.Lookup(marker, target); def != nil {
+			return def
+		}
+	}
+	return nil
+}
+
+// definitionsByName returns the known definitions grouped by name, sorted by
+// name.
+func (s *Server) definitionsByName() [][]*markers.Definition {
+	byName := make(map[string][]*markers.Definition)
+	for _, def := range s.Registry.AllDefinitions() {
+		byName[def.Name] = append(byName[def.Name], def)
+	}
+	res := make([][]*markers.Definition, 0, len(byName))
+	for _, defs := range byName {
+		slices.SortFunc(defs, func(a, b *markers.Definition) int { return int(a.Target) - int(b.Target) })
+		res = append(res, defs)
+	}
+	slices.SortFunc(res, func(a, b []*markers.Definition) int { return strings.Compare(a[0].Name, b[0].Name) })
+	return res
+}
+
+// complete returns the completions at the given position on the given line.
+func (s *Server) complete(line string, pos position) []completionItem {
+	start, isMarker := markerStart(line)
+	cursor := byteOffset(line, pos.Character)
+	if !isMarker || cursor <= start {
+		return nil
+	}
+	typed := line[start+1 : cursor]
+
+	var items []completionItem
+	if !strings.Contains(typed, "=") {
+		for _, defs := range s.definitionsByName() {
+			def := defs[0]
+			switch {
+			case strings.HasPrefix(def.Name, typed):
+				item := s.markerItem(defs)
+				item.TextEdit = &textEdit{Range: rangeOnLine(pos.Line, line, start+1, cursor), NewText: def.Name}
+				items = append(items, item)
+			case isStruct(def) && strings.HasPrefix(typed, def.Name+":"):
+				// the first argument of a struct marker follows its name
+				argStart := start + 1 + len(def.Name) + 1
+				items = append(items, s.argumentItems(def, pos.Line, line, argStart, cursor, nil)...)
+			}
+		}
+		return items
+	}
+
+	def := s.lookup("+" + typed)
+	if def == nil || def.Empty() {
+		return nil
+	}
+	nameEnd := start + 1 + strings.Index(typed, "=")
+	if def.AnonymousField() {
+		return valueItems(def.Fields[""], pos.Line, line, nameEnd+1, cursor)
+	}
+
+	// work out which argument we're in, and whether we're in its name or
+	// its value.
+	if len(typed) <= len(def.Name) {
+		return nil
+	}
+	args := strings.Split(line[start+1+len(def.Name)+1:cursor], ",")
+	argStart := cursor - len(args[len(args)-1])
+	argName, _, inValue := strings.Cut(args[len(args)-1], "=")
+	if inValue {
+		return valueItems(def.Fields[argName], pos.Line, line, argStart+len(argName)+1, cursor)
+	}
+	used := make(map[string]bool, len(args))
+	for _, arg := range args[:len(args)-1] {
+		name, _, _ := strings.Cut(arg, "=")
+		used[name] = true
+	}
+	return s.argumentItems(def, pos.Line, line, argStart, cursor, used)
+}
+
+// isStruct checks if the given definition has named arguments.
+func isStruct(def *markers.Definition) bool {
+	return !def.Empty() && !def.AnonymousField()
+}
+
+// markerItem returns the completion for the marker with the given
+// definitions (one per target).
+func (s *Server) markerItem(defs []*markers.Definition) completionItem {
+	doc := help.ForDefinition(defs[0], s.Registry.HelpFor(defs[0]))
+	item := completionItem{
+		Label:  defs[0].Name,
+		Kind:   completionKindKeyword,
+		Detail: markerSignature(doc),
+	}
+	if text := helpText(doc.DetailedHelp); text != "" {
+		item.Documentation = markdown(text)
+	}
+	if doc.DeprecatedInFavorOf != nil {
+		item.Tags = []int{completionTagDeprecated}
+	}
+	return item
+}
+
+// argumentItems returns the completions for the arguments of the given
+// definition (except those already used), replacing the given range.
+func (s *Server) argumentItems(def *markers.Definition, lineNum int, line string, start, end int, used map[string]bool) []completionItem {
+	typed := line[start:end]
+	doc := help.ForDefinition(def, s.Registry.HelpFor(def))
+	var items []completionItem
+	for _, field := range doc.Fields {
+		if used[field.Name] || !strings.HasPrefix(field.Name, typed) {
+			continue
+		}
+		item := completionItem{
+			Label:    field.Name,
+			Kind:     completionKindField,
+			Detail:   field.TypeString(),
+			TextEdit: &textEdit{Range: rangeOnLine(lineNum, line, start, end), NewText: field.Name + "="},
+		}
+		if field.Optional {
+			item.Detail += " (optional)"
+		}
+		if text := helpText(field.DetailedHelp); text != "" {
+			item.Documentation = markdown(text)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// valueItems returns the completions for the value of an argument of the
+// given type, replacing the given range.  Only booleans have a known set of
+// values.
+func valueItems(arg markers.Argument, lineNum int, line string, start, end int) []completionItem {
+	if arg.Type != markers.BoolType {
+		return nil
+	}
+	var items []completionItem
+	for _, value := range []string{"true", "false"} {
+		if !strings.HasPrefix(value, line[start:end]) {
+			continue
+		}
+		items = append(items, completionItem{
+			Label:    value,
+			Kind:     completionKindValue,
+			TextEdit: &textEdit{Range: rangeOnLine(lineNum, line, start, end), NewText: value},
+		})
+	}
+	return items
+}
+
+// hover returns the help for the marker at the given position on the given
+// line, if any.
+func (s *Server) hover(line string, pos position) *hover {
+	start, isMarker := markerStart(line)
+	cursor := byteOffset(line, pos.Character)
+	marker := strings.TrimRight(line[start:], " \t")
+	if !isMarker || cursor < start || cursor >= start+len(marker) {
+		return nil
+	}
+	def := s.lookup(marker)
+	if def == nil {
+		return nil
+	}
+
+	doc := help.ForDefinition(def, s.Registry.HelpFor(def))
+	var out strings.Builder
+	fmt.Fprintf(&out, "```\n%s\n```\n\n", markerSignature(doc))
+
+	var targets []string
+	for _, target := range lookupOrder {
+		if s.Registry.Lookup(marker, target) != nil {
+			targets = append(targets, target.String()+"s")
+		}
+	}
+	if doc.Category != "" {
+		fmt.Fprintf(&out, "**%s** ", doc.Category)
+	}
+	fmt.Fprintf(&out, "(on %s)\n\n", strings.Join(targets, " and "))
+
+	if doc.DeprecatedInFavorOf != nil {
+		if *doc.DeprecatedInFavorOf != "" {
+			fmt.Fprintf(&out, "**Deprecated**: use `+%s` instead.\n\n", *doc.DeprecatedInFavorOf)
+		} else {
+			out.WriteString("**Deprecated**.\n\n")
+		}
+	}
+	if text := helpText(doc.DetailedHelp); text != "" {
+		fmt.Fprintf(&out, "%s\n\n", text)
+	}
+
+	if isStruct(def) {
+		out.WriteString("**Arguments**\n\n")
+		for _, field := range doc.Fields {
+			fmt.Fprintf(&out, "- `%s` (`%s`", field.Name, field.TypeString())
+			if field.Optional {
+				out.WriteString(", optional")
+			}
+			out.WriteString(")")
+			if field.Summary != "" {
+				fmt.Fprintf(&out, ": %s", field.Summary)
+			}
+			out.WriteString("\n")
+		}
+		out.WriteString("\n")
+	}
+
+	if fragment := schemaFragment(def, marker); fragment != "" {
+		fmt.Fprintf(&out, "**Schema**\n\n```yaml\n%s```\n", fragment)
+	}
+
+	return &hover{
+		Contents: markdown(strings.TrimSpace(out.String())),
+		Range:    rangeOnLine(pos.Line, line, start, start+len(marker)),
+	}
+}
+
+// markerSignature returns the syntax of a marker, as in
+// `+name:arg=<type>[,optionalArg=<type>]`.
+func markerSignature(doc help.MarkerDoc) string {
+	var out strings.Builder
+	out.WriteString("+" + doc.Name)
+	if doc.AnonymousField() {
+		fmt.Fprintf(&out, "=<%s>", doc.Fields[0].TypeString())
+		return out.String()
+	}
+	sep := ":"
+	for _, field := range doc.Fields {
+		if field.Optional {
+			fmt.Fprintf(&out, "[%s%s=<%s>]", sep, field.Name, field.TypeString())
+		} else {
+			fmt.Fprintf(&out, "%s%s=<%s>", sep, field.Name, field.TypeString())
+		}
+		sep = ","
+	}
+	return out.String()
+}
+
+// helpText joins the summary and details of some help.
+func helpText(h help.DetailedHelp) string {
+	if h.Details == "" {
+		return h.Summary
+	}
+	return h.Summary + "\n\n" + h.Details
+}
+
+// schemaFragment returns the part of a CRD schema produced by the given
+// marker as YAML, if it's a schema marker.  Since most markers only apply
+// to some types of schema, it's the fragment for the first type (if any)
+// that the marker applies to, without that type.
+func schemaFragment(def *markers.Definition, marker string) string {
+	val, err := def.Parse(marker)
+	if err != nil {
+		return ""
+	}
+	schemaMarker, isSchemaMarker := val.(crd.SchemaMarker)
+	if !isSchemaMarker {
+		return ""
+	}
+	for _, schemaType := range schemaTypes {
+		props := &apiextensionsv1.JSONSchemaProps{Type: schemaType}
+		if err := schemaMarker.ApplyToSchema(&crdmarkers.SchemaContext{}, props); err != nil {
+			continue
+		}
+		if props.Type == schemaType {
+			props.Type = ""
+		}
+		if strings.HasPrefix(def.Name, crdmarkers.ValidationItemsPrefix) {
+			props = &apiextensionsv1.JSONSchemaProps{Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: props}}
+		}
+		out, err := yaml.Marshal(props)
+		if err != nil || string(out) == "{}\n" {
+			return ""
+		}
+		return string(out)
+	}
+	return ""
+}
+
+// diagnostics returns the problems with parsing the markers in the given
+// document.
+func (s *Server) diagnostics(text string) []diagnostic {
+	res := []diagnostic{}
+	lines := splitLines(text)
+	for lineNum := 0; lineNum < len(lines); lineNum++ {
+		line := lines[lineNum]
+		start, isMarker := markerStart(line)
+		if !isMarker {
+			continue
+		}
+		marker, endLineNum := continuedMarker(lines, lineNum, start)
+		markerRange := rangeOnLine(lineNum, line, start, start+len(strings.TrimRight(line[start:], " \t")))
+		if endLineNum > lineNum {
+			endLine := strings.TrimRight(lines[endLineNum], " \t")
+			markerRange.End = position{Line: endLineNum, Character: utf16Offset(endLine, len(endLine))}
+			lineNum = endLineNum
+		}
+		def := s.lookup(marker)
+		if def == nil {
+			continue
+		}
+		_, err := def.Parse(marker)
+		if err == nil {
+			continue
+		}
+		errs := []error{err}
+		if errList, isList := err.(loader.ErrList); isList {
+			errs = errList
+		}
+		for _, err := range errs {
+			errRange, msg := markerRange, err.Error()
+			// the editor points at the problem itself, so there's no need
+			// for the excerpt -- just narrow the range to match it.
+			var scanErr *markers.ScannerError
+			if errors.As(err, &scanErr) && scanErr.Marker != "" {
+				msg = scanErr.Msg
+				if markerRange.Start.Line == markerRange.End.Line {
+					errStart := min(start+scanErr.Offset, len(line))
+					errEnd := min(start+max(scanErr.EndOffset, scanErr.Offset+1), len(line))
+					errRange = rangeOnLine(lineNum, line, errStart, errEnd)
+				}
+			}
+			res = append(res, diagnostic{
+				Range:    errRange,
+				Severity: severityError,
+				Code:     def.Name,
+				Source:   diagnosticSource,
+				Message:  msg,
+			})
+		}
+	}
+	return res
+}
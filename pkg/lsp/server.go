@@ -0,0 +1,218 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// Server is a language server for markers.  It handles a single client,
+// one message at a time.
+type Server struct {
+	// Registry contains the markers known to the server, along with their
+	// help.
+	Registry *markers.Registry
+	// Version is the version of the server reported to the client.
+	Version string
+
+	// docs are the text of the open documents, by URI.
+	docs map[string]string
+	out  io.Writer
+	// shutdown records that the client asked the server to shut down.
+	shutdown bool
+}
+
+// Serve reads messages from the given reader and writes responses (and
+// notifications) to the given writer, until the client tells the server
+// to exit, or the reader is closed.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	if s.Registry == nil {
+		s.Registry = &markers.Registry{}
+	}
+	s.docs = make(map[string]string)
+	s.out = out
+
+	reader := bufio.NewReader(in)
+	for {
+		msg, err := readMessage(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			var respErr *responseError
+			if errors.As(err, &respErr) {
+				if err := s.reply(nil, nil, respErr); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("language client exited without shutting down the server first")
+			}
+			return nil
+		}
+		var result any
+		if s.shutdown {
+			err = &responseError{Code: codeInvalidRequest, Message: "the server has been shut down"}
+		} else {
+			result, err = s.handle(msg)
+		}
+		var respErr *responseError
+		if err != nil && !errors.As(err, &respErr) {
+			// anything other than a problem with the message means that we
+			// couldn't write to the client.
+			return err
+		}
+		if msg.ID == nil {
+			// notifications don't get responses, even if they fail
+			continue
+		}
+		if err := s.reply(msg.ID, result, respErr); err != nil {
+			return err
+		}
+	}
+}
+
+// handle handles a single request or notification, returning the result.
+func (s *Server) handle(msg *message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync:   textDocumentSyncOptions{OpenClose: true, Change: textDocumentSyncFull},
+				CompletionProvider: completionOptions{TriggerCharacters: []string{"+", ":", "=", ","}},
+				HoverProvider:      true,
+			},
+			ServerInfo: serverInfo{Name: "controller-gen", Version: s.Version},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		s.docs[params.TextDocument.URI] = params.TextDocument.Text
+		return nil, s.publishDiagnostics(params.TextDocument.URI)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// we ask for full syncs, so the last change has the whole text
+		s.docs[params.TextDocument.URI] = params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.publishDiagnostics(params.TextDocument.URI)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		items := s.complete(s.lineAt(params), params.Position)
+		return completionList{Items: items}, nil
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if res := s.hover(s.lineAt(params), params.Position); res != nil {
+			return res, nil
+		}
+		return nil, nil
+	default:
+		if msg.ID != nil && !strings.HasPrefix(msg.Method, "$/") {
+			return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not supported", msg.Method)}
+		}
+		return nil, nil
+	}
+}
+
+// decodeParams decodes the parameters of the given message.
+func decodeParams(msg *message, params any) error {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid parameters for %s: %v", msg.Method, err)}
+	}
+	return nil
+}
+
+// lineAt returns the line of the document at the given position, or an
+// empty string if the document isn't open.
+func (s *Server) lineAt(params textDocumentPositionParams) string {
+	lines := splitLines(s.docs[params.TextDocument.URI])
+	if params.Position.Line < 0 || params.Position.Line >= len(lines) {
+		return ""
+	}
+	return lines[params.Position.Line]
+}
+
+// publishDiagnostics sends the diagnostics for the given document.
+func (s *Server) publishDiagnostics(uri string) error {
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: s.diagnostics(s.docs[uri]),
+	})
+}
+
+// reply sends a response to the request with the given ID.
+func (s *Server) reply(id *json.RawMessage, result any, respErr *responseError) error {
+	msg := &message{ID: id, Error: respErr}
+	if id == nil {
+		// errors for messages we couldn't read at all have a null ID
+		nullID := json.RawMessage("null")
+		msg.ID = &nullID
+	}
+	if respErr == nil {
+		var err error
+		if msg.Result, err = json.Marshal(result); err != nil {
+			return err
+		}
+	}
+	return writeMessage(s.out, msg)
+}
+
+// notify sends a notification to the client.
+func (s *Server) notify(method string, params any) error {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &message{Method: method, Params: rawParams})
+}
@@ -0,0 +1,275 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lsp_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	crdmarkers "sigs.k8s.io/controller-tools/pkg/crd/markers"
+	"sigs.k8s.io/controller-tools/pkg/lsp"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// testClient talks to a Server over a pair of pipes.
+type testClient struct {
+	in     *io.PipeWriter
+	out    *bufio.Reader
+	nextID int
+	// notifications are the notifications received from the server, in order.
+	notifications []testMessage
+}
+
+type testMessage struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code int `json:"code"`
+	} `json:"error"`
+}
+
+func (c *testClient) send(msg map[string]any) {
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	Expect(err).NotTo(HaveOccurred())
+	_, err = fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func (c *testClient) receive() testMessage {
+	header, err := textproto.NewReader(c.out).ReadMIMEHeader()
+	Expect(err).NotTo(HaveOccurred())
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	Expect(err).NotTo(HaveOccurred())
+	body := make([]byte, length)
+	_, err = io.ReadFull(c.out, body)
+	Expect(err).NotTo(HaveOccurred())
+	var msg testMessage
+	Expect(json.Unmarshal(body, &msg)).To(Succeed())
+	return msg
+}
+
+// call sends a request, returning the response (recording any notifications
+// received in the meantime).
+func (c *testClient) call(method string, params any) testMessage {
+	c.nextID++
+	c.send(map[string]any{"id": c.nextID, "method": method, "params": params})
+	for {
+		msg := c.receive()
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		Expect(*msg.ID).To(Equal(c.nextID))
+		return msg
+	}
+}
+
+// notify sends a notification, waiting for the given number of
+// notifications in response.
+func (c *testClient) notify(method string, params any, responses int) {
+	c.send(map[string]any{"method": method, "params": params})
+	for range responses {
+		c.notifications = append(c.notifications, c.receive())
+	}
+}
+
+const testURI = "file:///src/api/v1/types.go"
+
+const testDoc = `package v1
+
+type FooSpec struct {
+	// +kubebuilder:validation:MaxLength=abc
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Max
+	// +kubebuilder:printcolumn:name="Foo",
+	// +kubebuilder:validation:ExclusiveMaximum=
+	Foo int
+}
+`
+
+var _ = Describe("Server", func() {
+	var client *testClient
+	var served chan error
+
+	BeforeEach(func() {
+		reg := &markers.Registry{}
+		Expect(crdmarkers.Register(reg)).To(Succeed())
+		server := &lsp.Server{Registry: reg, Version: "v1.2.3"}
+
+		clientIn, serverIn := io.Pipe()
+		serverOut, clientOut := io.Pipe()
+		served = make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			served <- server.Serve(clientIn, clientOut)
+			clientOut.Close()
+		}()
+		client = &testClient{in: serverIn, out: bufio.NewReader(serverOut)}
+
+		resp := client.call("initialize", map[string]any{"capabilities": map[string]any{}})
+		Expect(resp.Error).To(BeNil())
+		Expect(string(resp.Result)).To(ContainSubstring(`"hoverProvider":true`))
+		Expect(string(resp.Result)).To(ContainSubstring(`"version":"v1.2.3"`))
+		client.notify("initialized", map[string]any{}, 0)
+		client.notify("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": testURI, "languageId": "go", "version": 1, "text": testDoc},
+		}, 1)
+	})
+
+	AfterEach(func() {
+		Expect(client.call("shutdown", nil).Error).To(BeNil())
+		client.send(map[string]any{"method": "exit"})
+		Eventually(served).Should(Receive(BeNil()))
+	})
+
+	position := func(line, character int) map[string]any {
+		return map[string]any{
+			"textDocument": map[string]any{"uri": testURI},
+			"position":     map[string]any{"line": line, "character": character},
+		}
+	}
+
+	completionLabels := func(resp testMessage) []string {
+		var list struct {
+			Items []struct {
+				Label string `json:"label"`
+			} `json:"items"`
+		}
+		Expect(json.Unmarshal(resp.Result, &list)).To(Succeed())
+		var labels []string
+		for _, item := range list.Items {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+
+	It("should report marker parse errors as diagnostics", func() {
+		Expect(client.notifications).To(HaveLen(1))
+		Expect(client.notifications[0].Method).To(Equal("textDocument/publishDiagnostics"))
+		var params struct {
+			URI         string `json:"uri"`
+			Diagnostics []struct {
+				Range struct {
+					Start, End struct{ Line, Character int }
+				} `json:"range"`
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"diagnostics"`
+		}
+		Expect(json.Unmarshal(client.notifications[0].Params, &params)).To(Succeed())
+		Expect(params.URI).To(Equal(testURI))
+		// the half-written markers further down are reported too
+		Expect(params.Diagnostics).NotTo(BeEmpty())
+		By("pointing at the exact part of the marker that's wrong")
+		Expect(params.Diagnostics[0].Range.Start.Line).To(Equal(3))
+		Expect(params.Diagnostics[0].Range.Start.Character).To(Equal(len("\t// +kubebuilder:validation:MaxLength=")))
+		Expect(params.Diagnostics[0].Range.End.Character).To(Equal(len("\t// +kubebuilder:validation:MaxLength=abc")))
+		Expect(params.Diagnostics[0].Code).To(Equal("kubebuilder:validation:MaxLength"))
+		Expect(params.Diagnostics[0].Message).NotTo(ContainSubstring("\n"))
+
+		By("clearing them once fixed")
+		client.notify("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": testURI, "version": 2},
+			"contentChanges": []map[string]any{{"text": "package v1\n"}},
+		}, 1)
+		Expect(json.Unmarshal(client.notifications[1].Params, &params)).To(Succeed())
+		Expect(params.Diagnostics).To(BeEmpty())
+	})
+
+	It("should join markers continued over several lines before reporting errors", func() {
+		client.notify("textDocument/didChange", map[string]any{
+			"textDocument": map[string]any{"uri": testURI, "version": 2},
+			"contentChanges": []map[string]any{{"text": "package v1\n\ntype Foo struct {\n" +
+				"\t// +kubebuilder:validation:XValidation:rule=\"self.a > \\\n\t//   self.b\",message=\"bad\"\n" +
+				"\t// +kubebuilder:validation:MaxLength=\\\n\t//   foo\n" +
+				"\tA string\n}\n"}},
+		}, 1)
+		var params struct {
+			Diagnostics []struct {
+				Range struct {
+					Start struct{ Line, Character int }
+					End   struct{ Line, Character int }
+				} `json:"range"`
+				Code string `json:"code"`
+			} `json:"diagnostics"`
+		}
+		Expect(json.Unmarshal(client.notifications[1].Params, &params)).To(Succeed())
+		Expect(params.Diagnostics).NotTo(BeEmpty())
+		for _, diag := range params.Diagnostics {
+			Expect(diag.Code).To(Equal("kubebuilder:validation:MaxLength"))
+			Expect(diag.Range.Start.Line).To(Equal(5))
+			Expect(diag.Range.Start.Character).To(Equal(4))
+			Expect(diag.Range.End.Line).To(Equal(6))
+			Expect(diag.Range.End.Character).To(Equal(len("\t//   foo")))
+		}
+	})
+
+	It("should complete marker names", func() {
+		resp := client.call("textDocument/completion", position(5, len("\t// +kubebuilder:validation:Max")))
+		Expect(completionLabels(resp)).To(ContainElements(
+			"kubebuilder:validation:MaxItems", "kubebuilder:validation:MaxLength", "kubebuilder:validation:Maximum"))
+		Expect(completionLabels(resp)).NotTo(ContainElement("kubebuilder:validation:Minimum"))
+	})
+
+	It("should complete argument names that haven't been used yet", func() {
+		resp := client.call("textDocument/completion", position(6, len(`	// +kubebuilder:printcolumn:name="Foo",`)))
+		labels := completionLabels(resp)
+		Expect(labels).To(ContainElements("type", "JSONPath"))
+		Expect(labels).NotTo(ContainElement("name"))
+	})
+
+	It("should complete boolean values", func() {
+		resp := client.call("textDocument/completion", position(7, len("\t// +kubebuilder:validation:ExclusiveMaximum=")))
+		Expect(completionLabels(resp)).To(Equal([]string{"true", "false"}))
+	})
+
+	It("should show help and the schema produced by a marker on hover", func() {
+		resp := client.call("textDocument/hover", position(4, 10))
+		var result struct {
+			Contents struct {
+				Kind  string `json:"kind"`
+				Value string `json:"value"`
+			} `json:"contents"`
+		}
+		Expect(json.Unmarshal(resp.Result, &result)).To(Succeed())
+		Expect(result.Contents.Kind).To(Equal("markdown"))
+		Expect(result.Contents.Value).To(HavePrefix("```\n+kubebuilder:validation:Minimum=<"))
+		Expect(result.Contents.Value).To(ContainSubstring("**CRD validation**"))
+		Expect(result.Contents.Value).To(ContainSubstring("```yaml\nminimum: 1\n```"))
+	})
+
+	It("should show nothing when not hovering over a marker", func() {
+		resp := client.call("textDocument/hover", position(8, 2))
+		Expect(string(resp.Result)).To(Equal("null"))
+	})
+
+	It("should reject unknown requests", func() {
+		resp := client.call("textDocument/definition", position(4, 10))
+		Expect(resp.Error).NotTo(BeNil())
+		Expect(resp.Error.Code).To(Equal(-32601))
+	})
+})
@@ -17,24 +17,46 @@ limitations under the License.
 package markers
 
 import (
+	"cmp"
 	"go/ast"
 	"go/token"
+	"slices"
 	"strings"
 	"sync"
+	"unicode"
 
+	"sigs.k8s.io/controller-tools/pkg/internal/profile"
 	"sigs.k8s.io/controller-tools/pkg/loader"
 )
 
 // Collector collects and parses marker comments defined in the registry
 // from package source code.  If no registry is provided, an empty one will
 // be initialized on the first call to MarkersInPackage.
+//
+// It's safe for concurrent use, so long as no new definitions are registered
+// while markers are being collected.
 type Collector struct {
 	*Registry
 
-	byPackage map[*loader.Package]map[ast.Node]MarkerValues
+	// Macros are macros that can be used in any package, in addition to
+	// the ones defined in each package (see Macro).
+	Macros []Macro
+	// Overlays attach markers to types and fields in addition to the ones
+	// written in the source (see Overlay).
+	Overlays []Overlay
+
+	byPackage map[*loader.Package]*packageMarkers
 	mu        sync.Mutex
 }
 
+// packageMarkers holds the (possibly in-progress) result of collecting
+// markers for a single package.
+type packageMarkers struct {
+	once    sync.Once
+	markers map[ast.Node]MarkerValues
+	err     error
+}
+
 // MarkerValues are all the values for some set of markers.
 type MarkerValues map[string][]any
 
@@ -53,7 +75,7 @@ func (c *Collector) init() {
 		c.Registry = &Registry{}
 	}
 	if c.byPackage == nil {
-		c.byPackage = make(map[*loader.Package]map[ast.Node]MarkerValues)
+		c.byPackage = make(map[*loader.Package]*packageMarkers)
 	}
 }
 
@@ -70,65 +92,210 @@ func (c *Collector) init() {
 //     registered as type-level *or* it's not registered as being
 //     package-level]
 //
+//   - it's in the closest non-godoc comment group above that node,
+//     *and* that node is a func or single-line const declaration, *and*
+//     it's registered as func- or const-level
+//
 //   - it's not in the Godoc of a node, doesn't meet the above criteria, and
 //     isn't in a struct definition (in which case it's package-level)
 func (c *Collector) MarkersInPackage(pkg *loader.Package) (map[ast.Node]MarkerValues, error) {
 	c.mu.Lock()
 	c.init()
-	if markers, exist := c.byPackage[pkg]; exist {
-		c.mu.Unlock()
-		return markers, nil
+	res, exist := c.byPackage[pkg]
+	if !exist {
+		res = &packageMarkers{}
+		c.byPackage[pkg] = res
 	}
-	// unlock early, it's ok if we do a bit extra work rather than locking while we're working
+	// unlock early so that different packages can be collected in parallel --
+	// concurrent callers for the same package wait on the same result below.
 	c.mu.Unlock()
 
-	pkg.NeedSyntax()
-	nodeMarkersRaw := c.associatePkgMarkers(pkg)
-	markers, err := c.parseMarkersInPackage(nodeMarkersRaw)
-	if err != nil {
-		return nil, err
+	res.once.Do(func() {
+		defer profile.Start(profile.Markers)()
+		pkg.NeedSyntax()
+		nodeMarkersRaw := c.associatePkgMarkers(pkg)
+		res.markers, res.err = c.parseMarkersInPackage(pkg, nodeMarkersRaw)
+	})
+	if res.err != nil {
+		// don't cache errors -- each caller should get a chance to report them
+		c.mu.Lock()
+		if c.byPackage[pkg] == res {
+			delete(c.byPackage, pkg)
+		}
+		c.mu.Unlock()
+		return nil, res.err
 	}
+	return res.markers, nil
+}
 
+// Forget discards the cached markers for the given package, so that they're
+// collected again by the next call to MarkersInPackage (for instance, after
+// the package has been invalidated).
+func (c *Collector) Forget(pkg *loader.Package) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.byPackage[pkg] = markers
-	return markers, nil
+	c.init()
+	delete(c.byPackage, pkg)
+}
+
+// SourceMarker is a marker written in a comment, as found by
+// Collector.SourceMarkers.
+type SourceMarker struct {
+	// Marker is the text of the marker (including the leading "+").
+	Marker string
+	// Value is the parsed value of the marker.
+	Value any
+	// Comment is the comment containing the marker.
+	Comment *ast.Comment
+	// Node is the node the marker is associated with (an *ast.File for
+	// package-level markers).
+	Node ast.Node
+}
+
+// SourceMarkers finds the markers with the given definition written in the
+// given package, sorted by position, for reporting problems with particular
+// markers.  Unlike MarkersInPackage, it skips markers from macros and
+// overlays, since they're not written where they apply, along with markers
+// that fail to parse (which MarkersInPackage reports).
+func (c *Collector) SourceMarkers(pkg *loader.Package, def *Definition) []SourceMarker {
+	c.mu.Lock()
+	c.init()
+	c.mu.Unlock()
+
+	pkg.NeedSyntax()
+	var res []SourceMarker
+	for node, markersRaw := range c.associatePkgMarkers(pkg) {
+		target := targetFor(node)
+		for _, markerRaw := range markersRaw {
+			markerText := markerRaw.Text()
+			if c.Registry.Lookup(markerText, target) != def {
+				continue
+			}
+			val, err := def.Parse(markerText)
+			if err != nil {
+				continue
+			}
+			res = append(res, SourceMarker{
+				Marker:  markerText,
+				Value:   val,
+				Comment: markerRaw.Comment,
+				Node:    node,
+			})
+		}
+	}
+
+	slices.SortFunc(res, func(a, b SourceMarker) int {
+		return cmp.Compare(a.Comment.Pos(), b.Comment.Pos())
+	})
+	return res
 }
 
-// parseMarkersInPackage parses the given raw marker comments into output values using the registry.
-func (c *Collector) parseMarkersInPackage(nodeMarkersRaw map[ast.Node][]markerComment) (map[ast.Node]MarkerValues, error) {
-	var errors []error
+// parseMarkersInPackage parses the given raw marker comments from the given
+// package (along with any overlays for it) into output values using the registry.
+func (c *Collector) parseMarkersInPackage(pkg *loader.Package, nodeMarkersRaw map[ast.Node][]markerComment) (map[ast.Node]MarkerValues, error) {
+	macros, errors := c.macrosIn(nodeMarkersRaw)
 	nodeMarkerValues := make(map[ast.Node]MarkerValues)
 	for node, markersRaw := range nodeMarkersRaw {
-		var target TargetType
-		switch node.(type) {
-		case *ast.File:
-			target = DescribesPackage
-		case *ast.Field:
-			target = DescribesField
-		default:
-			target = DescribesType
-		}
+		target := targetFor(node)
 		markerVals := make(map[string][]any)
 		for _, markerRaw := range markersRaw {
 			markerText := markerRaw.Text()
+			if macro, isMacro := macroFor(macros, markerText); isMacro {
+				errors = append(errors, c.expandMacro(macro, markerRaw, target, markerVals)...)
+				continue
+			}
 			def := c.Registry.Lookup(markerText, target)
 			if def == nil {
 				continue
 			}
 			val, err := def.Parse(markerText)
 			if err != nil {
-				errors = append(errors, loader.ErrFromNode(err, markerRaw))
+				errors = append(errors, markerRaw.wrapError(def.Name, err))
 				continue
 			}
 			markerVals[def.Name] = append(markerVals[def.Name], val)
 		}
 		nodeMarkerValues[node] = markerVals
 	}
+	errors = append(errors, c.applyOverlays(pkg, nodeMarkerValues)...)
 
 	return nodeMarkerValues, loader.MaybeErrList(errors)
 }
 
+// targetFor returns the kind of node that markers associated with the given
+// node describe.
+func targetFor(node ast.Node) TargetType {
+	switch node.(type) {
+	case *ast.File:
+		return DescribesPackage
+	case *ast.Field:
+		return DescribesField
+	case *ast.FuncDecl:
+		return DescribesFunc
+	case *ast.ValueSpec:
+		return DescribesConst
+	default:
+		return DescribesType
+	}
+}
+
+// MarkerError is an error encountered while parsing a particular marker,
+// as reported by the Collector.  Its message is that of the underlying error.
+type MarkerError struct {
+	// Marker is the name of the marker that couldn't be parsed.
+	Marker string
+	// Err is the underlying error.
+	Err error
+	// End is the source position of the end of the part of the marker
+	// that the error is about, if known (e.g. for ScannerErrors).
+	End token.Pos
+}
+
+func (e MarkerError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e MarkerError) Unwrap() error {
+	return e.Err
+}
+
+// wrapError wraps the given error (or each error in the given list) in a
+// MarkerError for the given marker, positioned at the exact place in this
+// marker comment that the error occurred at, if known.
+func (c markerComment) wrapError(marker string, err error) error {
+	if errList, isList := err.(loader.ErrList); isList {
+		wrapped := make(loader.ErrList, len(errList))
+		for i, subErr := range errList {
+			wrapped[i] = c.wrapError(marker, subErr)
+		}
+		return wrapped
+	}
+	var node loader.Node = c
+	markerErr := MarkerError{Marker: marker, Err: err}
+	if scanErr, isScanErr := err.(*ScannerError); isScanErr {
+		node = sourcePos(c.posAt(scanErr.Offset))
+		// map the end through the marker's lines too, since it may be on a
+		// different line than the start (for continued markers).
+		markerErr.End = c.posAt(max(scanErr.EndOffset, scanErr.Offset+1))
+	}
+	return loader.ErrFromNode(markerErr, node)
+}
+
+// splitErrors returns the errors in the given list of errors, or just the
+// given error if it's not a list.
+func splitErrors(err error) []error {
+	if errList, isList := err.(loader.ErrList); isList {
+		return errList
+	}
+	return []error{err}
+}
+
+// sourcePos is a position in the source, usable as a loader.Node.
+type sourcePos token.Pos
+
+func (p sourcePos) Pos() token.Pos { return token.Pos(p) }
+
 // associatePkgMarkers associates markers with AST nodes in the given package.
 func (c *Collector) associatePkgMarkers(pkg *loader.Package) map[ast.Node][]markerComment {
 	nodeMarkers := make(map[ast.Node][]markerComment)
@@ -158,10 +325,11 @@ func (c *Collector) associateFileMarkers(file *ast.File) map[ast.Node][]markerCo
 	lastFileMarkers := visitor.markersBetween(false, visitor.commentInd, len(visitor.allComments))
 	visitor.pkgMarkers = append(visitor.pkgMarkers, lastFileMarkers...)
 
-	// figure out if any type-level markers are actually package-level markers
+	// figure out if any type-, func-, or const-level markers are actually
+	// package-level markers
 	for node, markers := range visitor.nodeMarkers {
-		_, isType := node.(*ast.TypeSpec)
-		if !isType {
+		target := targetFor(node)
+		if target != DescribesType && target != DescribesFunc && target != DescribesConst {
 			continue
 		}
 		endOfMarkers := 0
@@ -173,21 +341,23 @@ func (c *Collector) associateFileMarkers(file *ast.File) map[ast.Node][]markerCo
 				continue
 			}
 			markerText := marker.Text()
-			typeDef := c.Registry.Lookup(markerText, DescribesType)
-			if typeDef != nil {
-				// prefer assuming type-level markers
+			nodeDef := c.Registry.Lookup(markerText, target)
+			if nodeDef != nil {
+				// prefer assuming type-level (or func- or const-level) markers
 				markers[endOfMarkers] = marker
 				endOfMarkers++
 				continue
 			}
 			def := c.Registry.Lookup(markerText, DescribesPackage)
-			if def == nil {
-				// assume type-level unless proven otherwise
+			if def == nil && target == DescribesType {
+				// assume type-level unless proven otherwise (free comments
+				// before funcs and consts have always been package-level,
+				// though, so keep them that way)
 				markers[endOfMarkers] = marker
 				endOfMarkers++
 				continue
 			}
-			// it's package-level, since a package-level definition exists
+			// otherwise, it's package-level
 			visitor.pkgMarkers = append(visitor.pkgMarkers, marker)
 		}
 		visitor.nodeMarkers[node] = markers[:endOfMarkers] // re-set after trimming the package markers
@@ -202,14 +372,109 @@ func (c *Collector) associateFileMarkers(file *ast.File) map[ast.Node][]markerCo
 // marker re-associated (from type-level to package-level)
 type markerComment struct {
 	*ast.Comment
-	fromGodoc bool
+	// continuations are the comments that the marker is continued onto,
+	// if it spans several lines (see JoinMarkerLines).
+	continuations []*ast.Comment
+	fromGodoc     bool
+}
+
+// End returns the end of the last comment that the marker spans.
+func (c markerComment) End() token.Pos {
+	if len(c.continuations) > 0 {
+		return c.continuations[len(c.continuations)-1].End()
+	}
+	return c.Comment.End()
+}
+
+// lines returns the text of each comment that the marker spans, without
+// the comment markers.
+func (c markerComment) lines() []string {
+	lines := []string{c.Comment.Text[2:]}
+	for _, comment := range c.continuations {
+		lines = append(lines, comment.Text[2:])
+	}
+	return lines
 }
 
 // Text returns the text of the marker, stripped of the comment
-// marker and leading spaces, as should be passed to Registry.Lookup
-// and Registry.Parse.
+// marker and leading spaces, and joined into a single line if continued
+// over several, as should be passed to Registry.Lookup and Registry.Parse.
 func (c markerComment) Text() string {
-	return strings.TrimSpace(c.Comment.Text[2:])
+	text, _, _ := joinMarkerLines(c.lines())
+	return text
+}
+
+// posAt returns the source position of the given byte offset into the
+// marker's Text.
+func (c markerComment) posAt(offset int) token.Pos {
+	text, starts, _ := joinMarkerLines(c.lines())
+	comments := append([]*ast.Comment{c.Comment}, c.continuations...)
+	line := 0
+	for line+1 < len(starts) && offset >= starts[line+1] {
+		line++
+	}
+	lineEnd := len(text)
+	if line+1 < len(starts) {
+		// not counting the space replacing the line break
+		lineEnd = starts[line+1] - 1
+	}
+	offset = min(offset, lineEnd)
+
+	comment := comments[line]
+	content := comment.Text[2:]
+	leading := len(content) - len(strings.TrimLeftFunc(content, unicode.IsSpace))
+	return comment.Pos() + token.Pos(2+leading+offset-starts[line])
+}
+
+// JoinMarkerLines joins a marker that's continued over several comment
+// lines, given the text of each line (without the comment marker), starting
+// with the line containing the marker.  A line is continued onto the next
+// one if it ends with a backslash, in which case the backslash and line break
+// (along with the spaces around them) are replaced by a single space:
+//
+//	// +kubebuilder:validation:XValidation:rule="self.minReplicas <= \
+//	//   self.maxReplicas",message="must be at most maxReplicas"
+//
+// It returns the joined marker, along with the number of lines that it
+// spans.
+func JoinMarkerLines(lines []string) (marker string, spans int) {
+	marker, _, spans = joinMarkerLines(lines)
+	return marker, spans
+}
+
+// joinMarkerLines implements JoinMarkerLines, additionally returning the
+// offset in the joined marker where each spanned line starts.
+func joinMarkerLines(lines []string) (marker string, starts []int, spans int) {
+	var out strings.Builder
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if i > 0 {
+			out.WriteByte(' ')
+		}
+		starts = append(starts, out.Len())
+		content, continued := strings.CutSuffix(line, `\`)
+		if !continued || i == len(lines)-1 {
+			out.WriteString(line)
+			return out.String(), starts, i + 1
+		}
+		out.WriteString(strings.TrimRight(content, " \t"))
+	}
+	return out.String(), starts, len(lines)
+}
+
+// markerContinuations returns the comments that the marker in the given
+// comment (at the given index in the given list) is continued onto, if any.
+// Only single-line (`//`) comments can continue a marker.
+func markerContinuations(list []*ast.Comment, ind int) []*ast.Comment {
+	var lines []string
+	for _, comment := range list[ind:] {
+		if !strings.HasPrefix(comment.Text, "//") {
+			break
+		}
+		lines = append(lines, comment.Text[2:])
+	}
+	_, spans := JoinMarkerLines(lines)
+	return list[ind+1 : ind+spans]
 }
 
 // markerVisistor visits AST nodes, recording markers associated with each node.
@@ -245,11 +510,14 @@ func (v *markerVisitor) markersBetween(fromGodoc bool, start, end int) []markerC
 	var res []markerComment
 	for i := start; i < end; i++ {
 		commentGroup := v.allComments[i]
-		for _, comment := range commentGroup.List {
+		for j := 0; j < len(commentGroup.List); j++ {
+			comment := commentGroup.List[j]
 			if !isMarkerComment(comment.Text) {
 				continue
 			}
-			res = append(res, markerComment{Comment: comment, fromGodoc: fromGodoc})
+			continuations := markerContinuations(commentGroup.List, j)
+			res = append(res, markerComment{Comment: comment, continuations: continuations, fromGodoc: fromGodoc})
+			j += len(continuations)
 		}
 	}
 	return res
@@ -349,9 +617,9 @@ func (v markerSubVisitor) Visit(node ast.Node) ast.Visitor {
 	// associate those markers with a node
 	switch typedNode := node.(type) {
 	case *ast.GenDecl:
-		// save the comments associated with the gen-decl if it's a single-line type decl
-		if typedNode.Lparen != token.NoPos || typedNode.Tok != token.TYPE {
-			// not a single-line type spec, treat them as free comments
+		// save the comments associated with the gen-decl if it's a single-line type or const decl
+		if typedNode.Lparen != token.NoPos || (typedNode.Tok != token.TYPE && typedNode.Tok != token.CONST) {
+			// not a single-line type or const spec, treat them as free comments
 			v.pkgMarkers = append(v.pkgMarkers, markerCommentBlock...)
 			break
 		}
@@ -370,6 +638,21 @@ func (v markerSubVisitor) Visit(node ast.Node) ast.Visitor {
 	case *ast.Field:
 		v.nodeMarkers[node] = append(v.nodeMarkers[node], markerCommentBlock...)
 		v.nodeMarkers[node] = append(v.nodeMarkers[node], docCommentBlock...)
+	case *ast.FuncDecl:
+		v.nodeMarkers[node] = append(v.nodeMarkers[node], markerCommentBlock...)
+		v.nodeMarkers[node] = append(v.nodeMarkers[node], docCommentBlock...)
+	case *ast.ValueSpec:
+		decl, inDecl := v.node.(*ast.GenDecl)
+		if !inDecl || decl.Tok != token.CONST {
+			// markers on variables aren't collected
+			break
+		}
+		// add in comments attributed to the gen-decl, if any (for single-line
+		// decls), as well as the godoc of the actual spec (free comments in a
+		// const block aren't collected)
+		v.nodeMarkers[node] = append(v.nodeMarkers[node], v.declComments...)
+		v.nodeMarkers[node] = append(v.nodeMarkers[node], docCommentBlock...)
+		v.declComments = nil
 	case *ast.File:
 		v.pkgMarkers = append(v.pkgMarkers, markerCommentBlock...)
 		v.pkgMarkers = append(v.pkgMarkers, docCommentBlock...)
@@ -17,8 +17,16 @@ limitations under the License.
 package markers_test
 
 import (
+	"go/ast"
+	"reflect"
+	"sync"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	pkgstest "golang.org/x/tools/go/packages/packagestest"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	testloader "sigs.k8s.io/controller-tools/pkg/loader/testutils"
 	. "sigs.k8s.io/controller-tools/pkg/markers"
 )
 
@@ -68,6 +76,27 @@ var _ = Describe("Collecting", func() {
 		Expect(fakePkg.Errors).To(HaveLen(0))
 	})
 
+	It("should return the same markers when collecting concurrently", func() {
+		By("collecting markers from a fresh collector in several goroutines")
+		freshCol := &Collector{Registry: col.Registry}
+		results := make([]map[ast.Node]MarkerValues, 8)
+		var wg sync.WaitGroup
+		for i := range results {
+			wg.Go(func() {
+				defer GinkgoRecover()
+				var err error
+				results[i], err = freshCol.MarkersInPackage(fakePkg)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		}
+		wg.Wait()
+
+		By("checking that every caller got the single cached result")
+		for _, res := range results[1:] {
+			Expect(reflect.ValueOf(res).Pointer()).To(Equal(reflect.ValueOf(results[0]).Pointer()))
+		}
+	})
+
 	Context("of package-level markers", func() {
 
 		It("should consider markers anywhere not obviously type- or field-level as package-level", func() {
@@ -86,6 +115,28 @@ var _ = Describe("Collecting", func() {
 			By("checking that it doesn't contain any markers it's not supposed to")
 			Expect(pkgMarkers).To(HaveKeyWithValue("testing:pkglvl", Not(ContainElement(ContainSubstring("not here")))))
 		})
+
+		It("should find the comments containing them, in order", func() {
+			def := col.Lookup("+testing:pkglvl", DescribesPackage)
+			Expect(def).NotTo(BeNil())
+			sourceMarkers := col.SourceMarkers(fakePkg, def)
+
+			By("checking that it contains the same markers as PackageMarkers")
+			pkgMarkers, err := PackageMarkers(col, fakePkg)
+			Expect(err).NotTo(HaveOccurred())
+			values := make([]any, len(sourceMarkers))
+			for i, marker := range sourceMarkers {
+				values[i] = marker.Value
+				Expect(marker.Comment.Text).To(HaveSuffix(marker.Marker))
+				Expect(marker.Node).To(BeAssignableToTypeOf(&ast.File{}))
+			}
+			Expect(values).To(ConsistOf(pkgMarkers["testing:pkglvl"]...))
+
+			By("checking that they're sorted by position")
+			for i := 1; i < len(sourceMarkers); i++ {
+				Expect(sourceMarkers[i].Comment.Pos()).To(BeNumerically(">", sourceMarkers[i-1].Comment.Pos()))
+			}
+		})
 	})
 
 	Context("of type-level markers", func() {
@@ -234,3 +285,207 @@ foo:
 		})
 	})
 })
+
+type continuedStruct struct {
+	Rule    string
+	Message string
+}
+
+var _ = Describe("Collecting markers continued over several lines", Ordered, func() {
+	var pkg, badPkg *loader.Package
+	var col *Collector
+
+	BeforeAll(func() {
+		modules := []pkgstest.Module{
+			{
+				Name: "sigs.k8s.io/controller-tools/pkg/markers/continued",
+				Files: map[string]any{
+					"file.go": `
+						package continued
+
+						// +testing:multi:rule="self.a > \
+						//   self.b",message=must be \
+						//   bigger
+						// +testing:typelvl=after
+						type Foo struct {
+							// Doc for A.
+							// +testing:fieldlvl={1,\
+							// 2}
+							// More doc.
+							A string
+						}
+					`,
+					"bad/file.go": `
+						package bad
+
+						type Foo struct {
+							// +testing:fieldlvl={1, \
+							// 2 x}
+							A string
+						}
+					`,
+				},
+			},
+		}
+		pkgs, exported, err := testloader.LoadFakeRoots(pkgstest.Modules, modules,
+			"sigs.k8s.io/controller-tools/pkg/markers/continued", "sigs.k8s.io/controller-tools/pkg/markers/continued/bad")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(exported.Cleanup)
+		Expect(pkgs).To(HaveLen(2))
+		pkg, badPkg = pkgs[0], pkgs[1]
+
+		reg := &Registry{}
+		mustDefine(reg, "testing:multi", DescribesType, continuedStruct{})
+		mustDefine(reg, "testing:typelvl", DescribesType, "")
+		mustDefine(reg, "testing:fieldlvl", DescribesField, []int{})
+		col = &Collector{Registry: reg}
+	})
+
+	It("should join the lines of continued markers, and not treat the continuations as docs", func() {
+		var infos []*TypeInfo
+		Expect(EachType(col, pkg, func(info *TypeInfo) { infos = append(infos, info) })).To(Succeed())
+		Expect(infos).To(HaveLen(1))
+		Expect(infos[0].Markers).To(Equal(MarkerValues{
+			"testing:multi":   {continuedStruct{Rule: "self.a > self.b", Message: "must be bigger"}},
+			"testing:typelvl": {"after"},
+		}))
+		Expect(infos[0].Fields).To(HaveLen(1))
+		Expect(infos[0].Fields[0].Markers).To(Equal(MarkerValues{"testing:fieldlvl": {[]int{1, 2}}}))
+		Expect(infos[0].Fields[0].Doc).To(Equal("Doc for A.\nMore doc."))
+	})
+
+	It("should report errors at the exact place that they occur at, with an excerpt", func() {
+		_, err := col.MarkersInPackage(badPkg)
+		// one list of errors per marker
+		Expect(err).To(BeAssignableToTypeOf(loader.ErrList{}))
+		Expect(err.(loader.ErrList)).To(HaveLen(1))
+		markerErrs := err.(loader.ErrList)[0]
+		Expect(markerErrs).To(BeAssignableToTypeOf(loader.ErrList{}))
+		Expect(markerErrs.(loader.ErrList)).NotTo(BeEmpty())
+
+		firstErr := markerErrs.(loader.ErrList)[0]
+		Expect(firstErr).To(BeAssignableToTypeOf(loader.PositionedError{}))
+		pos := badPkg.FileSet().Position(firstErr.(loader.PositionedError).Pos)
+		// the "x" on the second line of the marker
+		Expect(pos.Line).To(Equal(6))
+		Expect(pos.Column).To(Equal(13))
+		Expect(firstErr.Error()).To(Equal("expected comma, got \"x\"\n\t+testing:fieldlvl={1, 2 x}\n\t                        ^"))
+	})
+})
+
+var _ = Describe("Joining marker lines", func() {
+	It("should join lines ending in backslashes with single spaces", func() {
+		marker, spans := JoinMarkerLines([]string{` +a:b="c \`, `  d",\`, ` e=f`, ` +g`})
+		Expect(marker).To(Equal(`+a:b="c d", e=f`))
+		Expect(spans).To(Equal(3))
+	})
+
+	It("should keep a trailing backslash on the last line", func() {
+		marker, spans := JoinMarkerLines([]string{` +a=b\`})
+		Expect(marker).To(Equal(`+a=b\`))
+		Expect(spans).To(Equal(1))
+	})
+})
+
+var _ = Describe("Collecting markers on functions and constants", Ordered, func() {
+	var pkg *loader.Package
+	var col *Collector
+
+	BeforeAll(func() {
+		modules := []pkgstest.Module{
+			{
+				Name: "sigs.k8s.io/controller-tools/pkg/markers/funcs",
+				Files: map[string]any{
+					"file.go": `
+						package funcs
+
+						// +testing:pkglvl="here before func"
+
+						// Reconcile does things.
+						// +testing:funclvl="here in godoc"
+						func Reconcile() {}
+
+						// +testing:funclvl="here before method"
+						func (r *Reconciler[T]) Reconcile() {}
+
+						type Reconciler[T any] struct{}
+
+						type Phase string
+
+						// +testing:constlvl="here on single-line const"
+						// Running is running.
+						const Running Phase = "Running"
+
+						const (
+							// Pending is pending.
+							// +testing:constlvl="here in godoc"
+							Pending Phase = "Pending"
+
+							// +testing:constlvl="not here free in block"
+
+							// +testing:constlvl="here on both"
+							A, B = iota, iota
+							C
+						)
+
+						// +testing:constlvl="not here on var"
+						var d = 1
+					`,
+				},
+			},
+		}
+		pkgs, exported, err := testloader.LoadFakeRoots(pkgstest.Modules, modules, "sigs.k8s.io/controller-tools/pkg/markers/funcs")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(exported.Cleanup)
+		Expect(pkgs).To(HaveLen(1))
+		pkg = pkgs[0]
+
+		reg := &Registry{}
+		mustDefine(reg, "testing:pkglvl", DescribesPackage, "")
+		mustDefine(reg, "testing:funclvl", DescribesFunc, "")
+		mustDefine(reg, "testing:constlvl", DescribesConst, "")
+		col = &Collector{Registry: reg}
+	})
+
+	It("should associate markers with functions and methods", func() {
+		var infos []*FuncInfo
+		Expect(EachFunc(col, pkg, func(info *FuncInfo) { infos = append(infos, info) })).To(Succeed())
+		Expect(infos).To(HaveLen(2))
+
+		Expect(infos[0].Name).To(Equal("Reconcile"))
+		Expect(infos[0].Receiver).To(BeEmpty())
+		Expect(infos[0].Doc).To(Equal("Reconcile does things."))
+		Expect(infos[0].Markers).To(Equal(MarkerValues{"testing:funclvl": {"here in godoc"}}))
+
+		Expect(infos[1].Name).To(Equal("Reconcile"))
+		Expect(infos[1].Receiver).To(Equal("Reconciler"))
+		Expect(infos[1].Markers).To(Equal(MarkerValues{"testing:funclvl": {"here before method"}}))
+	})
+
+	It("should keep package-level markers before functions package-level", func() {
+		pkgMarkers, err := PackageMarkers(col, pkg)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkgMarkers).To(Equal(MarkerValues{"testing:pkglvl": {"here before func"}}))
+	})
+
+	It("should associate markers with constants, one per name", func() {
+		var infos []*ConstInfo
+		Expect(EachConst(col, pkg, func(info *ConstInfo) { infos = append(infos, info) })).To(Succeed())
+
+		var names, typeNames []string
+		for _, info := range infos {
+			names = append(names, info.Name)
+			typeNames = append(typeNames, info.Type)
+		}
+		Expect(names).To(Equal([]string{"Running", "Pending", "A", "B", "C"}))
+		Expect(typeNames).To(Equal([]string{"Phase", "Phase", "", "", ""}))
+
+		Expect(infos[0].Doc).To(Equal("Running is running."))
+		Expect(infos[0].Markers).To(Equal(MarkerValues{"testing:constlvl": {"here on single-line const"}}))
+		Expect(infos[1].Doc).To(Equal("Pending is pending."))
+		Expect(infos[1].Markers).To(Equal(MarkerValues{"testing:constlvl": {"here in godoc"}}))
+		Expect(infos[2].Markers).To(Equal(MarkerValues{"testing:constlvl": {"here on both"}}))
+		Expect(infos[3].Markers).To(Equal(MarkerValues{"testing:constlvl": {"here on both"}}))
+		Expect(infos[4].Markers).To(BeEmpty())
+	})
+})
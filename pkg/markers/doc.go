@@ -47,6 +47,12 @@ limitations under the License.
 // Note that the first form will not properly parse nested slices, but is
 // generally convenient and is the form used in many existing markers.
 //
+// Long markers may be continued over several comment lines by ending each
+// line but the last with a backslash (see JoinMarkerLines):
+//
+//	+path:to:marker:arg1=val,\
+//	  arg2=val2
+//
 // Each of those argument types maps to the corresponding go type.  Pointers
 // mark optional fields (a struct tag, below, may also be used).  The empty
 // interface will match any type.
@@ -61,12 +67,17 @@ limitations under the License.
 // non-optional fields aren't mentioned, an error will be raised unless
 // `Strict` is set to false.
 //
+// Definition.Serialize goes the other way, turning a parsed value back into a
+// marker in a canonical form, and Definition.Format uses that to normalize
+// markers written in any of the forms above.
+//
 // # Registries and Lookup
 //
 // Definitions can be added to registries to facilitate lookups.  Each
-// definition is marked as either describing a type, struct field, or package
-// (unassociated).  The same marker name may be registered multiple times, as
-// long as each describes a different construct (type, field, or package).
+// definition is marked as either describing a type, struct field, function
+// (or method), constant, or package (unassociated).  The same marker name may
+// be registered multiple times, as long as each describes a different
+// construct.
 // Definitions can then be looked up by passing unparsed markers.
 //
 // # Collection and Extraction
@@ -82,6 +93,12 @@ limitations under the License.
 // block" may also be considered package level if registered as such and no
 // identical type-level definition exists.
 //
+// Uses of macros (see Macro), defined with MacroDefinition or in
+// Collector.Macros, are replaced by the markers they expand to before
+// looking the markers up in the Registry.  Overlays (see Overlay) attach
+// markers to types and fields without editing their source (e.g. for types
+// from other modules), merged in as if they were written in the source.
+//
 // Like loader.Package, Collector's methods are idempotent and will not
 // reperform work.
 //
@@ -91,6 +108,9 @@ limitations under the License.
 // conveniently structured type and field information with marker values
 // associated.
 //
+// EachFunc and EachConst similarly iterate over the functions (and methods)
+// and constants in a Package.
+//
 // PackageMarkers can be used to fetch just package-level markers.
 //
 // # Help
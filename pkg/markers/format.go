@@ -0,0 +1,88 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// formatWidth is the length past which Format splits markers with several
+// arguments over several lines.
+const formatWidth = 100
+
+// Format returns the canonical form of the given marker, parsing it with
+// this definition and serializing the result (see Definition.Serialize).
+// Markers with several arguments that would be longer than 100 characters are
+// continued over several lines, with one argument per line (see
+// JoinMarkerLines), so the result may contain line breaks.
+//
+// It returns the marker as-is if it can't be serialized, or if the canonical
+// form wouldn't parse back into the same value (e.g. because it would match
+// a different definition in the given registry).
+func (d *Definition) Format(reg *Registry, marker string) (string, error) {
+	val, err := d.Parse(marker)
+	if err != nil {
+		return marker, err
+	}
+	head, args, err := d.serialize(val)
+	if err != nil {
+		return marker, nil
+	}
+	formatted := head
+	if len(args) > 0 {
+		formatted = head + ":" + strings.Join(args, ",")
+		if len(formatted) > formatWidth && len(args) > 1 {
+			formatted = head + ":" + strings.Join(args, ",\\\n")
+		}
+	}
+
+	joined, _ := JoinMarkerLines(strings.Split(formatted, "\n"))
+	if reg.Lookup(joined, d.Target) != d {
+		return marker, nil
+	}
+	if reparsed, err := d.Parse(joined); err != nil || !reflect.DeepEqual(reparsed, val) {
+		return marker, nil
+	}
+	return formatted, nil
+}
+
+// UnformattedMarkers finds the markers in the given package that aren't in
+// their canonical form (see Definition.Format), along with that form.
+// Markers that fail to parse are returned with an error.  The results are
+// sorted by position.
+func (c *Collector) UnformattedMarkers(pkg *loader.Package) []MarkerRewrite {
+	return c.rewritesInPackage(pkg, func(def *Definition, marker markerComment) ([]string, bool, error) {
+		formatted, err := def.Format(c.Registry, marker.Text())
+		if err != nil {
+			return nil, true, fmt.Errorf("unable to parse %s: %w", marker.Text(), err)
+		}
+		// compare line-by-line, since the canonical form may be continued
+		// over several lines.
+		lines := marker.lines()
+		for i, line := range lines {
+			lines[i] = strings.TrimSpace(line)
+		}
+		if formatted == strings.Join(lines, "\n") {
+			return nil, false, nil
+		}
+		return []string{formatted}, true, nil
+	})
+}
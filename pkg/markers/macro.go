@@ -0,0 +1,251 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markers
+
+import (
+	"fmt"
+	"go/ast"
+	"regexp"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// MacroMarkerName is the name of the marker used to define macros.
+const MacroMarkerName = "kubebuilder:macro"
+
+// MacroDefinition is the definition of the package-level marker used to
+// define macros (see Macro).  It must be registered with a Collector for
+// macros to be defined in packages (genall does this automatically).
+var MacroDefinition = Must(MakeDefinition(MacroMarkerName, DescribesPackage, Macro{}))
+
+// macroNamePattern matches valid macro names: colon-separated parts, like
+// marker names, the first starting with a letter.
+var macroNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*(:[A-Za-z0-9_.-]+)*$`)
+
+// Macro is a shorthand for a set of markers that are often used together.
+// Writing `+<name>` where a marker is expected is equivalent to writing each
+// of the markers that the macro expands to in its place, e.g.
+//
+//	// +kubebuilder:macro:name=k8sName,expands={"+kubebuilder:validation:MaxLength=253", "+kubebuilder:validation:MinLength=1"}
+//
+// lets `+k8sName` stand for both of those markers on any field in the
+// package.  Macros are defined with package-level markers, in which case
+// they can be used in that package, or via Collector.Macros (e.g. from a
+// config file), in which case they can be used anywhere.
+//
+// Macros take no arguments, and can't refer to other macros.
+type Macro struct {
+	// Name is the name that the macro is used by, without the leading `+`.
+	Name string `marker:"name" json:"name"`
+	// Expands are the markers that the macro stands for, with or without
+	// the leading `+`.
+	Expands []string `marker:"expands" json:"expands"`
+	// Description describes the macro in help output.
+	Description string `marker:"description,optional" json:"description,omitempty"`
+}
+
+// Help returns the help for the marker used to define macros.
+func (Macro) Help() *DefinitionHelp {
+	return &DefinitionHelp{
+		Category: "Macros",
+		DetailedHelp: DetailedHelp{
+			Summary: "defines a macro: a shorthand for a set of markers that are often used together.",
+			Details: "Writing `+<name>` where a marker is expected is equivalent to writing each of the markers\n" +
+				"that the macro expands to in its place.  The macro can be used anywhere in the package\n" +
+				"that it's defined in.  Macros take no arguments, and can't refer to other macros.",
+		},
+		FieldHelp: map[string]DetailedHelp{
+			"Name":        {Summary: "is the name that the macro is used by, without the leading `+`."},
+			"Expands":     {Summary: "are the markers that the macro stands for, with or without the leading `+`."},
+			"Description": {Summary: "describes the macro in help output."},
+		},
+	}
+}
+
+// Validate checks that the macro has a valid name and expands to at least
+// one marker.
+func (m Macro) Validate() error {
+	if !macroNamePattern.MatchString(m.Name) {
+		return fmt.Errorf("invalid macro name %q", m.Name)
+	}
+	if len(m.Expands) == 0 {
+		return fmt.Errorf("macro %q must expand to at least one marker", m.Name)
+	}
+	for _, marker := range m.Expands {
+		if strings.TrimSpace(strings.TrimPrefix(marker, "+")) == "" {
+			return fmt.Errorf("macro %q expands to an empty marker", m.Name)
+		}
+	}
+	return nil
+}
+
+// Markers returns the markers that the macro expands to, each with a leading
+// `+`.
+func (m Macro) Markers() []string {
+	res := make([]string, len(m.Expands))
+	for i, marker := range m.Expands {
+		res[i] = "+" + strings.TrimPrefix(marker, "+")
+	}
+	return res
+}
+
+// Targets returns the kinds of node that the macro can be used on, that is,
+// the ones that all the markers it expands to are defined for in the given
+// registry.
+func (m Macro) Targets(reg *Registry) []TargetType {
+	var res []TargetType
+	for _, target := range targetTypes {
+		if !slices.ContainsFunc(m.Markers(), func(marker string) bool {
+			return reg.Lookup(marker, target) == nil
+		}) {
+			res = append(res, target)
+		}
+	}
+	return res
+}
+
+// MacroError is a problem with one of the markers that a macro expanded to.
+// It's reported at the place that the macro was used, so it doesn't unwrap
+// to the underlying error: any positions in that are relative to the
+// expanded marker, not the source.
+type MacroError struct {
+	// Macro is the name of the macro that was used.
+	Macro string
+	// Err is the problem with the expanded marker.
+	Err error
+}
+
+func (e *MacroError) Error() string {
+	return fmt.Sprintf("in expansion of macro +%s: %v", e.Macro, e.Err)
+}
+
+// MacrosInPackage returns the macros that can be used in the given package
+// (both the ones defined in it and Collector.Macros), sorted by name.
+// Invalid macros are skipped.
+func (c *Collector) MacrosInPackage(pkg *loader.Package) []Macro {
+	c.mu.Lock()
+	c.init()
+	c.mu.Unlock()
+
+	pkg.NeedSyntax()
+	macros, _ := c.macrosIn(c.associatePkgMarkers(pkg))
+	res := make([]Macro, 0, len(macros))
+	for _, macro := range macros {
+		res = append(res, macro)
+	}
+	slices.SortFunc(res, func(a, b Macro) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return res
+}
+
+// macrosIn returns the macros that can be used with the given raw markers
+// for a package, by name, along with any problems with their definitions
+// (other than parse errors, which are reported along with the other markers).
+func (c *Collector) macrosIn(nodeMarkersRaw map[ast.Node][]markerComment) (map[string]Macro, []error) {
+	var errs []error
+	macros := make(map[string]Macro)
+	add := func(macro Macro, node loader.Node) {
+		err := macro.Validate()
+		if err == nil {
+			if _, exists := macros[macro.Name]; exists {
+				err = fmt.Errorf("macro %q is already defined", macro.Name)
+			}
+		}
+		if err == nil && c.isMarker("+"+macro.Name) {
+			err = fmt.Errorf("macro %q has the same name as a marker", macro.Name)
+		}
+		if err != nil {
+			if node != nil {
+				err = loader.ErrFromNode(MarkerError{Marker: MacroMarkerName, Err: err}, node)
+			}
+			errs = append(errs, err)
+			return
+		}
+		macros[macro.Name] = macro
+	}
+
+	for _, macro := range c.Macros {
+		add(macro, nil)
+	}
+	for node, markersRaw := range nodeMarkersRaw {
+		if _, isFile := node.(*ast.File); !isFile {
+			continue
+		}
+		for _, markerRaw := range markersRaw {
+			markerText := markerRaw.Text()
+			def := c.Registry.Lookup(markerText, DescribesPackage)
+			if def == nil || def.Name != MacroMarkerName {
+				continue
+			}
+			val, err := def.Parse(markerText)
+			if err != nil {
+				continue
+			}
+			macro, isMacro := val.(Macro)
+			if !isMacro {
+				continue
+			}
+			add(macro, markerRaw)
+		}
+	}
+	return macros, errs
+}
+
+// isMarker checks if the given marker is defined for any kind of node.
+func (c *Collector) isMarker(marker string) bool {
+	for _, target := range targetTypes {
+		if c.Registry.Lookup(marker, target) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// macroFor returns the macro used by the given marker, if it's a use of one
+// of the given macros.
+func macroFor(macros map[string]Macro, markerText string) (Macro, bool) {
+	macro, isMacro := macros[strings.TrimSpace(strings.TrimPrefix(markerText, "+"))]
+	return macro, isMacro
+}
+
+// expandMacro parses the markers that the given macro expands to for the
+// given kind of node, adding their values to the given ones, and reporting
+// any problems at the given use of the macro.
+func (c *Collector) expandMacro(macro Macro, use markerComment, target TargetType, vals MarkerValues) []error {
+	var errs []error
+	at := sourcePos(use.posAt(0))
+	for _, marker := range macro.Markers() {
+		def := c.Registry.Lookup(marker, target)
+		if def == nil {
+			err := fmt.Errorf("macro +%s expands to %s, which isn't a known %s marker", macro.Name, marker, target)
+			errs = append(errs, loader.ErrFromNode(MarkerError{Marker: macro.Name, Err: err}, at))
+			continue
+		}
+		val, err := def.Parse(marker)
+		if err != nil {
+			for _, parseErr := range splitErrors(err) {
+				errs = append(errs, loader.ErrFromNode(MarkerError{Marker: def.Name, Err: &MacroError{Macro: macro.Name, Err: parseErr}}, at))
+			}
+			continue
+		}
+		vals[def.Name] = append(vals[def.Name], val)
+	}
+	return errs
+}
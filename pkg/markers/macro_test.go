@@ -0,0 +1,135 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markers_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	pkgstest "golang.org/x/tools/go/packages/packagestest"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	testloader "sigs.k8s.io/controller-tools/pkg/loader/testutils"
+	. "sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// flattenErrs flattens nested lists of errors, as returned by a Collector.
+func flattenErrs(err error) []error {
+	errList, isList := err.(loader.ErrList)
+	if !isList {
+		return []error{err}
+	}
+	var res []error
+	for _, subErr := range errList {
+		res = append(res, flattenErrs(subErr)...)
+	}
+	return res
+}
+
+var _ = Describe("Collecting markers with macros", Ordered, func() {
+	var pkg, badPkg *loader.Package
+	var col *Collector
+
+	BeforeAll(func() {
+		modules := []pkgstest.Module{
+			{
+				Name: "sigs.k8s.io/controller-tools/pkg/markers/macros",
+				Files: map[string]any{
+					"file.go": `
+						// +kubebuilder:macro:name=pair,expands={"+testing:fieldlvl={1, 2}"}
+						package macros
+
+						// +global
+						type Foo struct {
+							// +pair
+							A string
+						}
+					`,
+					"bad/file.go": `
+						// +kubebuilder:macro:name=broken,expands={"+testing:fieldlvl={1, x}"}
+						// +kubebuilder:macro:name=typeOnly,expands={"testing:typelvl=x"}
+						// +kubebuilder:macro:name=testing:typelvl,expands={"testing:typelvl=y"}
+						package bad
+
+						type Foo struct {
+							// +broken
+							A string
+							// +typeOnly
+							B string
+						}
+					`,
+				},
+			},
+		}
+		pkgs, exported, err := testloader.LoadFakeRoots(pkgstest.Modules, modules,
+			"sigs.k8s.io/controller-tools/pkg/markers/macros", "sigs.k8s.io/controller-tools/pkg/markers/macros/bad")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(exported.Cleanup)
+		Expect(pkgs).To(HaveLen(2))
+		pkg, badPkg = pkgs[0], pkgs[1]
+
+		reg := &Registry{}
+		mustDefine(reg, "testing:typelvl", DescribesType, "")
+		mustDefine(reg, "testing:fieldlvl", DescribesField, []int{})
+		Expect(reg.Register(MacroDefinition)).To(Succeed())
+		col = &Collector{
+			Registry: reg,
+			Macros:   []Macro{{Name: "global", Expands: []string{"testing:typelvl=from-config"}}},
+		}
+	})
+
+	It("should replace uses of macros from the package and the collector with their expansions", func() {
+		var infos []*TypeInfo
+		Expect(EachType(col, pkg, func(info *TypeInfo) { infos = append(infos, info) })).To(Succeed())
+		Expect(infos).To(HaveLen(1))
+		Expect(infos[0].Markers).To(Equal(MarkerValues{"testing:typelvl": {"from-config"}}))
+		Expect(infos[0].Fields).To(HaveLen(1))
+		Expect(infos[0].Fields[0].Markers).To(Equal(MarkerValues{"testing:fieldlvl": {[]int{1, 2}}}))
+	})
+
+	It("should list the macros usable in a package", func() {
+		var names []string
+		for _, macro := range col.MacrosInPackage(pkg) {
+			names = append(names, macro.Name)
+		}
+		Expect(names).To(Equal([]string{"global", "pair"}))
+	})
+
+	It("should not report uses of macros as unknown markers", func() {
+		Expect(col.UnknownMarkers(pkg, []string{"pair", "global"})).To(BeEmpty())
+	})
+
+	It("should report problems with expansions at the start of the macro's use, and with definitions", func() {
+		_, err := col.MarkersInPackage(badPkg)
+		Expect(err).To(HaveOccurred())
+
+		type problem struct {
+			line, column int
+			msg          string
+		}
+		var problems []problem
+		for _, err := range flattenErrs(err) {
+			Expect(err).To(BeAssignableToTypeOf(loader.PositionedError{}))
+			pos := badPkg.FileSet().Position(err.(loader.PositionedError).Pos)
+			problems = append(problems, problem{line: pos.Line, column: pos.Column, msg: err.Error()})
+		}
+		Expect(problems).To(ConsistOf(
+			problem{line: 4, column: 7, msg: `macro "testing:typelvl" has the same name as a marker`},
+			problem{line: 8, column: 11, msg: "in expansion of macro +broken: expected integer, got \"x\"\n\t+testing:fieldlvl={1, x}\n\t                      ^"},
+			problem{line: 10, column: 11, msg: "macro +typeOnly expands to +testing:typelvl=x, which isn't a known field marker"},
+		))
+	})
+})
@@ -0,0 +1,111 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markers
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// Migration rewrites a deprecated marker with the given definition (as in
+// `+name:arg=value`) into the markers that replace it.  It may return no
+// markers if the marker should just be removed, or an error if the marker
+// can't be migrated automatically.
+type Migration func(def *Definition, marker string) ([]string, error)
+
+// RenameTo returns a Migration that renames markers to the given name,
+// keeping their arguments as they are.
+func RenameTo(name string) Migration {
+	return func(def *Definition, marker string) ([]string, error) {
+		return []string{"+" + name + strings.TrimPrefix(marker, "+"+def.Name)}, nil
+	}
+}
+
+// MarkerRewrite is a marker to rewrite (e.g. a deprecated one found by
+// Collector.MigrationsInPackage), along with its replacements.
+type MarkerRewrite struct {
+	// Marker is the text of the marker (including the leading "+").
+	Marker string
+	// Comment is the comment containing the marker.
+	Comment *ast.Comment
+	// Continuations are the comments that the marker is continued onto,
+	// if it spans several lines (see JoinMarkerLines).
+	Continuations []*ast.Comment
+	// Replacements are the markers replacing it, if any.
+	Replacements []string
+	// Err is set if the marker couldn't be rewritten.
+	Err error
+}
+
+// MigrationsInPackage finds the markers in the given package whose
+// definitions have a Migration registered (see Registry.AddMigration),
+// migrating each of them.  The results are sorted by position.
+func (c *Collector) MigrationsInPackage(pkg *loader.Package) []MarkerRewrite {
+	return c.rewritesInPackage(pkg, func(def *Definition, marker markerComment) ([]string, bool, error) {
+		migration := c.Registry.MigrationFor(def)
+		if migration == nil {
+			return nil, false, nil
+		}
+		replacements, err := migration(def, marker.Text())
+		if err != nil {
+			return nil, true, fmt.Errorf("unable to migrate %s: %w", marker.Text(), err)
+		}
+		return replacements, true, nil
+	})
+}
+
+// rewritesInPackage calls rewrite for each known marker in the given package,
+// collecting the ones that it wants to rewrite, sorted by position.
+func (c *Collector) rewritesInPackage(pkg *loader.Package, rewrite func(def *Definition, marker markerComment) (replacements []string, rewritten bool, err error)) []MarkerRewrite {
+	c.mu.Lock()
+	c.init()
+	c.mu.Unlock()
+
+	pkg.NeedSyntax()
+	var res []MarkerRewrite
+	for node, markersRaw := range c.associatePkgMarkers(pkg) {
+		target := targetFor(node)
+		for _, markerRaw := range markersRaw {
+			markerText := markerRaw.Text()
+			def := c.Registry.Lookup(markerText, target)
+			if def == nil {
+				continue
+			}
+			replacements, rewritten, err := rewrite(def, markerRaw)
+			if !rewritten {
+				continue
+			}
+			res = append(res, MarkerRewrite{
+				Marker:        markerText,
+				Comment:       markerRaw.Comment,
+				Continuations: markerRaw.continuations,
+				Replacements:  replacements,
+				Err:           err,
+			})
+		}
+	}
+
+	slices.SortFunc(res, func(a, b MarkerRewrite) int {
+		return cmp.Compare(a.Comment.Pos(), b.Comment.Pos())
+	})
+	return res
+}
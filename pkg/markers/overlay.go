@@ -0,0 +1,210 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markers
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// Overlay attaches markers to types and fields whose source can't be edited
+// (e.g. ones from other modules), as if they were written in the source
+// (see Collector.Overlays).
+type Overlay struct {
+	// Source describes where the overlay came from (e.g. its file name), for
+	// error messages.
+	Source string
+	// Markers maps targets (see ParseOverlayTarget) to the markers to attach
+	// to them, with or without the leading `+`.
+	Markers map[string][]string
+}
+
+// OverlayTarget is a type or struct field that an Overlay attaches markers
+// to.
+type OverlayTarget struct {
+	// Package is the path of the package containing the type.
+	Package string
+	// Type is the name of the type.
+	Type string
+	// Field is the name of the field, or empty if the target is the type
+	// itself.
+	Field string
+}
+
+func (t OverlayTarget) String() string {
+	if t.Field == "" {
+		return t.Package + "." + t.Type
+	}
+	return t.Package + "." + t.Type + "." + t.Field
+}
+
+// ParseOverlayTarget parses an overlay target of the form
+// `<package path>.<Type>` or `<package path>.<Type>.<Field>`, e.g.
+// `k8s.io/api/core/v1.Container.Name`.  Since types in other packages have
+// to be exported to be used, the type and field names must be exported,
+// which is what tells them apart from dots in the last element of the
+// package path (as in `gopkg.in/yaml.v3.Node`).  Fields are named by their
+// Go name, or by the name of their type if they're embedded.
+func ParseOverlayTarget(target string) (OverlayTarget, error) {
+	lastSlash := strings.LastIndex(target, "/")
+	parts := strings.Split(target[lastSlash+1:], ".")
+	typeInd := slices.IndexFunc(parts, token.IsExported)
+	if typeInd < 1 || len(parts)-typeInd > 2 {
+		return OverlayTarget{}, fmt.Errorf("invalid overlay target %q (must be <package path>.<Type> or <package path>.<Type>.<Field>)", target)
+	}
+	for _, name := range parts[typeInd:] {
+		if !token.IsIdentifier(name) || !token.IsExported(name) {
+			return OverlayTarget{}, fmt.Errorf("invalid overlay target %q (%q isn't an exported name)", target, name)
+		}
+	}
+
+	res := OverlayTarget{
+		Package: target[:lastSlash+1] + strings.Join(parts[:typeInd], "."),
+		Type:    parts[typeInd],
+	}
+	if len(parts)-typeInd == 2 {
+		res.Field = parts[typeInd+1]
+	}
+	return res, nil
+}
+
+// FindOverlayTarget returns the *ast.TypeSpec or *ast.Field for the given
+// target in the given package, or nil if it doesn't exist (or the package
+// isn't the target's package).
+func FindOverlayTarget(pkg *loader.Package, target OverlayTarget) ast.Node {
+	if pkg.PkgPath != target.Package {
+		return nil
+	}
+	pkg.NeedSyntax()
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			genDecl, isGenDecl := decl.(*ast.GenDecl)
+			if !isGenDecl || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if typeSpec.Name.Name != target.Type {
+					continue
+				}
+				if target.Field == "" {
+					return typeSpec
+				}
+				structType, isStruct := typeSpec.Type.(*ast.StructType)
+				if !isStruct {
+					return nil
+				}
+				for _, field := range structType.Fields.List {
+					if slices.Contains(fieldNames(field), target.Field) {
+						return field
+					}
+				}
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// fieldNames returns the names of the fields declared by the given field
+// node, which is the name of the type for embedded fields.
+func fieldNames(field *ast.Field) []string {
+	if len(field.Names) > 0 {
+		names := make([]string, len(field.Names))
+		for i, name := range field.Names {
+			names[i] = name.Name
+		}
+		return names
+	}
+	typ := field.Type
+	for {
+		switch expr := typ.(type) {
+		case *ast.StarExpr:
+			typ = expr.X
+		case *ast.IndexExpr:
+			typ = expr.X
+		case *ast.IndexListExpr:
+			typ = expr.X
+		case *ast.SelectorExpr:
+			return []string{expr.Sel.Name}
+		case *ast.Ident:
+			return []string{expr.Name}
+		default:
+			return nil
+		}
+	}
+}
+
+// applyOverlays adds the values of the markers from the overlays that
+// target the given package to the given values, reporting targets that
+// don't exist, and markers that can't be parsed.  Markers that aren't
+// defined for the kind of node they're attached to are ignored, like in the
+// source.
+func (c *Collector) applyOverlays(pkg *loader.Package, nodeMarkerValues map[ast.Node]MarkerValues) []error {
+	var errs []error
+	for _, overlay := range c.Overlays {
+		rawTargets := make([]string, 0, len(overlay.Markers))
+		for rawTarget := range overlay.Markers {
+			rawTargets = append(rawTargets, rawTarget)
+		}
+		slices.Sort(rawTargets)
+
+		for _, rawTarget := range rawTargets {
+			target, err := ParseOverlayTarget(rawTarget)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", overlay.Source, err))
+				continue
+			}
+			if target.Package != pkg.PkgPath {
+				continue
+			}
+			node := FindOverlayTarget(pkg, target)
+			if node == nil {
+				errs = append(errs, fmt.Errorf("%s: overlay target %s doesn't exist", overlay.Source, target))
+				continue
+			}
+
+			vals := nodeMarkerValues[node]
+			if vals == nil {
+				vals = make(MarkerValues)
+				nodeMarkerValues[node] = vals
+			}
+			targetType := targetFor(node)
+			for _, marker := range overlay.Markers[rawTarget] {
+				marker = "+" + strings.TrimPrefix(marker, "+")
+				def := c.Registry.Lookup(marker, targetType)
+				if def == nil {
+					continue
+				}
+				val, err := def.Parse(marker)
+				if err != nil {
+					for _, parseErr := range splitErrors(err) {
+						errs = append(errs, fmt.Errorf("%s: %s: %w", overlay.Source, target, MarkerError{Marker: def.Name, Err: parseErr}))
+					}
+					continue
+				}
+				vals[def.Name] = append(vals[def.Name], val)
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,136 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markers_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	pkgstest "golang.org/x/tools/go/packages/packagestest"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	testloader "sigs.k8s.io/controller-tools/pkg/loader/testutils"
+	. "sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+var _ = Describe("Parsing overlay targets", func() {
+	DescribeTable("should split targets into the package, type, and field",
+		func(rawTarget string, expected OverlayTarget) {
+			target, err := ParseOverlayTarget(rawTarget)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(target).To(Equal(expected))
+			Expect(target.String()).To(Equal(rawTarget))
+		},
+		Entry("type", "k8s.io/api/core/v1.Container", OverlayTarget{Package: "k8s.io/api/core/v1", Type: "Container"}),
+		Entry("field", "k8s.io/api/core/v1.Container.Name", OverlayTarget{Package: "k8s.io/api/core/v1", Type: "Container", Field: "Name"}),
+		Entry("dots in the package path", "gopkg.in/yaml.v3.Node.Kind", OverlayTarget{Package: "gopkg.in/yaml.v3", Type: "Node", Field: "Kind"}),
+		Entry("package without a slash", "main.Foo", OverlayTarget{Package: "main", Type: "Foo"}),
+	)
+
+	DescribeTable("should reject invalid targets",
+		func(rawTarget string) {
+			_, err := ParseOverlayTarget(rawTarget)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("no type", "k8s.io/api/core/v1"),
+		Entry("no package", "Container.Name"),
+		Entry("unexported field", "k8s.io/api/core/v1.Container.name"),
+		Entry("too many parts", "k8s.io/api/core/v1.Container.Name.Other"),
+	)
+})
+
+var _ = Describe("Collecting markers with overlays", Ordered, func() {
+	var pkg *loader.Package
+	var reg *Registry
+
+	BeforeAll(func() {
+		modules := []pkgstest.Module{
+			{
+				Name: "sigs.k8s.io/controller-tools/pkg/markers/overlays",
+				Files: map[string]any{
+					"file.go": `
+						package overlays
+
+						type Base struct{}
+
+						// +testing:typelvl=source
+						type Foo struct {
+							*Base
+							// +testing:fieldlvl={1}
+							A, B string
+						}
+					`,
+				},
+			},
+		}
+		pkgs, exported, err := testloader.LoadFakeRoots(pkgstest.Modules, modules, "sigs.k8s.io/controller-tools/pkg/markers/overlays")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(exported.Cleanup)
+		Expect(pkgs).To(HaveLen(1))
+		pkg = pkgs[0]
+
+		reg = &Registry{}
+		mustDefine(reg, "testing:typelvl", DescribesType, "")
+		mustDefine(reg, "testing:fieldlvl", DescribesField, []int{})
+	})
+
+	It("should merge the markers from overlays with the ones in the source", func() {
+		col := &Collector{Registry: reg, Overlays: []Overlay{{
+			Source: "overlay.yaml",
+			Markers: map[string][]string{
+				"sigs.k8s.io/controller-tools/pkg/markers/overlays.Foo":      {"testing:typelvl=overlay", "+testing:unknown"},
+				"sigs.k8s.io/controller-tools/pkg/markers/overlays.Foo.B":    {"+testing:fieldlvl={2}"},
+				"sigs.k8s.io/controller-tools/pkg/markers/overlays.Foo.Base": {"+testing:fieldlvl={3}"},
+				"sigs.k8s.io/controller-tools/pkg/markers/other.Foo":         {"+testing:typelvl=elsewhere"},
+			},
+		}}}
+
+		var infos []*TypeInfo
+		Expect(EachType(col, pkg, func(info *TypeInfo) { infos = append(infos, info) })).To(Succeed())
+		Expect(infos).To(HaveLen(2))
+		foo := infos[1]
+		Expect(foo.Name).To(Equal("Foo"))
+		Expect(foo.Markers).To(Equal(MarkerValues{"testing:typelvl": {"source", "overlay"}}))
+		Expect(foo.Fields).To(HaveLen(3))
+		Expect(foo.Fields[0].Markers).To(Equal(MarkerValues{"testing:fieldlvl": {[]int{3}}}))
+		// A and B are declared together, so they share their markers
+		Expect(foo.Fields[2].Name).To(Equal("B"))
+		Expect(foo.Fields[2].Markers).To(Equal(MarkerValues{"testing:fieldlvl": {[]int{1}, []int{2}}}))
+	})
+
+	It("should fail when a target doesn't exist, or its markers can't be parsed", func() {
+		col := &Collector{Registry: reg, Overlays: []Overlay{{
+			Source: "overlay.yaml",
+			Markers: map[string][]string{
+				"sigs.k8s.io/controller-tools/pkg/markers/overlays.Foo.C": {"+testing:fieldlvl={1}"},
+				"sigs.k8s.io/controller-tools/pkg/markers/overlays.Bar":   {"+testing:typelvl=x"},
+				"sigs.k8s.io/controller-tools/pkg/markers/overlays.Foo.A": {"+testing:fieldlvl={x}"},
+			},
+		}}}
+
+		_, err := col.MarkersInPackage(pkg)
+		Expect(err).To(HaveOccurred())
+		var msgs []string
+		for _, err := range flattenErrs(err) {
+			msgs = append(msgs, err.Error())
+		}
+		Expect(msgs).To(ConsistOf(
+			"overlay.yaml: overlay target sigs.k8s.io/controller-tools/pkg/markers/overlays.Bar doesn't exist",
+			"overlay.yaml: overlay target sigs.k8s.io/controller-tools/pkg/markers/overlays.Foo.C doesn't exist",
+			HavePrefix("overlay.yaml: sigs.k8s.io/controller-tools/pkg/markers/overlays.Foo.A: expected integer"),
+		))
+	})
+})
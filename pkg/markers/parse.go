@@ -24,6 +24,7 @@ import (
 	"strings"
 	sc "text/scanner"
 	"unicode"
+	"unicode/utf8"
 
 	"sigs.k8s.io/controller-tools/pkg/loader"
 )
@@ -710,8 +711,17 @@ const (
 	DescribesType
 	// DescribesField indicates that a marker is associated with a struct field.
 	DescribesField
+	// DescribesFunc indicates that a marker is associated with a function or
+	// method declaration.
+	DescribesFunc
+	// DescribesConst indicates that a marker is associated with a constant
+	// declaration.
+	DescribesConst
 )
 
+// targetTypes are all the kinds of node that markers can be associated with.
+var targetTypes = []TargetType{DescribesPackage, DescribesType, DescribesField, DescribesFunc, DescribesConst}
+
 func (t TargetType) String() string {
 	switch t {
 	case DescribesPackage:
@@ -720,6 +730,10 @@ func (t TargetType) String() string {
 		return "type"
 	case DescribesField:
 		return "field"
+	case DescribesFunc:
+		return "func"
+	case DescribesConst:
+		return "const"
 	default:
 		return "(unknown)"
 	}
@@ -744,6 +758,12 @@ type Definition struct {
 	// Strict indicates that this definition should error out when parsing if
 	// not all non-optional fields were seen.
 	Strict bool
+	// MatchesSubNames indicates that this definition also matches markers
+	// without arguments whose names have extra parts after its name (e.g.
+	// `+a:b:c` for a definition named `a:b`), so that those parts can act as
+	// an argument.  The output type should parse its own markers (by
+	// implementing ParseMarker) to make use of them.
+	MatchesSubNames bool
 }
 
 // AnonymousField indicates that the definition has one field,
@@ -861,8 +881,26 @@ func (d *Definition) Parse(rawMarker string) (any, error) {
 	}
 
 	var errs []error
+	// fields is always the tail of the marker
+	fieldsOffset := len(rawMarker) - len(fields)
 	scanner := parserScanner(fields, func(scanner *sc.Scanner, msg string) {
-		errs = append(errs, &ScannerError{Msg: msg, Pos: scanner.Position})
+		pos := scanner.Position
+		if !pos.IsValid() {
+			// errors from the scanner itself are reported at the current position
+			pos = scanner.Pos()
+		}
+		end := pos.Offset
+		if scanner.Position.IsValid() {
+			// cover the offending token
+			end += len(scanner.TokenText())
+		}
+		errs = append(errs, &ScannerError{
+			Msg:       msg,
+			Pos:       pos,
+			Marker:    rawMarker,
+			Offset:    fieldsOffset + pos.Offset,
+			EndOffset: fieldsOffset + end,
+		})
 	})
 
 	// TODO(directxman12): strict parsing where we error out if certain fields aren't optional
@@ -995,11 +1033,64 @@ func splitMarker(raw string) (name string, anonymousName string, restFields stri
 	return name, anonymousName, restFields
 }
 
+// ScannerError is an error encountered while scanning the arguments of a
+// marker, at the given position in them.
 type ScannerError struct {
 	Msg string
 	Pos sc.Position
+
+	// Marker is the whole marker that the error occurred in, if known.
+	Marker string
+	// Offset and EndOffset are the byte offsets in Marker of the start and
+	// end of the part of it that the error is about (usually a single
+	// token).
+	Offset, EndOffset int
 }
 
 func (e *ScannerError) Error() string {
-	return fmt.Sprintf("%s (at %s)", e.Msg, e.Pos)
+	if e.Marker == "" {
+		return fmt.Sprintf("%s (at %s)", e.Msg, e.Pos)
+	}
+	return e.Msg + "\n" + e.Excerpt()
+}
+
+// excerptWidth is the width past which Excerpt only shows part of a marker.
+const excerptWidth = 80
+
+// Excerpt returns the part of the marker around the error (indented by a
+// tab), with carets underneath pointing at the error, like
+//
+//	+a:b=1,c=2 x
+//	           ^
+//
+// Long markers are cut down to the part around the error.
+func (e *ScannerError) Excerpt() string {
+	start, end := 0, len(e.Marker)
+	offset := min(max(e.Offset, 0), len(e.Marker))
+	errEnd := min(max(e.EndOffset, offset+1), len(e.Marker))
+	if end > excerptWidth {
+		start = max(offset-excerptWidth/2, 0)
+		end = min(start+excerptWidth, len(e.Marker))
+		errEnd = min(errEnd, end)
+		// don't cut runes in half
+		for start > 0 && !utf8.RuneStart(e.Marker[start]) {
+			start--
+		}
+		for end < len(e.Marker) && !utf8.RuneStart(e.Marker[end]) {
+			end++
+		}
+	}
+
+	var prefix, suffix string
+	if start > 0 {
+		prefix = "..."
+	}
+	if end < len(e.Marker) {
+		suffix = "..."
+	}
+	// tabs would throw off the alignment of the carets
+	excerpt := strings.ReplaceAll(e.Marker[start:end], "\t", " ")
+	indent := utf8.RuneCountInString(prefix + e.Marker[start:offset])
+	carets := max(utf8.RuneCountInString(e.Marker[offset:max(errEnd, offset)]), 1)
+	return "\t" + prefix + excerpt + suffix + "\n\t" + strings.Repeat(" ", indent) + strings.Repeat("^", carets)
 }
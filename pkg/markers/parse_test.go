@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"strings"
 	sc "text/scanner"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -54,6 +55,15 @@ type CustomType struct {
 	Value any
 }
 
+// subNamedVal takes its value from the part of the marker's name after
+// "testing:subNamed".
+type subNamedVal string
+
+func (v *subNamedVal) ParseMarker(_ string, anonymousName string, _ string) error {
+	*v = subNamedVal(strings.TrimPrefix(strings.TrimPrefix(anonymousName, "testing:subNamed"), ":"))
+	return nil
+}
+
 var _ = Describe("Parsing", func() {
 	var reg *Registry
 
@@ -79,6 +89,11 @@ var _ = Describe("Parsing", func() {
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(reg.Register(defn)).To(Succeed())
+
+			defn, err = MakeDefinition("testing:subNamed", DescribesPackage, subNamedVal(""))
+			Expect(err).NotTo(HaveOccurred())
+			defn.MatchesSubNames = true
+			Expect(reg.Register(defn)).To(Succeed())
 		})
 
 		It("should work with fiddled field names", parseTestCase{reg: &reg, raw: "+testing:custom={hi}", output: CustomType{Value: []string{"hi"}}}.Run)
@@ -161,6 +176,15 @@ var _ = Describe("Parsing", func() {
 			It("should consider anonymously-named ones before considering fields", parseTestCase{reg: &reg, raw: "+testing:parent:optStr=other string", output: allOptionalStruct{OptStr: "other string"}}.Run)
 		})
 
+		Context("when dealing with markers that match sub-names", func() {
+			It("should match extra parts of the name", parseTestCase{reg: &reg, raw: "+testing:subNamed:some-code", output: subNamedVal("some-code")}.Run)
+			It("should match multiple extra parts of the name", parseTestCase{reg: &reg, raw: "+testing:subNamed:some:code", output: subNamedVal("some:code")}.Run)
+			It("should still match the plain name", parseTestCase{reg: &reg, raw: "+testing:subNamed", output: subNamedVal("")}.Run)
+			It("shouldn't match extra parts of the name for other markers", func() {
+				Expect(reg.Lookup("+testing:empty:extra", DescribesPackage)).To(BeNil())
+			})
+		})
+
 		Context("when dealing with markers describing multiple things", func() {
 			It("should properly parse the package-level one", parseTestCase{reg: &reg, raw: "+testing:tripleDefined=42", output: 42, target: DescribesPackage}.Run)
 			It("should properly parse the field-level one", parseTestCase{reg: &reg, raw: "+testing:tripleDefined=foo", output: "foo", target: DescribesField}.Run)
@@ -281,3 +305,19 @@ func (tc argParseTestCase) Run() {
 	By("checking that it equals the expected output")
 	Expect(actualOut.Interface()).To(Equal(tc.output))
 }
+
+var _ = Describe("Scanner errors", func() {
+	It("should point at the part of the marker that the error is about", func() {
+		err := &ScannerError{Msg: "bad", Marker: "+a:b=1,c=foo", Offset: 9, EndOffset: 12}
+		Expect(err.Error()).To(Equal("bad\n\t+a:b=1,c=foo\n\t         ^^^"))
+	})
+
+	It("should only show the part of long markers around the error", func() {
+		marker := "+a:b=" + strings.Repeat("x", 100) + ",c=foo," + strings.Repeat("y", 100)
+		offset := strings.Index(marker, "foo")
+		err := &ScannerError{Msg: "bad", Marker: marker, Offset: offset, EndOffset: offset + 3}
+		excerpt := err.Excerpt()
+		Expect(excerpt).To(HavePrefix("\t..." + strings.Repeat("x", 37) + ",c=foo,"))
+		Expect(excerpt).To(HaveSuffix(strings.Repeat("y", 33) + "...\n\t" + strings.Repeat(" ", 43) + "^^^"))
+	})
+})
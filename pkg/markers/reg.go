@@ -18,6 +18,7 @@ package markers
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 )
 
@@ -27,8 +28,12 @@ type Registry struct {
 	forPkg   map[string]*Definition
 	forType  map[string]*Definition
 	forField map[string]*Definition
+	forFunc  map[string]*Definition
+	forConst map[string]*Definition
 	helpFor  map[*Definition]*DefinitionHelp
 
+	migrations map[*Definition]Migration
+
 	mu       sync.RWMutex
 	initOnce sync.Once
 }
@@ -44,9 +49,18 @@ func (r *Registry) init() {
 		if r.forField == nil {
 			r.forField = make(map[string]*Definition)
 		}
+		if r.forFunc == nil {
+			r.forFunc = make(map[string]*Definition)
+		}
+		if r.forConst == nil {
+			r.forConst = make(map[string]*Definition)
+		}
 		if r.helpFor == nil {
 			r.helpFor = make(map[*Definition]*DefinitionHelp)
 		}
+		if r.migrations == nil {
+			r.migrations = make(map[*Definition]Migration)
+		}
 	})
 }
 
@@ -76,6 +90,10 @@ func (r *Registry) Register(def *Definition) error {
 		r.forType[def.Name] = def
 	case DescribesField:
 		r.forField[def.Name] = def
+	case DescribesFunc:
+		r.forFunc[def.Name] = def
+	case DescribesConst:
+		r.forConst[def.Name] = def
 	default:
 		return fmt.Errorf("unknown target type %v", def.Target)
 	}
@@ -93,6 +111,17 @@ func (r *Registry) AddHelp(def *Definition, help *DefinitionHelp) {
 	r.helpFor[def] = help
 }
 
+// AddMigration stores the given migration in the registry, marking the given
+// (deprecated) definition as replaceable using it.
+func (r *Registry) AddMigration(def *Definition, migration Migration) {
+	r.init()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.migrations[def] = migration
+}
+
 // Lookup fetches the definition corresponding to the given name and target type.
 func (r *Registry) Lookup(name string, target TargetType) *Definition {
 	r.init()
@@ -107,6 +136,10 @@ func (r *Registry) Lookup(name string, target TargetType) *Definition {
 		return tryAnonLookup(name, r.forType)
 	case DescribesField:
 		return tryAnonLookup(name, r.forField)
+	case DescribesFunc:
+		return tryAnonLookup(name, r.forFunc)
+	case DescribesConst:
+		return tryAnonLookup(name, r.forConst)
 	default:
 		return nil
 	}
@@ -122,9 +155,24 @@ func (r *Registry) HelpFor(def *Definition) *DefinitionHelp {
 	return r.helpFor[def]
 }
 
+// MigrationFor fetches the migration for a given definition, if present.
+func (r *Registry) MigrationFor(def *Definition) Migration {
+	r.init()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.migrations[def]
+}
+
 // AllDefinitions returns all marker definitions known to this registry.
 func (r *Registry) AllDefinitions() []*Definition {
-	res := make([]*Definition, 0, len(r.forPkg)+len(r.forType)+len(r.forField))
+	r.init()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]*Definition, 0, len(r.forPkg)+len(r.forType)+len(r.forField)+len(r.forFunc)+len(r.forConst))
 	for _, def := range r.forPkg {
 		res = append(res, def)
 	}
@@ -134,6 +182,12 @@ func (r *Registry) AllDefinitions() []*Definition {
 	for _, def := range r.forField {
 		res = append(res, def)
 	}
+	for _, def := range r.forFunc {
+		res = append(res, def)
+	}
+	for _, def := range r.forConst {
+		res = append(res, def)
+	}
 	return res
 }
 
@@ -149,6 +203,24 @@ func tryAnonLookup(name string, defs map[string]*Definition) *Definition {
 	if def, exists := defs[anonName]; exists {
 		return def
 	}
+	if def, exists := defs[name]; exists {
+		return def
+	}
 
-	return defs[name]
+	if name == anonName {
+		// markers without arguments may carry one in their name instead, for
+		// definitions that allow it (e.g. `+kubebuilder:nolint:some-code`).
+		prefix := name
+		for {
+			idx := strings.LastIndex(prefix, ":")
+			if idx < 0 {
+				return nil
+			}
+			prefix = prefix[:idx]
+			if def, exists := defs[prefix]; exists && def.MatchesSubNames {
+				return def
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,253 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markers
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// isBareString checks if the given string can be written without quotes
+// (as a single bare token that the parser reads back as-is).  If inAny is
+// set, it also checks that the parser wouldn't guess that it's some other type
+// (e.g. a number or bool) when parsing it as AnyType.
+func isBareString(val string, inAny bool) bool {
+	if val == "" || strings.Contains(val, "//") || strings.Contains(val, "/*") {
+		return false
+	}
+	for _, r := range val {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_', r == '-', r == '.', r == '/':
+		default:
+			return false
+		}
+	}
+	if !inAny {
+		return true
+	}
+	first := val[0]
+	isLetter := (first >= 'a' && first <= 'z') || (first >= 'A' && first <= 'Z')
+	return isLetter && val != "true" && val != "false"
+}
+
+// serializeString serializes a string, quoting it if needed.  Strings that
+// need escaping in double quotes (e.g. regular expressions) are written as
+// raw strings where possible.
+func serializeString(val string, inAny bool) string {
+	if isBareString(val, inAny) {
+		return val
+	}
+	if strings.ContainsAny(val, "\\\"") && !strings.Contains(val, "`") && strconv.CanBackquote(val) {
+		return "`" + val + "`"
+	}
+	return strconv.Quote(val)
+}
+
+// serializeNumber serializes a float, using exponents only for very large
+// or very small numbers (like encoding/json).  If inAny is set, integral
+// numbers get a trailing ".0" so that they aren't read back as integers.
+func serializeNumber(val float64, inAny bool) string {
+	format := byte('f')
+	if abs := math.Abs(val); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	res := strconv.FormatFloat(val, format, -1, 64)
+	if inAny && !strings.ContainsAny(res, ".eEIN") {
+		res += ".0"
+	}
+	return res
+}
+
+// serializeAny serializes a value of an AnyType argument, based on its
+// actual type.
+func serializeAny(val reflect.Value) (string, error) {
+	for val.Kind() == reflect.Interface || val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return "", fmt.Errorf("cannot serialize a nil value")
+		}
+		val = val.Elem()
+	}
+
+	switch val.Kind() {
+	case reflect.String:
+		return serializeString(val.String(), true), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(val.Int(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return serializeNumber(val.Float(), true), nil
+	case reflect.Bool:
+		return strconv.FormatBool(val.Bool()), nil
+	case reflect.Slice:
+		items := make([]string, val.Len())
+		for i := range items {
+			item, err := serializeAny(val.Index(i))
+			if err != nil {
+				return "", err
+			}
+			items[i] = item
+		}
+		return "{" + strings.Join(items, ", ") + "}", nil
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String {
+			return "", fmt.Errorf("cannot serialize a map with non-string keys")
+		}
+		return serializeMap(val, func(item reflect.Value) (string, error) {
+			return serializeAny(item)
+		})
+	default:
+		return "", fmt.Errorf("cannot serialize a value of type %s", val.Type())
+	}
+}
+
+// serializeMap serializes a map, sorted by key, serializing the values
+// with the given function.
+func serializeMap(val reflect.Value, serializeItem func(reflect.Value) (string, error)) (string, error) {
+	keys := make([]string, 0, val.Len())
+	for _, key := range val.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	slices.Sort(keys)
+
+	items := make([]string, len(keys))
+	for i, key := range keys {
+		item, err := serializeItem(val.MapIndex(reflect.ValueOf(key).Convert(val.Type().Key())))
+		if err != nil {
+			return "", fmt.Errorf("map key %q: %w", key, err)
+		}
+		// keys are always parsed as strings, but the parser needs to guess
+		// that this is a map (and not a slice) from the first one.
+		items[i] = serializeString(key, true) + ": " + item
+	}
+	return "{" + strings.Join(items, ", ") + "}", nil
+}
+
+// Serialize converts the given value into the canonical form of this
+// argument, such that parsing it produces the same value.  It's the
+// inverse of Parse: strings are only quoted when needed, slices and maps are
+// always written with curly braces, and maps are sorted by key.
+func (a Argument) Serialize(val reflect.Value) (string, error) {
+	if a.Pointer {
+		if val.IsNil() {
+			return "", fmt.Errorf("cannot serialize a nil value")
+		}
+		val = val.Elem()
+	}
+
+	switch a.Type {
+	case RawType:
+		return string(val.Bytes()), nil
+	case IntType:
+		return strconv.FormatInt(val.Int(), 10), nil
+	case NumberType:
+		return serializeNumber(val.Float(), false), nil
+	case StringType:
+		return serializeString(val.String(), false), nil
+	case BoolType:
+		return strconv.FormatBool(val.Bool()), nil
+	case AnyType:
+		return serializeAny(val)
+	case SliceType:
+		items := make([]string, val.Len())
+		for i := range items {
+			item, err := a.ItemType.Serialize(val.Index(i))
+			if err != nil {
+				return "", err
+			}
+			items[i] = item
+		}
+		return "{" + strings.Join(items, ", ") + "}", nil
+	case MapType:
+		return serializeMap(val, a.ItemType.Serialize)
+	default:
+		return "", fmt.Errorf("cannot serialize invalid type")
+	}
+}
+
+// Serialize converts the given value (of this definition's output type)
+// into a marker in canonical form (as in `+a:b:c=arg,d=arg`), such that
+// parsing it produces the same value.  Arguments are written in the order
+// that they're declared in the output type, and optional arguments with
+// zero values are left out.
+//
+// Output types that parse their own markers (by implementing ParseMarker)
+// can't be serialized.
+func (d *Definition) Serialize(value any) (string, error) {
+	head, args, err := d.serialize(value)
+	if err != nil || len(args) == 0 {
+		return head, err
+	}
+	return head + ":" + strings.Join(args, ","), nil
+}
+
+// serialize implements Serialize, returning the marker up to its arguments
+// (or the whole marker, for anonymous ones), and the serialized arguments
+// (as `arg=val`) separately.
+func (d *Definition) serialize(value any) (head string, args []string, err error) {
+	val := reflect.ValueOf(value)
+	if val.Type() != d.Output {
+		return "", nil, fmt.Errorf("expected a value of type %s, got %s", d.Output, val.Type())
+	}
+	if _, isParser := reflect.New(d.Output).Interface().(markerParser); isParser {
+		return "", nil, fmt.Errorf("cannot serialize marker %q, since it parses itself", d.Name)
+	}
+
+	if d.Empty() {
+		return "+" + d.Name, nil, nil
+	}
+
+	if d.AnonymousField() {
+		arg := d.Fields[""]
+		if structFieldName := d.FieldNames[""]; structFieldName != "" {
+			val = val.FieldByName(structFieldName)
+		}
+		if arg.Optional && val.IsZero() {
+			return "+" + d.Name, nil, nil
+		}
+		argVal, err := arg.Serialize(val)
+		if err != nil {
+			return "", nil, err
+		}
+		return "+" + d.Name + "=" + argVal, nil, nil
+	}
+
+	argNames := make(map[string]string, len(d.FieldNames))
+	for argName, fieldName := range d.FieldNames {
+		argNames[fieldName] = argName
+	}
+	for field := range d.Output.Fields() {
+		argName, known := argNames[field.Name]
+		if !known {
+			continue
+		}
+		arg := d.Fields[argName]
+		fieldVal := val.FieldByIndex(field.Index)
+		if arg.Optional && fieldVal.IsZero() {
+			continue
+		}
+		argVal, err := arg.Serialize(fieldVal)
+		if err != nil {
+			return "", nil, fmt.Errorf("argument %q: %w", argName, err)
+		}
+		args = append(args, argName+"="+argVal)
+	}
+	return "+" + d.Name, args, nil
+}
@@ -0,0 +1,174 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markers_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	sc "text/scanner"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// formatTestCase checks that a marker is formatted into the expected
+// canonical form, and that formatting that (once joined back into a single
+// line) changes nothing.
+type formatTestCase struct {
+	reg       **Registry
+	raw       string
+	formatted string
+}
+
+func (tc formatTestCase) Run() {
+	reg := *tc.reg
+	By("looking up the marker definition")
+	defn := reg.Lookup(tc.raw, DescribesPackage)
+	Expect(defn).NotTo(BeNil())
+
+	By("formatting the marker")
+	formatted, err := defn.Format(reg, tc.raw)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(formatted).To(Equal(tc.formatted))
+
+	By("formatting the formatted marker")
+	joined, _ := JoinMarkerLines(strings.Split(formatted, "\n"))
+	Expect(defn.Format(reg, joined)).To(Equal(formatted))
+}
+
+// argSerializeTestCase checks that a value serializes into the expected
+// form for an argument, and parses back into the same value.
+type argSerializeTestCase struct {
+	arg        Argument
+	val        any
+	serialized string
+}
+
+func (tc argSerializeTestCase) Run() {
+	By("serializing the value")
+	serialized, err := tc.arg.Serialize(reflect.ValueOf(tc.val))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(serialized).To(Equal(tc.serialized))
+
+	By("parsing the serialized value")
+	scanner := sc.Scanner{}
+	scanner.Init(strings.NewReader(serialized))
+	scanner.Mode = sc.ScanIdents | sc.ScanInts | sc.ScanFloats | sc.ScanStrings | sc.ScanRawStrings | sc.SkipComments
+	scanner.Error = func(scanner *sc.Scanner, msg string) {
+		Fail(fmt.Sprintf("%s (at %s)", msg, scanner.Position))
+	}
+	var out reflect.Value
+	if tc.arg.Type == AnyType {
+		out = reflect.New(reflect.TypeFor[any]()).Elem()
+	} else {
+		out = reflect.New(reflect.TypeOf(tc.val)).Elem()
+	}
+	tc.arg.Parse(&scanner, serialized, out)
+	Expect(out.Interface()).To(Equal(tc.val))
+}
+
+var _ = Describe("Serializing", func() {
+	Context("arguments", func() {
+		anyArg := Argument{Type: AnyType}
+
+		It("should write plain strings bare", argSerializeTestCase{arg: Argument{Type: StringType}, val: "some-value.v1", serialized: "some-value.v1"}.Run)
+		It("should quote strings with spaces", argSerializeTestCase{arg: Argument{Type: StringType}, val: "some value", serialized: `"some value"`}.Run)
+		It("should use raw strings for strings with backslashes", argSerializeTestCase{arg: Argument{Type: StringType}, val: `^\d+$`, serialized: "`^\\d+$`"}.Run)
+		It("should quote strings that would otherwise be comments", argSerializeTestCase{arg: Argument{Type: StringType}, val: "a//b", serialized: `"a//b"`}.Run)
+		It("should quote empty strings", argSerializeTestCase{arg: Argument{Type: StringType}, val: "", serialized: `""`}.Run)
+		It("should write numbers without exponents", argSerializeTestCase{arg: Argument{Type: NumberType}, val: 1000000.0, serialized: "1000000"}.Run)
+		It("should write slices with curly braces", argSerializeTestCase{
+			arg:        Argument{Type: SliceType, ItemType: &Argument{Type: IntType}},
+			val:        []int{1, 2, 3},
+			serialized: "{1, 2, 3}",
+		}.Run)
+		It("should write maps sorted by key", argSerializeTestCase{
+			arg:        Argument{Type: MapType, ItemType: &Argument{Type: StringType}},
+			val:        map[string]string{"b": "x", "a": "y z"},
+			serialized: `{a: "y z", b: x}`,
+		}.Run)
+		It("should quote strings in any-typed values that would look like other types", argSerializeTestCase{arg: anyArg, val: []string{"true", "42", "a"}, serialized: `{"true", "42", a}`}.Run)
+		It("should keep integral floats in any-typed values as floats", argSerializeTestCase{arg: anyArg, val: 2.0, serialized: "2.0"}.Run)
+		It("should write nested any-typed maps", argSerializeTestCase{
+			arg:        anyArg,
+			val:        map[string]any{"a": map[string]any{"b": []int{1}}, "c": false},
+			serialized: `{a: {b: {1}}, c: false}`,
+		}.Run)
+		It("should refuse to serialize nil values", func() {
+			_, err := anyArg.Serialize(reflect.ValueOf([]any{nil}))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("markers", func() {
+		var reg *Registry
+		BeforeEach(func() {
+			reg = &Registry{}
+
+			mustDefine(reg, "testing:empty", DescribesPackage, struct{}{})
+			mustDefine(reg, "testing:anonymous:literal", DescribesPackage, "")
+			mustDefine(reg, "testing:multiField", DescribesPackage, multiFieldStruct{})
+			mustDefine(reg, "testing:allOptional", DescribesPackage, allOptionalStruct{})
+			mustDefine(reg, "testing:anonymousOptional", DescribesPackage, (*int)(nil))
+			mustDefine(reg, "testing:parent", DescribesPackage, allOptionalStruct{})
+			mustDefine(reg, "testing:parent:optStr", DescribesPackage, "")
+
+			defn, err := MakeAnyTypeDefinition("testing:custom", DescribesPackage, CustomType{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reg.Register(defn)).To(Succeed())
+
+			defn, err = MakeDefinition("testing:subNamed", DescribesPackage, subNamedVal(""))
+			Expect(err).NotTo(HaveOccurred())
+			defn.MatchesSubNames = true
+			Expect(reg.Register(defn)).To(Succeed())
+		})
+
+		It("should leave name-only markers alone", formatTestCase{reg: &reg, raw: "+testing:empty", formatted: "+testing:empty"}.Run)
+		It("should format anonymous markers", formatTestCase{reg: &reg, raw: `+testing:anonymous:literal="foo"`, formatted: "+testing:anonymous:literal=foo"}.Run)
+		It("should leave out unset optional anonymous arguments", formatTestCase{reg: &reg, raw: "+testing:anonymousOptional", formatted: "+testing:anonymousOptional"}.Run)
+		It("should format any-typed markers with fiddled field names", formatTestCase{reg: &reg, raw: "+testing:custom=a;b", formatted: "+testing:custom={a, b}"}.Run)
+		It("should write arguments in declaration order, leaving out unset optional ones", formatTestCase{
+			reg:       &reg,
+			raw:       `+testing:multiField:int=42,str=a,any=21,bool=true,sliceOfSlice={},slice=99;104,other=b`,
+			formatted: `+testing:multiField:str=a,int=42,bool=true,any=21,other=b,slice={99, 104},sliceOfSlice={}`,
+		}.Run)
+		It("should continue long markers over several lines, one argument per line", formatTestCase{
+			reg:       &reg,
+			raw:       `+testing:multiField:int=42,str=some str,any=21,bool=true,sliceOfSlice={{1,1},{2,3}},slice=99;104,other="yet another"`,
+			formatted: "+testing:multiField:str=\"some str\",\\\nint=42,\\\nbool=true,\\\nany=21,\\\nother=\"yet another\",\\\nslice={99, 104},\\\nsliceOfSlice={{1, 1}, {2, 3}}",
+		}.Run)
+		It("should leave out all arguments if they're all unset and optional", formatTestCase{reg: &reg, raw: `+testing:allOptional:optStr=""`, formatted: "+testing:allOptional"}.Run)
+		It("should leave markers alone if their canonical form would match another definition", formatTestCase{reg: &reg, raw: "+testing:parent:optInt=1,optStr=a", formatted: "+testing:parent:optInt=1,optStr=a"}.Run)
+		It("should leave markers that parse themselves alone", formatTestCase{reg: &reg, raw: "+testing:subNamed:some-code", formatted: "+testing:subNamed:some-code"}.Run)
+
+		It("should return an error for markers that fail to parse", func() {
+			raw := "+testing:multiField:str=`hi`"
+			defn := reg.Lookup(raw, DescribesPackage)
+			Expect(defn).NotTo(BeNil())
+			formatted, err := defn.Format(reg, raw)
+			Expect(err).To(HaveOccurred())
+			Expect(formatted).To(Equal(raw))
+		})
+
+		It("should refuse to serialize values of the wrong type", func() {
+			_, err := reg.Lookup("+testing:empty", DescribesPackage).Serialize("foo")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,226 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markers
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+)
+
+// UnknownMarkerPrefixes are the prefixes of the markers that are likely meant
+// for controller-tools, as checked for by Collector.UnknownMarkers.  A marker
+// has a prefix if its name is the prefix, or starts with the prefix followed
+// by a colon.
+var UnknownMarkerPrefixes = []string{
+	"kubebuilder",
+	"k8s",
+	"groupName",
+	"versionName",
+	"listType",
+	"listMapKey",
+	"mapType",
+	"structType",
+	"optional",
+	"required",
+	"nullable",
+}
+
+// ExternalMarkerPrefixes are the prefixes of markers that share a prefix with
+// the markers used by controller-tools, but that are used by other tools, and
+// so are never reported by Collector.UnknownMarkers.
+var ExternalMarkerPrefixes = []string{
+	"kubebuilder:scaffold",
+	"k8s:openapi-gen",
+	"k8s:conversion-gen",
+	"k8s:defaulter-gen",
+	"k8s:prerelease-lifecycle-gen",
+}
+
+// maxSuggestions is the maximum number of suggestions given for an unknown
+// marker.
+const maxSuggestions = 3
+
+// UnknownMarker is a marker that doesn't match any definition for the node
+// it's on, as found by Collector.UnknownMarkers.
+type UnknownMarker struct {
+	// Marker is the text of the marker (including the leading "+").
+	Marker string
+	// Pos is the position of the comment containing the marker.
+	Pos token.Pos
+	// Node is the node the marker is associated with (an *ast.File for
+	// package-level markers).
+	Node ast.Node
+	// Target is the kind of node that the marker is associated with.
+	Target TargetType
+	// DefinedFor lists the other kinds of node that the marker is defined
+	// for, if any (meaning it's misplaced, rather than unknown).
+	DefinedFor []TargetType
+	// Suggestions are the names of defined markers with similar names,
+	// closest first.
+	Suggestions []string
+}
+
+// Misplaced indicates that the marker is defined, but not for the kind of
+// node it's on.
+func (m UnknownMarker) Misplaced() bool {
+	return len(m.DefinedFor) > 0
+}
+
+// Message describes the problem with the marker.
+func (m UnknownMarker) Message() string {
+	if m.Misplaced() {
+		targets := make([]string, len(m.DefinedFor))
+		for i, target := range m.DefinedFor {
+			targets[i] = target.String()
+		}
+		return fmt.Sprintf("marker %q is not valid on a %s (only on a %s)", m.Marker, m.Target, strings.Join(targets, " or "))
+	}
+	if len(m.Suggestions) == 0 {
+		return fmt.Sprintf("unknown marker %q", m.Marker)
+	}
+	return fmt.Sprintf("unknown marker %q (did you mean +%s?)", m.Marker, strings.Join(m.Suggestions, " or +"))
+}
+
+// UnknownMarkers finds the markers in the given package with one of the given
+// prefixes (see UnknownMarkerPrefixes) that don't match any definition in the
+// registry for the node they're on, such as misspelled markers, or field
+// markers placed on a type.  The results are sorted by position.
+func (c *Collector) UnknownMarkers(pkg *loader.Package, prefixes []string) []UnknownMarker {
+	c.mu.Lock()
+	c.init()
+	c.mu.Unlock()
+
+	pkg.NeedSyntax()
+	var res []UnknownMarker
+	var defNames []string
+	nodeMarkersRaw := c.associatePkgMarkers(pkg)
+	macros, _ := c.macrosIn(nodeMarkersRaw)
+	for node, markersRaw := range nodeMarkersRaw {
+		target := targetFor(node)
+		for _, markerRaw := range markersRaw {
+			markerText := markerRaw.Text()
+			if _, isMacro := macroFor(macros, markerText); isMacro {
+				continue
+			}
+			name, anonName, _ := splitMarker(markerText)
+			if !hasMarkerPrefix(anonName, prefixes) || hasMarkerPrefix(anonName, ExternalMarkerPrefixes) {
+				continue
+			}
+			if c.Registry.Lookup(markerText, target) != nil {
+				continue
+			}
+
+			unknown := UnknownMarker{
+				Marker: markerText,
+				Pos:    markerRaw.Pos(),
+				Node:   node,
+				Target: target,
+			}
+			for _, otherTarget := range targetTypes {
+				if otherTarget != target && c.Registry.Lookup(markerText, otherTarget) != nil {
+					unknown.DefinedFor = append(unknown.DefinedFor, otherTarget)
+				}
+			}
+			if !unknown.Misplaced() {
+				if defNames == nil {
+					defNames = c.definitionNames()
+				}
+				unknown.Suggestions = suggestionsFor(defNames, name, anonName)
+			}
+			res = append(res, unknown)
+		}
+	}
+
+	slices.SortFunc(res, func(a, b UnknownMarker) int {
+		return cmp.Compare(a.Pos, b.Pos)
+	})
+	return res
+}
+
+// definitionNames returns the unique names of all the definitions in the
+// registry, sorted.
+func (c *Collector) definitionNames() []string {
+	var names []string
+	for _, def := range c.Registry.AllDefinitions() {
+		names = append(names, def.Name)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// hasMarkerPrefix checks if the given marker name has one of the given
+// prefixes (see UnknownMarkerPrefixes).
+func hasMarkerPrefix(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if name == prefix || strings.HasPrefix(name, prefix+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestionsFor returns the given definition names that are close to the
+// given marker name (or the name if it's not a struct), by edit distance.
+func suggestionsFor(defNames []string, name, anonName string) []string {
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	var suggestions []suggestion
+	for _, defName := range defNames {
+		distance := min(editDistance(name, defName), editDistance(anonName, defName))
+		if distance <= max(2, len(defName)/10) {
+			suggestions = append(suggestions, suggestion{name: defName, distance: distance})
+		}
+	}
+	slices.SortStableFunc(suggestions, func(a, b suggestion) int {
+		return a.distance - b.distance
+	})
+
+	var res []string
+	for _, suggestion := range suggestions[:min(len(suggestions), maxSuggestions)] {
+		res = append(res, suggestion.name)
+	}
+	return res
+}
+
+// editDistance computes the Levenshtein distance between the given strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
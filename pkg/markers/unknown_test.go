@@ -0,0 +1,100 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markers_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	pkgstest "golang.org/x/tools/go/packages/packagestest"
+
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	testloader "sigs.k8s.io/controller-tools/pkg/loader/testutils"
+	. "sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+var _ = Describe("Finding unknown markers", Ordered, func() {
+	var pkg *loader.Package
+
+	BeforeAll(func() {
+		modules := []pkgstest.Module{
+			{
+				Name: "sigs.k8s.io/controller-tools/pkg/markers/unknown",
+				Files: map[string]any{
+					"file.go": `
+						// +testing:pkglvl
+						// +testing:pkglv
+						package unknown
+
+						// +testing:typelvl
+						// +testing:fieldlvl
+						// +other:typelvl
+						// +k8s:openapi-gen=true
+						type Foo struct {
+							// +testing:fieldlvl
+							// +testing:feildlvl=3
+							A string
+						}
+					`,
+				},
+			},
+		}
+		pkgs, exported, err := testloader.LoadFakeRoots(pkgstest.Modules, modules, "sigs.k8s.io/controller-tools/pkg/markers/unknown")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(exported.Cleanup)
+		Expect(pkgs).To(HaveLen(1))
+		pkg = pkgs[0]
+	})
+
+	It("should report misspelled and misplaced markers with the given prefixes", func() {
+		reg := &Registry{}
+		mustDefine(reg, "testing:pkglvl", DescribesPackage, struct{}{})
+		mustDefine(reg, "testing:typelvl", DescribesType, struct{}{})
+		mustDefine(reg, "testing:fieldlvl", DescribesField, 0)
+		col := &Collector{Registry: reg}
+
+		unknown := col.UnknownMarkers(pkg, []string{"testing", "k8s"})
+		Expect(unknown).To(HaveExactElements(
+			MatchFields(IgnoreExtras, Fields{
+				"Marker":      Equal("+testing:pkglv"),
+				"Target":      Equal(DescribesPackage),
+				"DefinedFor":  BeEmpty(),
+				"Suggestions": Equal([]string{"testing:pkglvl"}),
+			}),
+			MatchFields(IgnoreExtras, Fields{
+				"Marker":      Equal("+testing:fieldlvl"),
+				"Target":      Equal(DescribesType),
+				"DefinedFor":  Equal([]TargetType{DescribesField}),
+				"Suggestions": BeEmpty(),
+			}),
+			MatchFields(IgnoreExtras, Fields{
+				"Marker":      Equal("+testing:feildlvl=3"),
+				"Target":      Equal(DescribesField),
+				"Suggestions": Equal([]string{"testing:fieldlvl"}),
+			}),
+		))
+
+		Expect(pkg.FileSet().Position(unknown[0].Pos).Line).To(Equal(3))
+		Expect(unknown[0].Message()).To(Equal(`unknown marker "+testing:pkglv" (did you mean +testing:pkglvl?)`))
+		Expect(unknown[1].Message()).To(Equal(`marker "+testing:fieldlvl" is not valid on a type (only on a field)`))
+	})
+
+	It("should not report markers without the given prefixes", func() {
+		col := &Collector{Registry: &Registry{}}
+		Expect(col.UnknownMarkers(pkg, []string{"other:thing", "test"})).To(BeEmpty())
+	})
+})
@@ -19,6 +19,7 @@ package markers
 import (
 	"go/ast"
 	"go/token"
+	"go/types"
 	"reflect"
 	"strings"
 
@@ -43,6 +44,14 @@ func extractDoc(node ast.Node, decl *ast.GenDecl) string {
 		if docs == nil && decl.Lparen == token.NoPos {
 			docs = decl.Doc
 		}
+	case *ast.ValueSpec:
+		docs = docced.Doc
+		// likewise for single-line const decls
+		if docs == nil && decl.Lparen == token.NoPos {
+			docs = decl.Doc
+		}
+	case *ast.FuncDecl:
+		docs = docced.Doc
 	}
 
 	if docs == nil {
@@ -52,8 +61,11 @@ func extractDoc(node ast.Node, decl *ast.GenDecl) string {
 	// filter out markers
 	var outGroup ast.CommentGroup
 	outGroup.List = make([]*ast.Comment, 0, len(docs.List))
-	for _, comment := range docs.List {
+	for i := 0; i < len(docs.List); i++ {
+		comment := docs.List[i]
 		if isMarkerComment(comment.Text) {
+			// skip the lines that the marker is continued onto, too
+			i += len(markerContinuations(docs.List, i))
 			continue
 		}
 		outGroup.List = append(outGroup.List, comment)
@@ -232,3 +244,159 @@ func EachType(col *Collector, pkg *loader.Package, cb TypeCallback) error {
 
 	return nil
 }
+
+// FuncInfo contains marker values and commonly used information for a
+// function or method declaration.
+type FuncInfo struct {
+	// Name is the name of the function or method.
+	Name string
+	// Receiver is the name of the receiver's type for methods (without any
+	// pointer or type parameters), or "" for functions.
+	Receiver string
+	// Doc is the Godoc of the function, pre-processed to remove markers and joine
+	// single newlines together.
+	Doc string
+
+	// Markers are all registered markers associated with the function.
+	Markers MarkerValues
+
+	// RawDecl contains the raw FuncDecl that declared this function.
+	RawDecl *ast.FuncDecl
+	// RawFile contains the file in which this function was declared.
+	RawFile *ast.File
+}
+
+// FuncCallback is a callback called for each function or method declaration
+// in a package.
+type FuncCallback func(info *FuncInfo)
+
+// EachFunc collects all markers, then calls the given callback for each
+// function and method declaration in a package, in the order they're
+// declared.
+func EachFunc(col *Collector, pkg *loader.Package, cb FuncCallback) error {
+	markers, err := col.MarkersInPackage(pkg)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			funcDecl, isFunc := decl.(*ast.FuncDecl)
+			if !isFunc {
+				continue
+			}
+			cb(&FuncInfo{
+				Name:     funcDecl.Name.Name,
+				Receiver: receiverName(funcDecl),
+				Doc:      extractDoc(funcDecl, nil),
+				Markers:  markers[funcDecl],
+				RawDecl:  funcDecl,
+				RawFile:  file,
+			})
+		}
+	}
+
+	return nil
+}
+
+// receiverName returns the name of the receiver's type for the given method,
+// or "" if it's a function.
+func receiverName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return ""
+	}
+	typ := decl.Recv.List[0].Type
+	for {
+		switch expr := typ.(type) {
+		case *ast.StarExpr:
+			typ = expr.X
+		case *ast.IndexExpr:
+			typ = expr.X
+		case *ast.IndexListExpr:
+			typ = expr.X
+		case *ast.Ident:
+			return expr.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// ConstInfo contains marker values and commonly used information for a
+// constant declaration.
+type ConstInfo struct {
+	// Name is the name of the constant.
+	Name string
+	// Type is the type of the constant as written (including types
+	// implied by an earlier spec in the same block, as with iota), or ""
+	// for untyped constants.
+	Type string
+	// Doc is the Godoc of the constant, pre-processed to remove markers and joine
+	// single newlines together.
+	Doc string
+
+	// Markers are all registered markers associated with the constant.
+	Markers MarkerValues
+
+	// RawDecl contains the raw GenDecl that the constant was declared as part of.
+	RawDecl *ast.GenDecl
+	// RawSpec contains the raw Spec that declared this constant.
+	RawSpec *ast.ValueSpec
+	// RawFile contains the file in which this constant was declared.
+	RawFile *ast.File
+}
+
+// ConstCallback is a callback called for each constant declaration in a
+// package.
+type ConstCallback func(info *ConstInfo)
+
+// EachConst collects all markers, then calls the given callback for each
+// package-level constant in a package, in the order they're declared.
+// Each name is considered separate, so
+//
+//	const (
+//	    Foo, Bar = 1, 2
+//	    Baz      = 3
+//	)
+//
+// yields three calls to the callback (with the same markers for Foo and Bar).
+func EachConst(col *Collector, pkg *loader.Package, cb ConstCallback) error {
+	markers, err := col.MarkersInPackage(pkg)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			genDecl, isGenDecl := decl.(*ast.GenDecl)
+			if !isGenDecl || genDecl.Tok != token.CONST {
+				continue
+			}
+			// specs without a type or values repeat the previous ones
+			var typ ast.Expr
+			for _, spec := range genDecl.Specs {
+				valueSpec := spec.(*ast.ValueSpec)
+				if valueSpec.Type != nil || len(valueSpec.Values) > 0 {
+					typ = valueSpec.Type
+				}
+				typeName := ""
+				if typ != nil {
+					typeName = types.ExprString(typ)
+				}
+				for _, name := range valueSpec.Names {
+					cb(&ConstInfo{
+						Name:    name.Name,
+						Type:    typeName,
+						Doc:     extractDoc(valueSpec, genDecl),
+						Markers: markers[valueSpec],
+						RawDecl: genDecl,
+						RawSpec: valueSpec,
+						RawFile: file,
+					})
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package plugin runs generators implemented by external executables.
+//
+// This lets custom generators live outside of controller-tools, while still
+// reusing its package loading, marker collection, and output rules.
+//
+// # Protocol
+//
+// A plugin is an executable (conventionally named controller-gen-<name>)
+// that reads a single JSON Request from its standard input, and writes a
+// single JSON Response to its standard output.  Anything written to standard
+// error is passed through to the user.
+//
+// A "describe" request asks the plugin for its Description: the options
+// that the generator itself takes, and the markers that it wants collected.
+// Plugins are described once when they're registered.
+//
+// A "generate" request carries the generator's options, along with the
+// collected marker values and basic type information for each root package.
+// The plugin responds with artifacts, which are written using the normal
+// output rule for the generator, and errors, which may carry positions in
+// the source files that were passed to it.
+//
+// Marker and option arguments are described with simple Go-style type
+// strings (like "string", "int", "bool", "[]string", or "map[string]any"),
+// and are passed to the plugin as JSON, keyed by argument name.
+//
+// # Writing plugins
+//
+// Serve implements the plugin side of the protocol, given a Description and
+// a function that handles generate requests.
+//
+// # Discovery
+//
+// Find finds the plugin for a single generator on the PATH, while Discover
+// finds all of them.  Register registers a plugin's generator as an option in
+// an options registry (as used by genall.FromOptions), failing if a generator
+// of the same name is already registered.
+//
+// Finding and registering a plugin runs it (to describe it), so callers
+// should only do so for generators that are actually asked for.  Executables
+// named like versions (e.g. controller-gen-v0.16.0, as installed by some
+// tools to pin controller-gen itself) are never considered plugins.
+package plugin
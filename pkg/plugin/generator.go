@@ -0,0 +1,365 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sync"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+// registration holds everything we learned about a plugin when registering it.
+type registration struct {
+	plugin *Plugin
+	// options parses the generator's options (into a dynamically constructed struct)
+	options *markers.Definition
+	markers []*markers.Definition
+	help    []*markers.DefinitionHelp
+}
+
+// registered holds the registered plugins, by generator name.  Generators
+// are parsed from options by the marker machinery, which always starts from
+// a zero value, so this is how a parsed Generator finds its plugin again.
+var registered = struct {
+	sync.RWMutex
+	byName map[string]*registration
+}{byName: make(map[string]*registration)}
+
+// lookup returns the registration for the given generator name.
+func lookup(name string) (*registration, error) {
+	registered.RLock()
+	defer registered.RUnlock()
+	reg, known := registered.byName[name]
+	if !known {
+		return nil, fmt.Errorf("unknown plugin %q", name)
+	}
+	return reg, nil
+}
+
+// Register describes the given plugin, and registers an option for its
+// generator (named after the plugin) in the given options registry.  The
+// returned definition is the one that was registered.
+func Register(optionsRegistry *markers.Registry, p *Plugin) (*markers.Definition, error) {
+	if !validName(p.Name) {
+		return nil, fmt.Errorf("invalid plugin name %q", p.Name)
+	}
+	// the registry silently replaces existing definitions, so check that we
+	// wouldn't replace another generator (e.g. a plugin from the PATH with
+	// one from a config file).
+	if existing := optionsRegistry.Lookup("+"+p.Name, markers.DescribesPackage); existing != nil && existing.Name == p.Name {
+		return nil, fmt.Errorf("plugin %s (%s): a generator named %q is already registered", p.Name, p.Path, p.Name)
+	}
+	desc, err := p.Describe()
+	if err != nil {
+		return nil, err
+	}
+
+	reg := &registration{plugin: p}
+	optsType, optsHelp, err := structFromArguments(desc.Options)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: invalid options: %w", p.Name, err)
+	}
+	if reg.options, err = markers.MakeDefinition(p.Name, markers.DescribesPackage, reflect.Zero(optsType).Interface()); err != nil {
+		return nil, fmt.Errorf("plugin %s: invalid options: %w", p.Name, err)
+	}
+	for _, spec := range desc.Markers {
+		defn, help, err := definitionFor(p.Name, spec)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", p.Name, err)
+		}
+		reg.markers = append(reg.markers, defn)
+		reg.help = append(reg.help, help)
+	}
+
+	// the option parses into a Generator (which then uses the options
+	// definition above), but advertises the plugin's options for help.
+	defn := &markers.Definition{
+		Output:     reflect.TypeOf(Generator{}),
+		Name:       p.Name,
+		Target:     markers.DescribesPackage,
+		Fields:     reg.options.Fields,
+		FieldNames: reg.options.FieldNames,
+		Strict:     true,
+	}
+	if err := optionsRegistry.Register(defn); err != nil {
+		return nil, err
+	}
+	optionsRegistry.AddHelp(defn, &markers.DefinitionHelp{
+		DetailedHelp: markers.DetailedHelp{Summary: desc.Help},
+		FieldHelp:    optsHelp,
+	})
+
+	registered.Lock()
+	defer registered.Unlock()
+	registered.byName[p.Name] = reg
+	return defn, nil
+}
+
+// Generator runs a plugin registered with Register.  It's produced by
+// parsing the plugin's option, and isn't useful to construct directly.
+type Generator struct {
+	name    string
+	options map[string]json.RawMessage
+}
+
+var _ genall.Generator = Generator{}
+var _ genall.NeedsTypeChecking = Generator{}
+
+// ParseMarker implements the custom marker parsing hook, parsing the
+// plugin's options with the definition constructed from its Description.
+func (g *Generator) ParseMarker(name string, anonymousName string, restFields string) error {
+	reg, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	raw := "+" + anonymousName
+	if restFields != "" {
+		raw += "=" + restFields
+	}
+	opts, err := reg.options.Parse(raw)
+	if err != nil {
+		return err
+	}
+	g.name = name
+	return convertJSON(opts, &g.options)
+}
+
+// CheckFilter implements genall.NeedsTypeChecking.  Plugins get type
+// information for every field in the roots, so everything is interesting.
+func (Generator) CheckFilter() loader.NodeFilter {
+	return func(ast.Node) bool { return true }
+}
+
+// RegisterMarkers registers the markers described by the plugin.
+func (g Generator) RegisterMarkers(into *markers.Registry) error {
+	reg, err := lookup(g.name)
+	if err != nil {
+		return err
+	}
+	for i, defn := range reg.markers {
+		if err := into.Register(defn); err != nil {
+			return err
+		}
+		into.AddHelp(defn, reg.help[i])
+	}
+	return nil
+}
+
+// Generate sends the collected markers and type information for the roots
+// to the plugin, writes out the artifacts it produces, and reports its
+// errors.
+func (g Generator) Generate(ctx *genall.GenerationContext) error {
+	reg, err := lookup(g.name)
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]struct{}, len(reg.markers))
+	for _, defn := range reg.markers {
+		wanted[defn.Name] = struct{}{}
+	}
+
+	req := &GenerateRequest{Options: g.options}
+	rootsByID := make(map[string]*loader.Package, len(ctx.Roots))
+	for _, root := range ctx.Roots {
+		rootsByID[root.ID] = root
+		pkg, err := describePackage(ctx, root, wanted)
+		if err != nil {
+			root.AddError(err)
+			continue
+		}
+		req.Packages = append(req.Packages, *pkg)
+	}
+
+	resp, err := reg.plugin.Generate(req)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, pluginErr := range resp.Errors {
+		if root, pos := findPos(ctx.Roots, pluginErr.Position); root != nil {
+			root.AddError(loader.ErrFromNode(errors.New(pluginErr.Message), pos))
+			continue
+		}
+		if pluginErr.Position != nil && pluginErr.Position.Filename != "" {
+			errs = append(errs, fmt.Errorf("%s:%d:%d: %s", pluginErr.Position.Filename, pluginErr.Position.Line, pluginErr.Position.Column, pluginErr.Message))
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s: %s", g.name, pluginErr.Message))
+	}
+
+	for _, artifact := range resp.Artifacts {
+		var pkg *loader.Package
+		if artifact.Package != "" {
+			var known bool
+			if pkg, known = rootsByID[artifact.Package]; !known {
+				errs = append(errs, fmt.Errorf("%s: artifact %s refers to unknown package %q", g.name, artifact.Path, artifact.Package))
+				continue
+			}
+		}
+		if err := writeArtifact(ctx, pkg, artifact); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return loader.MaybeErrList(errs)
+}
+
+// writeArtifact writes a single artifact using the context's output rule.
+func writeArtifact(ctx *genall.GenerationContext, pkg *loader.Package, artifact Artifact) error {
+	out, err := ctx.Open(pkg, artifact.Path)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write([]byte(artifact.Contents)); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// describePackage collects the markers and type information for the given
+// root package, keeping only the markers in wanted.
+func describePackage(ctx *genall.GenerationContext, root *loader.Package, wanted map[string]struct{}) (*Package, error) {
+	ctx.Checker.Check(root)
+	root.NeedTypesInfo()
+
+	pkgMarkers, err := markers.PackageMarkers(ctx.Collector, root)
+	if err != nil {
+		return nil, err
+	}
+	pkg := &Package{
+		ID:      root.ID,
+		Name:    root.Name,
+		PkgPath: root.PkgPath,
+	}
+	if pkg.Markers, err = markersToJSON(pkgMarkers, wanted); err != nil {
+		return nil, err
+	}
+
+	qualifier := types.RelativeTo(root.Types)
+	err = markers.EachType(ctx.Collector, root, func(info *markers.TypeInfo) {
+		typ := Type{
+			Name:     info.Name,
+			Doc:      info.Doc,
+			Position: position(root.Fset, info.RawSpec.Pos()),
+		}
+		if obj := root.Types.Scope().Lookup(info.Name); obj != nil {
+			if _, isStruct := obj.Type().Underlying().(*types.Struct); isStruct {
+				typ.Underlying = "struct{...}"
+			} else {
+				typ.Underlying = types.TypeString(obj.Type().Underlying(), qualifier)
+			}
+		}
+		var err error
+		if typ.Markers, err = markersToJSON(info.Markers, wanted); err != nil {
+			root.AddError(loader.ErrFromNode(err, info.RawSpec))
+		}
+		for _, fieldInfo := range info.Fields {
+			field := Field{
+				Name:     fieldInfo.Name,
+				Doc:      fieldInfo.Doc,
+				Tag:      string(fieldInfo.Tag),
+				Position: position(root.Fset, fieldInfo.RawField.Pos()),
+			}
+			if fieldType := root.TypesInfo.TypeOf(fieldInfo.RawField.Type); fieldType != nil {
+				field.Type = types.TypeString(fieldType, qualifier)
+			}
+			if field.Markers, err = markersToJSON(fieldInfo.Markers, wanted); err != nil {
+				root.AddError(loader.ErrFromNode(err, fieldInfo.RawField))
+			}
+			typ.Fields = append(typ.Fields, field)
+		}
+		pkg.Types = append(pkg.Types, typ)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// markersToJSON converts the wanted markers in the given values to JSON.
+func markersToJSON(values markers.MarkerValues, wanted map[string]struct{}) (Markers, error) {
+	var res Markers
+	for name, vals := range values {
+		if _, isWanted := wanted[name]; !isWanted {
+			continue
+		}
+		if res == nil {
+			res = make(Markers)
+		}
+		for _, val := range vals {
+			data, err := json.Marshal(val)
+			if err != nil {
+				return nil, fmt.Errorf("unable to serialize marker %q: %w", name, err)
+			}
+			res[name] = append(res[name], data)
+		}
+	}
+	return res, nil
+}
+
+// convertJSON converts in to out by way of its JSON representation.
+func convertJSON(in any, out any) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// position converts a token.Pos into a Position.
+func position(fset *token.FileSet, pos token.Pos) Position {
+	p := fset.Position(pos)
+	return Position{Filename: p.Filename, Line: p.Line, Column: p.Column}
+}
+
+// posNode adapts a token.Pos to loader.Node.
+type posNode token.Pos
+
+func (n posNode) Pos() token.Pos { return token.Pos(n) }
+
+// findPos finds the root containing the file for the given position, and
+// the corresponding token.Pos.
+func findPos(roots []*loader.Package, pos *Position) (*loader.Package, posNode) {
+	if pos == nil || pos.Filename == "" || pos.Line < 1 {
+		return nil, 0
+	}
+	for _, root := range roots {
+		for _, file := range root.Syntax {
+			tokFile := root.Fset.File(file.Pos())
+			if tokFile == nil || tokFile.Name() != pos.Filename || pos.Line > tokFile.LineCount() {
+				continue
+			}
+			tokPos := tokFile.LineStart(pos.Line)
+			if pos.Column > 1 {
+				tokPos = min(tokPos+token.Pos(pos.Column-1), token.Pos(tokFile.Base()+tokFile.Size()))
+			}
+			return root, posNode(tokPos)
+		}
+	}
+	return nil, 0
+}
@@ -0,0 +1,210 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// ExecutablePrefix is the prefix of the names of plugin executables found
+// by Discover.  The rest of the name is used as the generator name.
+const ExecutablePrefix = "controller-gen-"
+
+// Plugin is an external executable that implements a generator.
+type Plugin struct {
+	// Name is the name of the generator, as used on the command line.
+	Name string
+	// Path is the path to the executable.
+	Path string
+	// Dir is the working directory to run the executable in, against which
+	// relative paths in the generator's options are resolved.  Defaults to
+	// the current working directory.
+	Dir string
+	// Stderr receives anything the plugin writes to its standard error.
+	// Defaults to os.Stderr.
+	Stderr io.Writer
+}
+
+// Describe asks the plugin to describe its generator.
+func (p *Plugin) Describe() (*Description, error) {
+	resp, err := p.call(&Request{Command: CommandDescribe})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Describe == nil {
+		return nil, fmt.Errorf("plugin %s did not describe itself", p.Name)
+	}
+	return resp.Describe, nil
+}
+
+// Generate asks the plugin to generate artifacts.
+func (p *Plugin) Generate(req *GenerateRequest) (*GenerateResponse, error) {
+	resp, err := p.call(&Request{Command: CommandGenerate, Generate: req})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Generate == nil {
+		return &GenerateResponse{}, nil
+	}
+	return resp.Generate, nil
+}
+
+// call runs the plugin executable once, passing it the given request and
+// returning its response.
+func (p *Plugin) call(req *Request) (*Response, error) {
+	req.Version = ProtocolVersion
+	reqData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(p.Path)
+	cmd.Dir = p.Dir
+	cmd.Stdin = bytes.NewReader(reqData)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = p.Stderr
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("unable to run plugin %s (%s): %w", p.Name, p.Path, err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("invalid response from plugin %s: %w", p.Name, err)
+	}
+	if resp.Version != ProtocolVersion {
+		return nil, fmt.Errorf("plugin %s uses protocol version %q, but %q is required", p.Name, resp.Version, ProtocolVersion)
+	}
+	return &resp, nil
+}
+
+// Discover finds plugin executables named with ExecutablePrefix in the
+// directories of the given PATH-style list.  As with exec.LookPath,
+// executables in earlier directories shadow those with the same name in
+// later ones.  The returned plugins are sorted by name.
+func Discover(pathList string) []*Plugin {
+	var plugins []*Plugin
+	seen := make(map[string]struct{})
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" {
+			// the empty entry means the current directory, which we don't
+			// want to implicitly run things from.
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, isPlugin := pluginName(entry.Name())
+			if !isPlugin {
+				continue
+			}
+			if _, alreadySeen := seen[name]; alreadySeen {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			seen[name] = struct{}{}
+			plugins = append(plugins, &Plugin{Name: name, Path: path})
+		}
+	}
+	slices.SortFunc(plugins, func(a, b *Plugin) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return plugins
+}
+
+// Find finds the plugin executable for the generator with the given name in
+// the directories of the given PATH-style list, returning nil if there isn't
+// one.  As with exec.LookPath, the first directory containing an executable
+// with that name wins.  Unlike Discover, it only looks at executables that
+// would actually be used for the given generator, so it's suitable for
+// resolving plugins lazily, only once a generator isn't otherwise known.
+func Find(pathList string, name string) *Plugin {
+	if !validName(name) {
+		return nil
+	}
+	exe := ExecutablePrefix + name
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" {
+			// as for Discover, don't implicitly run things from the current
+			// directory.
+			continue
+		}
+		path := filepath.Join(dir, exe)
+		if isExecutable(path) {
+			return &Plugin{Name: name, Path: path}
+		}
+	}
+	return nil
+}
+
+// pluginName returns the generator name for the given executable file name,
+// if it's named like a plugin.
+func pluginName(fileName string) (string, bool) {
+	if runtime.GOOS == "windows" {
+		fileName = strings.TrimSuffix(fileName, ".exe")
+	}
+	name, isPlugin := strings.CutPrefix(fileName, ExecutablePrefix)
+	if !isPlugin || !validName(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// versionLike matches names that look like versions, like the v0.16.0 in
+// the controller-gen-v0.16.0 executables that tools like kubebuilder install
+// for pinning controller-gen itself.
+var versionLike = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)*(-[0-9A-Za-z.-]+)?$`)
+
+// validName checks that the given generator name can be used as an option
+// name on the command line, and doesn't look like a version (which would
+// indicate a copy of controller-gen itself, not a plugin).
+func validName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, ":=,+ \t") && !versionLike.MatchString(name)
+}
+
+// isExecutable checks if the given path is a regular, executable file.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.HasSuffix(path, ".exe")
+	}
+	return info.Mode().Perm()&0o111 != 0
+}
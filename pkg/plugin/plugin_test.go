@@ -0,0 +1,138 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/markers"
+	"sigs.k8s.io/controller-tools/pkg/plugin"
+)
+
+var _ = Describe("Plugins", func() {
+	var binDir string
+	var fakePlugin *plugin.Plugin
+
+	BeforeEach(func() {
+		binDir = GinkgoT().TempDir()
+		exe := plugin.ExecutablePrefix + "fake"
+		if runtime.GOOS == "windows" {
+			exe += ".exe"
+		}
+		build := exec.Command("go", "build", "-o", filepath.Join(binDir, exe), "./testdata/fakeplugin")
+		build.Stdout = GinkgoWriter
+		build.Stderr = GinkgoWriter
+		Expect(build.Run()).To(Succeed())
+		fakePlugin = &plugin.Plugin{Name: "fake", Path: filepath.Join(binDir, exe), Stderr: GinkgoWriter}
+	})
+
+	It("should discover plugins on the PATH", func() {
+		otherDir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(otherDir, plugin.ExecutablePrefix+"fake"), []byte("shadowed"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(otherDir, plugin.ExecutablePrefix+"notexec"), []byte("nope"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(otherDir, "unrelated"), []byte("nope"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(otherDir, plugin.ExecutablePrefix+"v0.16.0"), []byte("pinned controller-gen"), 0o755)).To(Succeed())
+
+		plugins := plugin.Discover(strings.Join([]string{binDir, otherDir}, string(filepath.ListSeparator)))
+		Expect(plugins).To(HaveLen(1))
+		Expect(plugins[0].Name).To(Equal("fake"))
+		Expect(plugins[0].Path).To(Equal(fakePlugin.Path))
+	})
+
+	It("should find a single plugin on the PATH, ignoring versioned controller-gen executables", func() {
+		otherDir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(otherDir, plugin.ExecutablePrefix+"v0.16.0"), []byte("pinned controller-gen"), 0o755)).To(Succeed())
+		pathList := strings.Join([]string{otherDir, binDir}, string(filepath.ListSeparator))
+
+		found := plugin.Find(pathList, "fake")
+		Expect(found).NotTo(BeNil())
+		Expect(found.Path).To(Equal(fakePlugin.Path))
+		Expect(plugin.Find(pathList, "other")).To(BeNil())
+		Expect(plugin.Find(pathList, "v0.16.0")).To(BeNil())
+	})
+
+	It("should describe the plugin's options and markers", func() {
+		desc, err := fakePlugin.Describe()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(desc.Options).To(HaveLen(1))
+		Expect(desc.Markers).To(HaveLen(4))
+	})
+
+	Context("when run as a generator", func() {
+		var optionsRegistry *markers.Registry
+		var outDir string
+
+		BeforeEach(func() {
+			optionsRegistry = &markers.Registry{}
+			Expect(genall.RegisterOptionsMarkers(optionsRegistry)).To(Succeed())
+			Expect(optionsRegistry.Register(markers.Must(markers.MakeDefinition("output:fake:dir", markers.DescribesPackage, genall.OutputToDirectory(""))))).To(Succeed())
+			defn, err := plugin.Register(optionsRegistry, fakePlugin)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(defn.Fields).To(HaveKey("prefix"))
+			outDir = GinkgoT().TempDir()
+		})
+
+		It("should write the artifacts and report errors at the right positions", func() {
+			rt, err := genall.FromOptions(optionsRegistry, []string{
+				"paths=./testdata/api",
+				`fake:prefix="gen_"`,
+				"output:fake:dir=" + outDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			rt.ErrorWriter = GinkgoWriter
+
+			By("checking that the positioned error was reported")
+			Expect(rt.Run()).To(BeTrue())
+			Expect(rt.Roots).To(HaveLen(1))
+			Expect(rt.Roots[0].Errors).To(HaveLen(1))
+			Expect(rt.Roots[0].Errors[0].Msg).To(Equal("invalid field: just because"))
+			Expect(rt.Roots[0].Errors[0].Pos).To(HaveSuffix(filepath.Join("testdata", "api", "types.go") + ":46:2"))
+
+			By("checking that the artifacts were written")
+			contents, err := os.ReadFile(filepath.Join(outDir, "gen_thing.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(`kind Thing (things) in things.example.com
+Name string
+Created time.Time
+Other []*Other
+`))
+			_, err = os.Stat(filepath.Join(outDir, "gen_broken.txt"))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should refuse to replace a generator that's already registered", func() {
+			_, err := plugin.Register(optionsRegistry, &plugin.Plugin{Name: "fake", Path: fakePlugin.Path, Stderr: GinkgoWriter})
+			Expect(err).To(MatchError(ContainSubstring(`a generator named "fake" is already registered`)))
+		})
+
+		It("should reject unknown options", func() {
+			_, err := genall.FromOptions(optionsRegistry, []string{
+				"paths=./testdata/api",
+				`fake:nope="gen_"`,
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
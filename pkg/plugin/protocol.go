@@ -0,0 +1,193 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import "encoding/json"
+
+// ProtocolVersion is the version of the plugin protocol implemented by this
+// package.  It's sent with every request, and must be echoed back in every
+// response.
+const ProtocolVersion = "v1alpha1"
+
+const (
+	// CommandDescribe asks the plugin for its Description.
+	CommandDescribe = "describe"
+	// CommandGenerate asks the plugin to generate artifacts.
+	CommandGenerate = "generate"
+)
+
+// Request is sent to a plugin on its standard input.
+type Request struct {
+	// Version is the protocol version (ProtocolVersion).
+	Version string `json:"version"`
+	// Command is the command to run (CommandDescribe or CommandGenerate).
+	Command string `json:"command"`
+	// Generate contains the details of a CommandGenerate request.
+	Generate *GenerateRequest `json:"generate,omitempty"`
+}
+
+// Response is read from a plugin's standard output.
+type Response struct {
+	// Version is the protocol version (ProtocolVersion).
+	Version string `json:"version"`
+	// Describe contains the response to a CommandDescribe request.
+	Describe *Description `json:"describe,omitempty"`
+	// Generate contains the response to a CommandGenerate request.
+	Generate *GenerateResponse `json:"generate,omitempty"`
+}
+
+// Description describes a plugin's generator and the markers it uses.
+type Description struct {
+	// Help is a summary of what the generator does, shown in the options help.
+	Help string `json:"help,omitempty"`
+	// Options are the arguments that the generator itself takes on the
+	// command line (as in `<name>:<option>=<value>`).
+	Options []ArgumentSpec `json:"options,omitempty"`
+	// Markers are the markers that the plugin wants collected.
+	Markers []MarkerSpec `json:"markers,omitempty"`
+}
+
+// MarkerSpec describes a marker that a plugin uses.
+type MarkerSpec struct {
+	// Name is the name of the marker, without the leading `+`.
+	Name string `json:"name"`
+	// Target is the kind of node the marker applies to: "package", "type",
+	// or "field".
+	Target string `json:"target"`
+	// Help is a summary of what the marker does.
+	Help string `json:"help,omitempty"`
+	// Type is the type of the marker's single, anonymous argument (as in
+	// `+name=value`).  Mutually exclusive with Fields.
+	Type string `json:"type,omitempty"`
+	// Fields are the named arguments of the marker (as in
+	// `+name:arg1=val1,arg2=val2`).  A marker with neither Type nor Fields
+	// takes no arguments.
+	Fields []ArgumentSpec `json:"fields,omitempty"`
+}
+
+// ArgumentSpec describes a single named argument to a marker or option.
+type ArgumentSpec struct {
+	// Name is the name of the argument.
+	Name string `json:"name"`
+	// Type is the type of the argument.
+	Type string `json:"type"`
+	// Optional indicates that the argument may be omitted.
+	Optional bool `json:"optional,omitempty"`
+	// Help is a summary of what the argument does.
+	Help string `json:"help,omitempty"`
+}
+
+// GenerateRequest asks a plugin to generate artifacts.
+type GenerateRequest struct {
+	// Options are the generator options from the command line, by name.
+	Options map[string]json.RawMessage `json:"options,omitempty"`
+	// Packages are the root packages to generate for.
+	Packages []Package `json:"packages"`
+}
+
+// Markers are the values of the markers on a node, by marker name.  Each
+// marker may appear multiple times.  Values of markers with named fields are
+// objects keyed by field name.
+type Markers map[string][]json.RawMessage
+
+// Package describes a root package.
+type Package struct {
+	// ID is the package's ID, used to refer to the package in artifacts.
+	ID string `json:"id"`
+	// Name is the package's name.
+	Name string `json:"name"`
+	// PkgPath is the package's import path.
+	PkgPath string `json:"pkgPath"`
+	// Markers are the package-level markers.
+	Markers Markers `json:"markers,omitempty"`
+	// Types are the types declared in the package.
+	Types []Type `json:"types,omitempty"`
+}
+
+// Type describes a type declared in a root package.
+type Type struct {
+	// Name is the name of the type.
+	Name string `json:"name"`
+	// Doc is the type's Godoc, with markers removed.
+	Doc string `json:"doc,omitempty"`
+	// Underlying is the underlying Go type, formatted relative to the
+	// package (e.g. "struct{...}", "string", or "[]metav1.Time").
+	// Struct types are abbreviated, since their fields are listed separately.
+	Underlying string `json:"underlying,omitempty"`
+	// Position is the position of the type declaration.
+	Position Position `json:"position"`
+	// Markers are the markers on the type.
+	Markers Markers `json:"markers,omitempty"`
+	// Fields are the fields of the type, if it's a struct.
+	Fields []Field `json:"fields,omitempty"`
+}
+
+// Field describes a field of a struct type.
+type Field struct {
+	// Name is the name of the field (or "" for embedded fields).
+	Name string `json:"name"`
+	// Doc is the field's Godoc, with markers removed.
+	Doc string `json:"doc,omitempty"`
+	// Tag is the field's raw struct tag.
+	Tag string `json:"tag,omitempty"`
+	// Type is the Go type of the field, formatted relative to the package.
+	Type string `json:"type,omitempty"`
+	// Position is the position of the field.
+	Position Position `json:"position"`
+	// Markers are the markers on the field.
+	Markers Markers `json:"markers,omitempty"`
+}
+
+// Position is a position in a source file.
+type Position struct {
+	// Filename is the absolute path to the file.
+	Filename string `json:"filename,omitempty"`
+	// Line is the 1-based line number.
+	Line int `json:"line,omitempty"`
+	// Column is the 1-based column number (in bytes).
+	Column int `json:"column,omitempty"`
+}
+
+// GenerateResponse contains the results of generation.
+type GenerateResponse struct {
+	// Artifacts are the generated artifacts.
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+	// Errors are the errors encountered while generating.
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Artifact is a single generated file.
+type Artifact struct {
+	// Package is the ID of the package the artifact belongs to, or empty for
+	// artifacts that don't belong to any particular package (like most
+	// config files).  This is passed on to the output rule.
+	Package string `json:"package,omitempty"`
+	// Path is the path of the artifact, relative to wherever the output rule
+	// puts it.
+	Path string `json:"path"`
+	// Contents are the contents of the artifact.
+	Contents string `json:"contents"`
+}
+
+// Error is an error encountered by a plugin while generating.
+type Error struct {
+	// Message describes the error.
+	Message string `json:"message"`
+	// Position is the (optional) position in a source file that the error
+	// refers to.
+	Position *Position `json:"position,omitempty"`
+}
@@ -0,0 +1,71 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// GenerateFunc handles a generate request on the plugin side.  Errors
+// returned from it are reported without a position; errors with positions
+// should be returned in the response instead.
+type GenerateFunc func(req *GenerateRequest) (*GenerateResponse, error)
+
+// Serve implements the plugin side of the protocol, reading a single request
+// from standard input and writing the response to standard output.  It's
+// intended to be called from a plugin's main function, and exits the
+// process with a non-zero status if the request can't be handled.
+func Serve(desc Description, generate GenerateFunc) {
+	if err := ServeIO(os.Stdin, os.Stdout, desc, generate); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// ServeIO is like Serve, but reads the request from in and writes the
+// response to out.
+func ServeIO(in io.Reader, out io.Writer, desc Description, generate GenerateFunc) error {
+	var req Request
+	if err := json.NewDecoder(in).Decode(&req); err != nil {
+		return fmt.Errorf("unable to read request: %w", err)
+	}
+	if req.Version != ProtocolVersion {
+		return fmt.Errorf("unsupported protocol version %q (expected %q)", req.Version, ProtocolVersion)
+	}
+
+	resp := Response{Version: ProtocolVersion}
+	switch req.Command {
+	case CommandDescribe:
+		resp.Describe = &desc
+	case CommandGenerate:
+		if req.Generate == nil {
+			return fmt.Errorf("generate request has no contents")
+		}
+		genResp, err := generate(req.Generate)
+		if err != nil {
+			genResp = &GenerateResponse{Errors: []Error{{Message: err.Error()}}}
+		}
+		resp.Generate = genResp
+	default:
+		return fmt.Errorf("unknown command %q", req.Command)
+	}
+
+	return json.NewEncoder(out).Encode(&resp)
+}
@@ -0,0 +1,47 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package api contains types for testing plugins.
+// +fake:group=things.example.com
+package api
+
+import "time"
+
+// Thing is a thing.
+// +fake:kind:name=Thing,plural=things
+type Thing struct {
+	// Name is the name.
+	Name string `json:"name"`
+	// Created is when it was created.
+	Created time.Time `json:"created"`
+	// Other refers to other things.
+	Other []*Other `json:"other,omitempty"`
+
+	// +fake:skip
+	Internal string `json:"-"`
+}
+
+// Other is another thing.
+type Other struct {
+	Count int `json:"count"`
+}
+
+// Broken is broken.
+// +fake:kind:name=Broken
+type Broken struct {
+	// +fake:invalid="just because"
+	Bad string `json:"bad"`
+}
@@ -0,0 +1,102 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// fakeplugin is a plugin used to test the plugin package.  It writes a
+// file for each type with a `+fake:kind` marker, listing the type's fields.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/controller-tools/pkg/plugin"
+)
+
+type kind struct {
+	Name   string `json:"name"`
+	Plural string `json:"plural,omitempty"`
+}
+
+func main() {
+	plugin.Serve(plugin.Description{
+		Help: "lists the fields of kinds",
+		Options: []plugin.ArgumentSpec{
+			{Name: "prefix", Type: "string", Optional: true, Help: "prefixes each file name"},
+		},
+		Markers: []plugin.MarkerSpec{
+			{Name: "fake:kind", Target: "type", Fields: []plugin.ArgumentSpec{
+				{Name: "name", Type: "string"},
+				{Name: "plural", Type: "string", Optional: true},
+			}},
+			{Name: "fake:skip", Target: "field"},
+			{Name: "fake:invalid", Target: "field", Type: "string"},
+			{Name: "fake:group", Target: "package", Type: "string"},
+		},
+	}, generate)
+}
+
+func generate(req *plugin.GenerateRequest) (*plugin.GenerateResponse, error) {
+	var prefix string
+	if raw, ok := req.Options["prefix"]; ok {
+		if err := json.Unmarshal(raw, &prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &plugin.GenerateResponse{}
+	for _, pkg := range req.Packages {
+		var group string
+		if raw := pkg.Markers["fake:group"]; len(raw) > 0 {
+			if err := json.Unmarshal(raw[0], &group); err != nil {
+				return nil, err
+			}
+		}
+		for _, typ := range pkg.Types {
+			rawKind := typ.Markers["fake:kind"]
+			if len(rawKind) == 0 {
+				continue
+			}
+			var k kind
+			if err := json.Unmarshal(rawKind[0], &k); err != nil {
+				return nil, err
+			}
+
+			var out strings.Builder
+			fmt.Fprintf(&out, "kind %s (%s) in %s\n", k.Name, k.Plural, group)
+			for _, field := range typ.Fields {
+				if len(field.Markers["fake:skip"]) > 0 {
+					continue
+				}
+				if raw := field.Markers["fake:invalid"]; len(raw) > 0 {
+					var reason string
+					if err := json.Unmarshal(raw[0], &reason); err != nil {
+						return nil, err
+					}
+					pos := field.Position
+					resp.Errors = append(resp.Errors, plugin.Error{Message: "invalid field: " + reason, Position: &pos})
+					continue
+				}
+				fmt.Fprintf(&out, "%s %s\n", field.Name, field.Type)
+			}
+			resp.Artifacts = append(resp.Artifacts, plugin.Artifact{
+				Path:     prefix + strings.ToLower(k.Name) + ".txt",
+				Contents: out.String(),
+			})
+		}
+	}
+	return resp, nil
+}
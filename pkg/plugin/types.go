@@ -0,0 +1,150 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"sigs.k8s.io/controller-tools/pkg/markers"
+)
+
+var anyType = reflect.TypeOf((*any)(nil)).Elem()
+
+// typeFromString converts a Go-style type string, as used in ArgumentSpec
+// and MarkerSpec, into the corresponding Go type.  Supported types are
+// string, int, float64, bool, any, and slices and string-keyed maps of those.
+func typeFromString(typ string) (reflect.Type, error) {
+	switch {
+	case typ == "string":
+		return reflect.TypeOf(""), nil
+	case typ == "int":
+		return reflect.TypeOf(0), nil
+	case typ == "float64":
+		return reflect.TypeOf(0.0), nil
+	case typ == "bool":
+		return reflect.TypeOf(false), nil
+	case typ == "any":
+		return anyType, nil
+	case strings.HasPrefix(typ, "[]"):
+		itemType, err := typeFromString(typ[2:])
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(itemType), nil
+	case strings.HasPrefix(typ, "map[string]"):
+		itemType, err := typeFromString(typ[len("map[string]"):])
+		if err != nil {
+			return nil, err
+		}
+		return reflect.MapOf(reflect.TypeOf(""), itemType), nil
+	default:
+		return nil, fmt.Errorf("unsupported argument type %q", typ)
+	}
+}
+
+// structFromArguments constructs a struct type with a field for each of the
+// given arguments, suitable for use as a marker definition's output type.
+// Fields are tagged so that the marker parser and encoding/json both use the
+// argument names.  Optional fields are pointers, so that they're omitted from
+// the JSON if not specified.
+//
+// It also returns the per-field help, in terms of the struct field names.
+func structFromArguments(args []ArgumentSpec) (reflect.Type, map[string]markers.DetailedHelp, error) {
+	fields := make([]reflect.StructField, len(args))
+	fieldHelp := make(map[string]markers.DetailedHelp, len(args))
+	for i, arg := range args {
+		if arg.Name == "" {
+			return nil, nil, fmt.Errorf("argument %d has no name", i)
+		}
+		argType, err := typeFromString(arg.Type)
+		if err != nil {
+			return nil, nil, fmt.Errorf("argument %q: %w", arg.Name, err)
+		}
+		markerTag := arg.Name
+		jsonTag := arg.Name
+		if arg.Optional {
+			markerTag += ",optional"
+			jsonTag += ",omitempty"
+			if argType.Kind() != reflect.Slice && argType.Kind() != reflect.Map && argType != anyType {
+				argType = reflect.PointerTo(argType)
+			}
+		}
+		fieldName := fmt.Sprintf("Arg%d", i)
+		fields[i] = reflect.StructField{
+			Name: fieldName,
+			Type: argType,
+			Tag:  reflect.StructTag(fmt.Sprintf(`marker:%q json:%q`, markerTag, jsonTag)),
+		}
+		fieldHelp[fieldName] = markers.DetailedHelp{Summary: arg.Help}
+	}
+	return reflect.StructOf(fields), fieldHelp, nil
+}
+
+// targetFromString converts a MarkerSpec target into a markers.TargetType.
+func targetFromString(target string) (markers.TargetType, error) {
+	switch target {
+	case "package":
+		return markers.DescribesPackage, nil
+	case "type":
+		return markers.DescribesType, nil
+	case "field":
+		return markers.DescribesField, nil
+	default:
+		return 0, fmt.Errorf("unknown marker target %q (must be package, type, or field)", target)
+	}
+}
+
+// definitionFor constructs a marker definition (and its help) for the given
+// spec.
+func definitionFor(category string, spec MarkerSpec) (*markers.Definition, *markers.DefinitionHelp, error) {
+	if spec.Name == "" {
+		return nil, nil, fmt.Errorf("marker has no name")
+	}
+	target, err := targetFromString(spec.Target)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marker %q: %w", spec.Name, err)
+	}
+	if spec.Type != "" && len(spec.Fields) > 0 {
+		return nil, nil, fmt.Errorf("marker %q: only one of type and fields may be specified", spec.Name)
+	}
+
+	help := &markers.DefinitionHelp{
+		Category:     category,
+		DetailedHelp: markers.DetailedHelp{Summary: spec.Help},
+	}
+	var output reflect.Type
+	if spec.Type != "" {
+		if output, err = typeFromString(spec.Type); err != nil {
+			return nil, nil, fmt.Errorf("marker %q: %w", spec.Name, err)
+		}
+		if output == anyType {
+			return nil, nil, fmt.Errorf("marker %q: markers may only take any-typed arguments as named fields", spec.Name)
+		}
+	} else {
+		if output, help.FieldHelp, err = structFromArguments(spec.Fields); err != nil {
+			return nil, nil, fmt.Errorf("marker %q: %w", spec.Name, err)
+		}
+	}
+
+	defn, err := markers.MakeDefinition(spec.Name, target, reflect.Zero(output).Interface())
+	if err != nil {
+		return nil, nil, fmt.Errorf("marker %q: %w", spec.Name, err)
+	}
+	return defn, help, nil
+}
@@ -29,10 +29,16 @@ import (
 
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-tools/pkg/genall"
+	"sigs.k8s.io/controller-tools/pkg/loader"
 	"sigs.k8s.io/controller-tools/pkg/markers"
 )
 
+// UnusedRuleCode is the code of warnings about RBAC markers that don't grant
+// anything, either at all, or beyond what the markers before them grant.
+const UnusedRuleCode = "unused-rbac-marker"
+
 var (
 	// RuleDefinition is a marker for defining RBAC rules.
 	// Call ToRule on the value to get a Kubernetes RBAC policy rule.
@@ -227,9 +233,11 @@ type Generator struct {
 	FileName string `marker:",optional"`
 
 	// HeaderFile specifies the header text (e.g. license) to prepend to generated files.
+	//
+	// See genall.Header for its format (including the template variables it may use).
 	HeaderFile string `marker:",optional"`
 
-	// Year specifies the year to substitute for " YEAR" in the header file.
+	// Year specifies the year to use in the header file (as {{ .Year }}, or in place of " YEAR").
 	Year string `marker:",optional"`
 }
 
@@ -251,12 +259,14 @@ func GenerateRoles(ctx *genall.GenerationContext, roleName string) ([]any, error
 		roleName  string
 	}
 	rulesByNSRole := make(map[nsRoleKey][]*Rule)
+	grantedVerbs := make(map[string]sets.Set[string])
 
 	for _, root := range ctx.Roots {
 		markerSet, err := markers.PackageMarkers(ctx.Collector, root)
 		if err != nil {
 			root.AddError(err)
 		}
+		warnUnusedRules(ctx, root, roleName, grantedVerbs)
 
 		// group RBAC markers by namespace and roleName, separate by resource
 		for _, markerValue := range markerSet[RuleDefinition.Name] {
@@ -447,6 +457,54 @@ func GenerateRoles(ctx *genall.GenerationContext, roleName string) ([]any, error
 	return objs, nil
 }
 
+// warnUnusedRules warns about the RBAC markers in the given package that don't
+// grant anything: ones without verbs, without resources or URLs, or whose
+// verbs have all been granted by earlier markers for the same resources in the
+// same role.  grantedVerbs tracks the verbs granted so far, by role and rule.
+func warnUnusedRules(ctx *genall.GenerationContext, pkg *loader.Package, roleName string, grantedVerbs map[string]sets.Set[string]) {
+	for _, marker := range ctx.Collector.SourceMarkers(pkg, RuleDefinition) {
+		rule := marker.Value.(Rule)
+		verbs := sets.New(rule.Verbs...)
+		verbs.Delete("")
+		if verbs.Len() == 0 {
+			ctx.Warn(pkg, marker.Comment, UnusedRuleCode, fmt.Sprintf("RBAC marker %q grants nothing, since it has no verbs", marker.Marker))
+			continue
+		}
+		if len(rule.Resources) == 0 && len(rule.URLs) == 0 {
+			ctx.Warn(pkg, marker.Comment, UnusedRuleCode, fmt.Sprintf("RBAC marker %q grants nothing, since it has neither resources nor urls", marker.Marker))
+			continue
+		}
+
+		scope := Rule{
+			Groups:        slices.Clone(rule.Groups),
+			Resources:     rule.Resources,
+			ResourceNames: rule.ResourceNames,
+			URLs:          rule.URLs,
+		}
+		for i, group := range scope.Groups {
+			if group == "core" {
+				scope.Groups[i] = ""
+			}
+		}
+		effectiveRoleName := rule.RoleName
+		if effectiveRoleName == "" {
+			effectiveRoleName = roleName
+		}
+		scopeKey := fmt.Sprintf("%s/%s: %s", rule.Namespace, effectiveRoleName, scope.key())
+
+		granted, seen := grantedVerbs[scopeKey]
+		if seen && (granted.Has("*") || granted.IsSuperset(verbs)) {
+			ctx.Warn(pkg, marker.Comment, UnusedRuleCode, fmt.Sprintf("RBAC marker %q grants nothing that earlier markers for the same resources don't already grant", marker.Marker))
+			continue
+		}
+		if !seen {
+			granted = sets.New[string]()
+			grantedVerbs[scopeKey] = granted
+		}
+		granted.Insert(verbs.UnsortedList()...)
+	}
+}
+
 func (g Generator) Generate(ctx *genall.GenerationContext) error {
 	objs, err := GenerateRoles(ctx, g.RoleName)
 	if err != nil {
@@ -457,15 +515,14 @@ func (g Generator) Generate(ctx *genall.GenerationContext) error {
 		return nil
 	}
 
-	var headerText string
-	if g.HeaderFile != "" {
-		headerBytes, err := ctx.ReadFile(g.HeaderFile)
-		if err != nil {
-			return err
-		}
-		headerText = string(headerBytes)
+	header, err := ctx.LoadHeader(g.HeaderFile, g.Year)
+	if err != nil {
+		return err
+	}
+	headerText, err := header.Render(genall.YAMLArtifact, nil)
+	if err != nil {
+		return err
 	}
-	headerText = strings.ReplaceAll(headerText, " YEAR", " "+g.Year)
 
 	fileName := "role.yaml"
 	if g.FileName != "" {
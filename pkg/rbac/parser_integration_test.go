@@ -84,3 +84,40 @@ var _ = Describe("ClusterRole generated by the RBAC Generator", func() {
 		})
 	}
 })
+
+var _ = Describe("RBAC Generator warnings", func() {
+	run := func(pkgPath string) []genall.Diagnostic {
+		By("loading the roots")
+		gens := genall.Generators{new(genall.Generator)}
+		*gens[0] = rbac.Generator{RoleName: "manager-role"}
+		rt, err := gens.ForRoots(pkgPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("running the generator")
+		rt.Diagnostics = &genall.Diagnostics{}
+		Expect(rt.Run()).To(BeFalse())
+		return rt.Diagnostics.Items()
+	}
+
+	It("should warn about markers that don't grant anything", func() {
+		Expect(run("./testdata/warnings")).To(ConsistOf(
+			And(
+				HaveField("Severity", genall.SeverityWarning),
+				HaveField("Code", rbac.UnusedRuleCode),
+				HaveField("Filename", HaveSuffix("controller.go")),
+				HaveField("Line", 21),
+				HaveField("Column", 1),
+				HaveField("Message", ContainSubstring("earlier markers")),
+			),
+			And(
+				HaveField("Code", rbac.UnusedRuleCode),
+				HaveField("Line", 23),
+				HaveField("Message", ContainSubstring("neither resources nor urls")),
+			),
+		))
+	})
+
+	It("should skip warnings suppressed with nolint", func() {
+		Expect(run("./testdata/warnings/nolint")).To(BeEmpty())
+	})
+})
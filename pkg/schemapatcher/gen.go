@@ -18,7 +18,6 @@ package schemapatcher
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 
 	"gopkg.in/yaml.v3"
@@ -334,7 +333,7 @@ func (e *partialCRD) setVersionedSchemata(newSchemata map[string]apiextensionsv1
 // minimally invasive.  Returned CRDs are mapped by group-kind.
 func crdsFromDirectory(ctx *genall.GenerationContext, dir string) (map[schema.GroupKind]*partialCRDSet, error) {
 	res := map[schema.GroupKind]*partialCRDSet{}
-	dirEntries, err := os.ReadDir(dir)
+	dirEntries, err := ctx.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
@@ -538,9 +538,11 @@ func (c Config) webhookVersions() ([]string, error) {
 // Generator generates (partial) {Mutating,Validating}WebhookConfiguration objects.
 type Generator struct {
 	// HeaderFile specifies the header text (e.g. license) to prepend to generated files.
+	//
+	// See genall.Header for its format (including the template variables it may use).
 	HeaderFile string `marker:",optional"`
 
-	// Year specifies the year to substitute for " YEAR" in the header file.
+	// Year specifies the year to use in the header file (as {{ .Year }}, or in place of " YEAR").
 	Year string `marker:",optional"`
 }
 
@@ -713,15 +715,14 @@ func (g Generator) Generate(ctx *genall.GenerationContext) error {
 		}
 	}
 
-	var headerText string
-	if g.HeaderFile != "" {
-		headerBytes, err := ctx.ReadFile(g.HeaderFile)
-		if err != nil {
-			return err
-		}
-		headerText = string(headerBytes)
+	header, err := ctx.LoadHeader(g.HeaderFile, g.Year)
+	if err != nil {
+		return err
+	}
+	headerText, err := header.Render(genall.YAMLArtifact, nil)
+	if err != nil {
+		return err
 	}
-	headerText = strings.ReplaceAll(headerText, " YEAR", " "+g.Year)
 
 	for k, v := range versionedWebhooks {
 		var fileName string
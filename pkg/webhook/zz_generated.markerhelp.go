@@ -122,10 +122,10 @@ func (Generator) Help() *markers.DefinitionHelp {
 		FieldHelp: map[string]markers.DetailedHelp{
 			"HeaderFile": {
 				Summary: "specifies the header text (e.g. license) to prepend to generated files.",
-				Details: "",
+				Details: "See genall.Header for its format (including the template variables it may use).",
 			},
 			"Year": {
-				Summary: "specifies the year to substitute for \" YEAR\" in the header file.",
+				Summary: "specifies the year to use in the header file (as {{ .Year }}, or in place of \" YEAR\").",
 				Details: "",
 			},
 		},